@@ -0,0 +1,83 @@
+package eks
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/internal/utils"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/cluster_config"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/datasource"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/report"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/role_relationships"
+	"github.com/fatih/color"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+)
+
+func buildAuditClusterConfigCommand() *cobra.Command {
+	auditClusterConfigCommand := &cobra.Command{
+		Use:                   "audit-cluster-config",
+		Example:               "mkat eks audit-cluster-config",
+		Short:                 "Audit the configuration of your EKS cluster",
+		Long:                  "audit-cluster-config will check the configuration of your EKS cluster for insecure settings, such as a public API server endpoint, disabled control plane logging or missing secrets encryption",
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cluster, err := getEKSClusterName()
+			if err != nil {
+				return err
+			}
+			return doAuditClusterConfigCommand(cmd.Context(), cluster)
+		},
+	}
+
+	addOutputFlags(auditClusterConfigCommand)
+	return auditClusterConfigCommand
+}
+
+func doAuditClusterConfigCommand(ctx context.Context, targetCluster string) error {
+	var clusterFindings []*findings.Finding
+	if loadedSnapshot != nil {
+		// Evaluate the configuration as of the snapshot date, so that results are reproducible
+		clusterFindings = cluster_config.AuditClusterConfiguration(loadedSnapshot.Cluster, loadedSnapshot.CollectedAt)
+	} else {
+		cluster := role_relationships.EKSCluster{
+			DataSources: &datasource.DataSources{Cluster: datasource.NewLiveClusterDataSource(utils.AWSClient())},
+			Name:        targetCluster,
+		}
+		if err := cluster.RetrieveClusterInformation(ctx); err != nil {
+			return fmt.Errorf("unable to retrieve EKS cluster information: %v", err)
+		}
+		clusterFindings = cluster_config.AuditClusterConfiguration(cluster.ClusterInfo, time.Now())
+	}
+	if isReportOutputFormat() {
+		return writeReport(report.NewClusterConfigReport(clusterFindings, getReportCluster(targetCluster)))
+	}
+	if isFindingsOutputFormat() {
+		return writeFindings(ctx, "audit-cluster-config", targetCluster, clusterFindings)
+	}
+	if len(clusterFindings) == 0 {
+		log.Println("No configuration issues found in your EKS cluster")
+		return nil
+	}
+
+	t := table.NewWriter()
+	t.AppendHeader(table.Row{"Severity", "Finding", "Details", "Remediation"})
+	for _, finding := range clusterFindings {
+		t.AppendRow(table.Row{getSeverityColor(finding.Severity).Sprint(finding.Severity), finding.Title, finding.Description, finding.Remediation})
+	}
+	return writeOutput(t.Render() + "\n")
+}
+
+func getSeverityColor(severity findings.Severity) *color.Color {
+	switch severity {
+	case findings.SeverityCritical, findings.SeverityHigh:
+		return color.New(color.BgRed, color.FgWhite, color.Bold)
+	case findings.SeverityMedium:
+		return color.New(color.FgRed, color.Bold)
+	default:
+		return color.New(color.FgYellow)
+	}
+}
@@ -0,0 +1,59 @@
+package eks
+
+import (
+	"context"
+	"log"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/internal/utils"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/datasource"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/snapshot"
+	"github.com/spf13/cobra"
+)
+
+var snapshotOutputFile string
+var collectSecrets bool
+
+func buildCollectCommand() *cobra.Command {
+	collectCommand := &cobra.Command{
+		Use:                   "collect",
+		Example:               "mkat eks collect -o snapshot.json",
+		Short:                 "Collect a snapshot of your EKS cluster for offline analysis",
+		Long:                  "collect retrieves everything MKAT needs to analyze your EKS cluster and writes it to a JSON file. Other commands can then analyze it offline using --from-snapshot",
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cluster, err := getEKSClusterName()
+			if err != nil {
+				return err
+			}
+			return doCollectCommand(cmd.Context(), cluster)
+		},
+	}
+
+	collectCommand.Flags().StringVarP(&snapshotOutputFile, "output-file", "o", "snapshot.json", "File to write the snapshot to")
+	collectCommand.Flags().BoolVarP(&collectSecrets, "include-secrets", "", false, "Include K8s Secrets in the snapshot, so that find-secrets can scan them. The snapshot will then contain sensitive data")
+	addNamespaceFilterFlags(collectCommand)
+	addPodSelectorFlag(collectCommand)
+	addServiceAccountSelectorFlag(collectCommand)
+	addRoleFilterFlags(collectCommand)
+	return collectCommand
+}
+
+func doCollectCommand(ctx context.Context, targetCluster string) error {
+	collector := snapshot.Collector{
+		DataSources:       datasource.NewLiveDataSources(utils.AWSClient(), utils.K8sClient()),
+		ClusterName:       targetCluster,
+		Filters:           getFilters(),
+		IncludeConfigMaps: true,
+		IncludeSecrets:    collectSecrets,
+		IncludeWorkloads:  true,
+	}
+	clusterSnapshot, err := collector.Collect(ctx)
+	if err != nil {
+		return err
+	}
+	if err := clusterSnapshot.Save(snapshotOutputFile); err != nil {
+		return err
+	}
+	log.Println("Wrote snapshot of cluster " + targetCluster + " to " + snapshotOutputFile)
+	return nil
+}
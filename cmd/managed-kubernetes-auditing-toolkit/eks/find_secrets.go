@@ -1,49 +1,222 @@
 package eks
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/datadog/managed-kubernetes-auditing-toolkit/internal/utils"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/baseline"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/datasource"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/report"
 	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/secrets"
 	"github.com/fatih/color"
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/spf13/cobra"
 )
 
+var validateSecrets bool
+var stsEndpoint string
+var iamEndpoint string
+var stsRegions []string
+var showValues bool
+var baselineFile string
+var updateBaseline bool
+
 func buildEksFindSecretsCommand() *cobra.Command {
 	eksFindSecretsCommand := &cobra.Command{
 		Use:                   "find-secrets",
-		Short:                 "Find hardcoded AWS secrets in your EKS cluster",
-		Long:                  "find-secret will scan your EKS cluster for hardcoded AWS secrets in pod environment variables, configmaps and secrets",
+		Short:                 "Find hardcoded credentials in your EKS cluster",
+		Long:                  "find-secret will scan your EKS cluster for hardcoded AWS, cloud and SaaS credentials in pod environment variables, configmaps and secrets",
 		Example:               "mkat eks find-secrets",
 		DisableFlagsInUseLine: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return doFindSecretsCommand()
+			return doFindSecretsCommand(cmd.Context())
 		},
 	}
 
+	addOutputFlags(eksFindSecretsCommand)
+	addNamespaceFilterFlags(eksFindSecretsCommand)
+	addPodSelectorFlag(eksFindSecretsCommand)
+	eksFindSecretsCommand.Flags().BoolVarP(&validateSecrets, "validate", "", false, "Check if the AWS credentials found are live by calling sts:GetCallerIdentity with them, and retrieve information about the IAM users of the current account they belong to")
+	eksFindSecretsCommand.Flags().StringVarP(&stsEndpoint, "sts-endpoint", "", "", "With --validate, STS endpoint to validate credentials against, e.g. http://localhost:4566 for LocalStack")
+	eksFindSecretsCommand.Flags().StringSliceVarP(&stsRegions, "sts-region", "", nil, "With --validate, region of the STS endpoint to validate credentials against, e.g. cn-north-1 for the China partition. Can be repeated. Defaults to a region of each AWS partition, credentials rejected by all of them being reported as invalid, or to us-east-1 with --sts-endpoint")
+	eksFindSecretsCommand.Flags().StringVarP(&iamEndpoint, "iam-endpoint", "", "", "With --validate, IAM endpoint to retrieve information about IAM users from")
+	eksFindSecretsCommand.Flags().BoolVarP(&showValues, "show-values", "", false, "Show the values of secrets in the output. By default, only their first characters and a hash are shown")
+	eksFindSecretsCommand.Flags().StringVarP(&baselineFile, "baseline", "", "", "Baseline file listing accepted secrets, which are not reported until they expire. When set, the command fails if it finds other secrets")
+	eksFindSecretsCommand.Flags().BoolVarP(&updateBaseline, "update-baseline", "", false, "Add the secrets found to the baseline file, and remove the ones that were not found anymore. Secrets are only removed when the whole cluster was scanned, without filters or errors")
 	return eksFindSecretsCommand
 }
 
-func doFindSecretsCommand() error {
-	detector := secrets.SecretsDetector{K8sClient: utils.K8sClient(), AwsClient: utils.AWSClient()}
-	secrets, err := detector.FindSecrets()
+func doFindSecretsCommand(ctx context.Context) error {
+	if !validateSecrets && (stsEndpoint != "" || iamEndpoint != "" || len(stsRegions) > 0) {
+		return errors.New("--sts-endpoint, --sts-region and --iam-endpoint require --validate")
+	}
+	if updateBaseline && baselineFile == "" {
+		return errors.New("--update-baseline requires --baseline")
+	}
+	secrets, complete, err := findSecrets()
 	if err != nil {
 		return err
 	}
+	if validateSecrets {
+		validateAWSCredentials(ctx, secrets)
+	}
+	// Findings identify resources by cluster, which isn't needed to display a table
+	cluster := ""
+	if baselineFile != "" || isReportOutputFormat() || isFindingsOutputFormat() {
+		cluster, _ = getEKSClusterName()
+	}
+	if baselineFile != "" {
+		if secrets, err = applyBaseline(secrets, cluster, complete); err != nil {
+			return err
+		}
+	}
 
-	if len(secrets) == 0 {
-		log.Println("No hardcoded AWS secrets found in your AWS cluster")
+	if err := writeSecrets(ctx, cluster, secrets); err != nil {
+		return err
+	}
+	// Fail so that pipelines only break on secrets that weren't accepted
+	if baselineFile != "" && len(secrets) > 0 {
+		return fmt.Errorf("found %d secrets that are not accepted in the baseline %s", len(secrets), baselineFile)
+	}
+	return nil
+}
+
+// writeSecrets writes the secrets found in the requested format
+func writeSecrets(ctx context.Context, cluster string, secretInfos []*secrets.SecretInfo) error {
+	if isReportOutputFormat() {
+		return writeReport(report.NewSecretsReport(secretInfos, getReportCluster(cluster), getFilters(), showValues))
+	}
+	if isFindingsOutputFormat() {
+		secretFindings := make([]*findings.Finding, 0, len(secretInfos))
+		for _, secret := range secretInfos {
+			secretFindings = append(secretFindings, secret.Finding(cluster))
+		}
+		return writeFindings(ctx, "find-secrets", cluster, secretFindings)
+	}
+
+	if len(secretInfos) == 0 {
+		log.Println("No hardcoded secrets found in your AWS cluster")
 		return nil
 	}
 
 	t := table.NewWriter()
-	t.AppendHeader(table.Row{"Namespace", "Type", "Name", "Value"})
+	header := table.Row{"Namespace", "Type", "Name", "Value", "Key Type"}
+	if validateSecrets {
+		header = append(header, "Validation")
+	}
+	t.AppendHeader(header)
 	secretColor := color.New(color.BgRed, color.FgWhite, color.Bold)
-	for _, secret := range secrets {
-		t.AppendRow(table.Row{secret.Namespace, secret.Type, secret.Name, secretColor.Sprintf(secret.Value)})
+	for _, secret := range secretInfos {
+		value := secret.Value
+		if !showValues {
+			value = secrets.MaskValue(value)
+		}
+		row := table.Row{secret.Namespace, secret.Type, secret.Name, secretColor.Sprint(value), secret.KeyType}
+		if validateSecrets {
+			validation := ""
+			if secret.Validation != nil {
+				validation = secret.Validation.String()
+			}
+			row = append(row, validation)
+		}
+		t.AppendRow(row)
 	}
 
-	println(t.Render())
-	return nil
+	return writeOutput(t.Render() + "\n")
+}
+
+// findSecrets returns the secrets found, and whether all the resources of the cluster could be scanned
+func findSecrets() ([]*secrets.SecretInfo, bool, error) {
+	if loadedSnapshot != nil {
+		// Snapshots that were filtered or partially collected don't contain all the resources of the cluster
+		complete := loadedSnapshot.Filters == nil && len(loadedSnapshot.Warnings) == 0
+		if len(loadedSnapshot.Secrets) == 0 {
+			log.Println("[WARN] The snapshot contains no Secrets. Collect it with --include-secrets to scan them")
+			complete = false
+		}
+		if loadedSnapshot.Workloads == nil {
+			log.Println("[WARN] The snapshot contains no workloads, only pods will be scanned. Collect it again with this version of MKAT to scan workloads")
+			complete = false
+		}
+		detector := secrets.SecretsDetector{DataSource: loadedSnapshot.DataSources().Kubernetes, Filters: getFilters()}
+		secretInfos, err := detector.FindSecrets()
+		return secretInfos, complete && detector.Complete(), err
+	}
+	detector := secrets.SecretsDetector{
+		DataSource: &datasource.LiveKubernetesDataSource{K8sClient: utils.K8sClient(), PageSize: datasource.DefaultPageSize},
+		Filters:    getFilters(),
+	}
+	secretInfos, err := detector.FindSecrets()
+	return secretInfos, detector.Complete(), err
+}
+
+// validateAWSCredentials checks if the AWS credentials found are live, using the AWS credentials MKAT runs with to
+// retrieve information about the IAM users of the current account
+func validateAWSCredentials(ctx context.Context, secretInfos []*secrets.SecretInfo) {
+	validator := secrets.AwsKeyValidator{AWSConfig: utils.AWSClient(), STSEndpoint: stsEndpoint, IAMEndpoint: iamEndpoint, STSRegions: stsRegions}
+	validator.ValidateSecrets(ctx, secretInfos)
+}
+
+// applyBaseline removes the secrets accepted in the baseline file from the secrets found. With --update-baseline, the
+// baseline is first updated with the secrets found. Entries that weren't found are only removed from the baseline if
+// the whole cluster was scanned successfully.
+func applyBaseline(secretInfos []*secrets.SecretInfo, clusterName string, complete bool) ([]*secrets.SecretInfo, error) {
+	var secretsBaseline *baseline.Baseline
+	var err error
+	if updateBaseline {
+		secretsBaseline, err = baseline.LoadOrCreate(baselineFile)
+	} else {
+		secretsBaseline, err = baseline.Load(baselineFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if updateBaseline {
+		secretFindings := make([]*findings.Finding, 0, len(secretInfos))
+		for _, secret := range secretInfos {
+			secretFindings = append(secretFindings, secret.Finding(clusterName))
+		}
+		secretsBaseline.Update(secretFindings, now, baselineScope(clusterName, complete))
+		if err := secretsBaseline.Save(baselineFile); err != nil {
+			return nil, fmt.Errorf("unable to write baseline file %s: %v", baselineFile, err)
+		}
+		log.Printf("Updated baseline %s, which now contains %d secrets", baselineFile, len(secretsBaseline.Entries))
+	}
+	if entries := secretsBaseline.EntriesNeedingReview(); len(entries) > 0 {
+		log.Printf("[WARN] %d entries of baseline %s have no justification, explain why their secrets are accepted", len(entries), baselineFile)
+	}
+
+	var newSecrets []*secrets.SecretInfo
+	for _, secret := range secretInfos {
+		entry := secretsBaseline.Entry(secret.Finding(clusterName))
+		if entry != nil && !entry.IsExpired(now) {
+			continue
+		}
+		if entry != nil {
+			log.Printf("[WARN] The baseline entry of the secret in %s %s/%s expired on %s, reporting it again", secret.Type, secret.Namespace, secret.Name, entry.Expires)
+		}
+		newSecrets = append(newSecrets, secret)
+	}
+	log.Printf("%d secrets are suppressed by baseline %s", len(secretInfos)-len(newSecrets), baselineFile)
+	return newSecrets, nil
+}
+
+// baselineScope returns which baseline entries were scanned, so that the ones that weren't found anymore can be
+// removed. It returns nil when only part of the cluster was scanned, as entries that weren't found may still exist.
+func baselineScope(clusterName string, complete bool) func(*baseline.Entry) bool {
+	if getFilters() != nil || !complete {
+		log.Println("[WARN] Not all resources of the cluster were scanned, baseline entries that were not found are kept")
+		return nil
+	}
+	clusterResources := findings.KubernetesResource(clusterName, findings.ResourceTypeKubernetesNamespace, "", "").ID
+	return func(entry *baseline.Entry) bool {
+		return strings.HasPrefix(entry.Resource, clusterResources)
+	}
 }
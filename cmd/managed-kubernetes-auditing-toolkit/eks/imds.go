@@ -1,13 +1,21 @@
 package eks
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"sync"
 
 	"github.com/datadog/managed-kubernetes-auditing-toolkit/internal/utils"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/datasource"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
 	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/imds"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/report"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 var successColor = color.New(color.BgBlack, color.FgGreen, color.Bold)
@@ -20,24 +28,84 @@ func buildTestImdsAccessCommand() *cobra.Command {
 		Short:                 "Test if your EKS cluster allows pod access to the IMDS",
 		Long:                  "test-imds-access will check if your EKS cluster allows pods to access the IMDS by running a pod and executing a curl command hitting the IMDS",
 		DisableFlagsInUseLine: true,
-		Run: func(cmd *cobra.Command, args []string) {
-			doTestImdsAccessCommand()
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if loadedSnapshot != nil {
+				return errors.New("test-imds-access runs pods in the live cluster and cannot be used with --from-snapshot")
+			}
+			return doTestImdsAccessCommand(cmd.Context())
 		},
 	}
 
+	addOutputFlags(eksFindSecretsCommand)
+	addNamespaceFilterFlags(eksFindSecretsCommand)
+	addPodSelectorFlag(eksFindSecretsCommand)
 	return eksFindSecretsCommand
 }
 
-func doTestImdsAccessCommand() {
-	tester := imds.ImdsTester{K8sClient: utils.K8sClient(), Namespace: "default"}
-	log.Println("Testing if IMDSv1 and IMDSv2 are accessible from pods by creating a pod that attempts to access it")
+func doTestImdsAccessCommand(ctx context.Context) error {
+	namespaces, err := getImdsTestNamespaces(ctx)
+	if err != nil {
+		return err
+	}
+	// Tester pods get the labels of the pods we're interested in, so that the same NetworkPolicies apply to them
+	podLabels, err := labels.ConvertSelectorToLabelsMap(cliFilters.PodLabelSelector)
+	if err != nil {
+		return fmt.Errorf("test-imds-access only supports label selectors of the form 'key=value,...', since tester pods get these labels: %v", err)
+	}
+
+	var results []*report.IMDSAccessResult
+	for _, namespace := range namespaces {
+		tester := imds.ImdsTester{K8sClient: utils.K8sClient(), Namespace: namespace, PodLabels: podLabels}
+		log.Println("Testing if IMDSv1 and IMDSv2 are accessible from pods in namespace " + namespace + " by creating a pod that attempts to access it")
+
+		// We run the test for IMDSv1 and IMDSv2 in parallel
+		namespaceResults := make([]*report.IMDSAccessResult, 2)
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go doTestImdsAccess(IMDSv1, &tester, &namespaceResults[0], &wg)
+		go doTestImdsAccess(IMDSv2, &tester, &namespaceResults[1], &wg)
+		wg.Wait()
+		results = append(results, namespaceResults...)
+	}
+
+	if isReportOutputFormat() {
+		// The cluster name is only informative here, since tester pods run in the current cluster regardless
+		cluster, _ := getEKSClusterName()
+		return writeReport(report.NewIMDSAccessReport(results, getReportCluster(cluster), getFilters()))
+	}
+	if isFindingsOutputFormat() {
+		cluster, _ := getEKSClusterName()
+		var accessFindings []*findings.Finding
+		for _, result := range results {
+			if result.Accessible != nil && *result.Accessible {
+				accessFindings = append(accessFindings, imds.AccessFinding(cluster, result.Namespace, result.IMDSVersion, result.Description))
+			}
+		}
+		return writeFindings(ctx, "test-imds-access", cluster, accessFindings)
+	}
+	return nil
+}
 
-	// We run the test for IMDSv1 and IMDSv2 in parallel
-	var wg sync.WaitGroup
-	wg.Add(2)
-	go doTestImdsAccess(IMDSv1, &tester, &wg)
-	go doTestImdsAccess(IMDSv2, &tester, &wg)
-	wg.Wait()
+// getImdsTestNamespaces returns the namespaces to run tester pods in, "default" unless namespaces are specified
+func getImdsTestNamespaces(ctx context.Context) ([]string, error) {
+	if len(cliFilters.IncludeNamespaces) == 0 {
+		if !cliFilters.MatchesNamespace("default") {
+			return nil, errors.New("the default namespace is excluded, specify namespaces to run tester pods in with --namespace")
+		}
+		return []string{"default"}, nil
+	}
+	dataSource := &datasource.LiveKubernetesDataSource{K8sClient: utils.K8sClient()}
+	namespaces, err := cliFilters.ResolveNamespaces(ctx, dataSource)
+	if err != nil {
+		return nil, err
+	}
+	if namespaces == nil {
+		return nil, errors.New("unable to determine which namespaces match " + strings.Join(cliFilters.IncludeNamespaces, ", ") + ", specify them without wildcards")
+	}
+	if len(namespaces) == 0 {
+		return nil, errors.New("no namespace matches " + strings.Join(cliFilters.IncludeNamespaces, ", "))
+	}
+	return namespaces, nil
 }
 
 type ImdsVersion string
@@ -47,11 +115,13 @@ const (
 	IMDSv2 ImdsVersion = "IMDSv2"
 )
 
-func doTestImdsAccess(imdsVersion ImdsVersion, tester *imds.ImdsTester, wg *sync.WaitGroup) {
+// doTestImdsAccess runs the test for a version of the IMDS, logs its result and records it for reports
+func doTestImdsAccess(imdsVersion ImdsVersion, tester *imds.ImdsTester, reportResult **report.IMDSAccessResult, wg *sync.WaitGroup) {
 	var result *imds.ImdsTestResult
 	var err error
 
 	defer wg.Done()
+	*reportResult = &report.IMDSAccessResult{Namespace: tester.Namespace, IMDSVersion: string(imdsVersion)}
 
 	switch imdsVersion {
 	case IMDSv1:
@@ -64,8 +134,17 @@ func doTestImdsAccess(imdsVersion ImdsVersion, tester *imds.ImdsTester, wg *sync
 
 	if err != nil {
 		log.Printf("Unable to determine if %s is accessible in your cluster: %s\n", imdsVersion, err.Error())
+		(*reportResult).Error = err.Error()
 		return
 	}
+	(*reportResult).Accessible = &result.IsImdsAccessible
+	(*reportResult).Description = result.ResultDescription
+	(*reportResult).FargateProfile = result.FargateProfile
+
+	if result.FargateProfile != "" {
+		log.Printf("[WARNING] The %s tester pod was scheduled on Fargate (profile %s), which never exposes the IMDS of a node to pods. "+
+			"The result below does not apply to pods running on EC2 nodes\n", imdsVersion, result.FargateProfile)
+	}
 
 	if result.IsImdsAccessible {
 		log.Printf("%s: %s\n", warningColor.Sprintf("%s is accessible", imdsVersion), result.ResultDescription)
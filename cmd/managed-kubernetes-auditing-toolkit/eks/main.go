@@ -3,13 +3,27 @@ package eks
 import (
 	"errors"
 	"log"
+	"time"
 
 	"github.com/common-nighthawk/go-figure"
 	"github.com/datadog/managed-kubernetes-auditing-toolkit/internal/utils"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/filters"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/snapshot"
 	"github.com/spf13/cobra"
 )
 
 var skipEksHostnameCheck bool
+var eksClusterName string
+var useNativeAuth bool
+var snapshotFile string
+var cliFilters filters.Filters
+
+// Snapshot to analyze instead of the live cluster, when --from-snapshot is used
+var loadedSnapshot *snapshot.Snapshot
+
+// Current EKS cluster, as determined automatically the first time a command needs its name
+var resolvedCluster *utils.EKSClusterResolution
+var clusterResolutionAttempted bool
 
 func BuildEksSubcommand() *cobra.Command {
 	eksCommand := &cobra.Command{
@@ -18,21 +32,106 @@ func BuildEksSubcommand() *cobra.Command {
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			figure.NewFigure("mkat", "", true).Print()
 			println()
-			if !skipEksHostnameCheck && !utils.IsEKS() {
-				return errors.New("you do not seem to be connected to an EKS cluster. Connect to an EKS cluster and try again")
+			if err := cliFilters.Validate(); err != nil {
+				return err
+			}
+			if snapshotFile != "" {
+				return loadSnapshot(cmd)
 			}
-			clusterName := utils.GetEKSClusterName()
-			if clusterName != "" {
-				log.Println("Connected to EKS cluster " + clusterName)
+			if useNativeAuth {
+				if eksClusterName == "" {
+					return errors.New("--native-auth requires the EKS cluster name to be specified with --eks-cluster-name")
+				}
+				utils.UseEKSNativeAuthentication(eksClusterName)
+			}
+			// Clusters behind a custom hostname are only recognized by looking up their endpoint, which is expensive
+			if !skipEksHostnameCheck && !utils.IsEKS() && resolveEKSCluster() == nil {
+				return errors.New("you do not seem to be connected to an EKS cluster. Connect to an EKS cluster and try again")
 			}
 			return nil
 		},
 	}
 
 	eksCommand.PersistentFlags().BoolVarP(&skipEksHostnameCheck, "skip-eks-hostname-check", "", false, "Don't check that the hostname of your current API server ends with .eks.amazonaws.com")
+	eksCommand.PersistentFlags().StringVarP(&eksClusterName, "eks-cluster-name", "", "", "When the EKS cluster name cannot be automatically detected from your KubeConfig, specify this argument to pass the EKS cluster name of your current kubectl context")
+	eksCommand.PersistentFlags().BoolVarP(&useNativeAuth, "native-auth", "", false, "Connect to the cluster passed in --eks-cluster-name using your AWS credentials, without using a KubeConfig file or the AWS CLI")
+	eksCommand.PersistentFlags().StringVarP(&snapshotFile, "from-snapshot", "", "", "Analyze a snapshot written by 'mkat eks collect' instead of the live cluster")
 	eksCommand.AddCommand(buildEksRoleRelationshipsCommand())
 	eksCommand.AddCommand(buildEksFindSecretsCommand())
 	eksCommand.AddCommand(buildTestImdsAccessCommand())
+	eksCommand.AddCommand(buildAuditClusterConfigCommand())
+	eksCommand.AddCommand(buildCollectCommand())
 
 	return eksCommand
 }
+
+// getEKSClusterName returns the name of the current EKS cluster, as specified on the CLI or determined automatically
+func getEKSClusterName() (string, error) {
+	if loadedSnapshot != nil {
+		return loadedSnapshot.ClusterName, nil
+	}
+	if eksClusterName != "" {
+		return eksClusterName, nil
+	}
+	if cluster := resolveEKSCluster(); cluster != nil {
+		return cluster.Name, nil
+	}
+	return "", errors.New("unable to determine your current EKS cluster name. Try specifying it explicitly with the --eks-cluster-name flag")
+}
+
+// resolveEKSCluster determines the current EKS cluster once, as it can require listing and describing the EKS
+// clusters of the account. It returns nil if the cluster couldn't be determined.
+func resolveEKSCluster() *utils.EKSClusterResolution {
+	if !clusterResolutionAttempted {
+		clusterResolutionAttempted = true
+		resolvedCluster = utils.ResolveEKSCluster(utils.AWSClient())
+		if resolvedCluster != nil {
+			log.Printf("Connected to EKS cluster %s (determined from %s)", resolvedCluster.Name, resolvedCluster.Strategy)
+		}
+	}
+	return resolvedCluster
+}
+
+// addNamespaceFilterFlags lets users restrict the namespaces a command analyzes. It's also useful when they're not
+// allowed to list resources in all namespaces.
+func addNamespaceFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVarP(&cliFilters.IncludeNamespaces, "namespace", "n", []string{}, "Only analyze namespaces matching these glob patterns, e.g. 'team-*' (can be repeated or comma-separated). By default, all namespaces are analyzed")
+	cmd.Flags().StringSliceVarP(&cliFilters.ExcludeNamespaces, "exclude-namespace", "", []string{}, "Don't analyze namespaces matching these glob patterns (can be repeated or comma-separated)")
+}
+
+func addPodSelectorFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&cliFilters.PodLabelSelector, "pod-selector", "", "", "Only analyze pods matching this label selector, e.g. 'app=web,tier!=cache'")
+}
+
+func addServiceAccountSelectorFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&cliFilters.ServiceAccountLabelSelector, "service-account-selector", "", "", "Only analyze service accounts matching this label selector")
+}
+
+func addRoleFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVarP(&cliFilters.IncludeRoles, "role", "", []string{}, "Only analyze IAM roles whose ARN or name matches these regular expressions (can be repeated)")
+	cmd.Flags().StringSliceVarP(&cliFilters.ExcludeRoles, "exclude-role", "", []string{}, "Don't analyze IAM roles whose ARN or name matches these regular expressions (can be repeated)")
+}
+
+// getFilters returns the filters passed on the command line, or nil if there are none
+func getFilters() *filters.Filters {
+	if cliFilters.IsEmpty() {
+		return nil
+	}
+	return &cliFilters
+}
+
+// loadSnapshot loads the snapshot passed in --from-snapshot. No connection to the cluster or to AWS is needed then.
+func loadSnapshot(cmd *cobra.Command) error {
+	if cmd.Name() == "collect" {
+		return errors.New("--from-snapshot cannot be used with the collect command")
+	}
+	var err error
+	if loadedSnapshot, err = snapshot.Load(snapshotFile); err != nil {
+		return err
+	}
+	log.Printf("Analyzing snapshot of EKS cluster %s collected at %s", loadedSnapshot.ClusterName, loadedSnapshot.CollectedAt.Format(time.RFC3339))
+	for _, warning := range loadedSnapshot.Warnings {
+		log.Println("[WARNING] Snapshot is incomplete: " + warning)
+	}
+	return nil
+}
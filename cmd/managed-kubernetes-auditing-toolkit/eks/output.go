@@ -0,0 +1,173 @@
+package eks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/internal/utils"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/asff"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/datasource"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/ocsf"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/report"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/sarif"
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/slices"
+)
+
+// ToolVersion is the version of mkat reported in SARIF, ASFF and OCSF output
+var ToolVersion = ""
+
+var securityHubImport bool
+var securityHubEndpoint string
+
+// Output formats available to all commands. JSON and YAML follow the schema of the report package.
+var reportOutputFormats = []string{TextOutputFormat, JsonOutputFormat, YamlOutputFormat, SarifOutputFormat, AsffOutputFormat, OcsfOutputFormat}
+
+// Output formats in which results are converted to findings
+var findingsOutputFormats = []string{SarifOutputFormat, AsffOutputFormat, OcsfOutputFormat}
+
+// addOutputFlags adds the --output-format and --output-file flags to commands that support all report formats
+func addOutputFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&outputFormat, "output-format", "f", DefaultOutputFormat, "Output format. Supported formats: "+strings.Join(reportOutputFormats, ", "))
+	cmd.Flags().StringVarP(&outputFile, "output-file", "o", "", "Output file. If not specified, output will be printed to stdout.")
+	addSecurityHubFlags(cmd)
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		return validateOutputFormat(reportOutputFormats)
+	}
+}
+
+func addSecurityHubFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&securityHubImport, "security-hub-import", "", false, "With --output-format asff, also import findings into AWS Security Hub")
+	cmd.Flags().StringVarP(&securityHubEndpoint, "security-hub-endpoint", "", "", "Security Hub endpoint to import findings into, e.g. http://localhost:4566 for LocalStack. Defaults to the endpoint of the region of the cluster")
+}
+
+func validateOutputFormat(formats []string) error {
+	if !slices.Contains(formats, outputFormat) {
+		return fmt.Errorf("invalid output format %s", outputFormat)
+	}
+	if (securityHubImport || securityHubEndpoint != "") && outputFormat != AsffOutputFormat {
+		return errors.New("--security-hub-import and --security-hub-endpoint require --output-format asff")
+	}
+	return nil
+}
+
+// isReportOutputFormat returns true if the output should be a machine-readable report
+func isReportOutputFormat() bool {
+	return outputFormat == JsonOutputFormat || outputFormat == YamlOutputFormat
+}
+
+// isFindingsOutputFormat returns true if results should be converted to findings, written with writeFindings
+func isFindingsOutputFormat() bool {
+	return slices.Contains(findingsOutputFormats, outputFormat)
+}
+
+// writeReport writes a report in the requested format
+func writeReport(commandReport *report.Report) error {
+	output, err := commandReport.Marshal(report.Format(outputFormat))
+	if err != nil {
+		return err
+	}
+	return writeOutput(string(output))
+}
+
+// writeFindings writes the findings of a command in the requested format
+func writeFindings(ctx context.Context, command string, clusterName string, commandFindings []*findings.Finding) error {
+	if outputFormat == SarifOutputFormat {
+		return writeSarif(command, clusterName, commandFindings)
+	}
+
+	// ASFF and OCSF findings belong to the account and region of the cluster
+	clusterArn, err := getClusterArn(ctx, clusterName)
+	if err != nil {
+		return err
+	}
+	var output []byte
+	switch outputFormat {
+	case AsffOutputFormat:
+		asffFindings, err := asff.ConvertFindings(commandFindings, clusterArn, ToolVersion, time.Now())
+		if err != nil {
+			return err
+		}
+		if securityHubImport && len(asffFindings) > 0 {
+			sender := asff.SecurityHubSender{AWSConfig: utils.AWSClient(), Endpoint: securityHubEndpoint}
+			if err := sender.BatchImportFindings(ctx, asffFindings); err != nil {
+				return err
+			}
+		}
+		output, err = asff.Marshal(asffFindings)
+		if err != nil {
+			return err
+		}
+	case OcsfOutputFormat:
+		ocsfFindings, err := ocsf.ConvertFindings(commandFindings, clusterArn, ToolVersion, time.Now())
+		if err != nil {
+			return err
+		}
+		output, err = ocsf.Marshal(ocsfFindings)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported output format %s", outputFormat)
+	}
+	return writeOutput(string(output))
+}
+
+// writeSarif writes findings as a SARIF log. The command and cluster name categorize the run, so that SARIF consumers
+// track the alerts of each command and cluster separately.
+func writeSarif(command string, clusterName string, commandFindings []*findings.Finding) error {
+	category := command
+	if clusterName != "" {
+		category += "/" + clusterName
+	}
+	output, err := sarif.NewLog(ToolVersion, category, commandFindings).Marshal()
+	if err != nil {
+		return err
+	}
+	return writeOutput(string(output))
+}
+
+// getClusterArn returns the ARN of the analyzed cluster, from the snapshot if any
+func getClusterArn(ctx context.Context, clusterName string) (string, error) {
+	if loadedSnapshot != nil {
+		if loadedSnapshot.Cluster.Arn == nil {
+			return "", errors.New("the snapshot doesn't contain the ARN of the cluster")
+		}
+		return *loadedSnapshot.Cluster.Arn, nil
+	}
+	if clusterName == "" {
+		return "", errors.New("unable to determine your current EKS cluster name, which is needed to determine the account and region of findings. Specify it with the --eks-cluster-name flag")
+	}
+	cluster, err := datasource.NewLiveClusterDataSource(utils.AWSClient()).DescribeCluster(ctx, clusterName)
+	if err != nil {
+		return "", fmt.Errorf("unable to determine the account and region of findings: %v", err)
+	}
+	if cluster.Arn == nil {
+		return "", fmt.Errorf("unable to determine the ARN of cluster %s", clusterName)
+	}
+	return *cluster.Arn, nil
+}
+
+// writeOutput writes the output of a command to the output file, or to stdout so that it can be piped to other tools
+func writeOutput(output string) error {
+	if outputFile != "" {
+		log.Println("Writing " + strings.ToUpper(outputFormat) + " output to " + outputFile)
+		return os.WriteFile(outputFile, []byte(output), 0644)
+	}
+	_, err := fmt.Fprint(os.Stdout, output)
+	return err
+}
+
+// getReportCluster describes the analyzed cluster in reports
+func getReportCluster(clusterName string) report.Cluster {
+	if loadedSnapshot != nil {
+		return report.Cluster{Name: clusterName, CollectedAt: loadedSnapshot.CollectedAt, Snapshot: snapshotFile}
+	}
+	return report.Cluster{Name: clusterName, CollectedAt: time.Now().UTC()}
+}
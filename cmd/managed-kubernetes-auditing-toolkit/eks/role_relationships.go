@@ -1,37 +1,48 @@
 package eks
 
 import (
-	"errors"
+	"context"
+	"encoding/csv"
 	"fmt"
 	"log"
-	"os"
 	"strings"
 
-	"github.com/awalterschulze/gographviz"
 	"github.com/aws/aws-sdk-go-v2/aws/arn"
 	"github.com/datadog/managed-kubernetes-auditing-toolkit/internal/utils"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/datasource"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/graph"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/report"
 	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/role_relationships"
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/jedib0t/go-pretty/v6/text"
 	"github.com/spf13/cobra"
-	"golang.org/x/exp/slices"
 	"golang.org/x/term"
 )
 
 // Command-line arguments
 var outputFormat string
 var outputFile string
-var eksClusterName string
 var showFullRoleArns bool
+var collapseWorkloads bool
 
 // Output formats
 const (
-	CsvOutputFormat  string = "csv"
-	TextOutputFormat string = "text"
-	DotOutputFormat  string = "dot"
+	CsvOutputFormat     string = "csv"
+	TextOutputFormat    string = "text"
+	DotOutputFormat     string = "dot"
+	MermaidOutputFormat string = "mermaid"
+	JsonOutputFormat    string = "json"
+	YamlOutputFormat    string = "yaml"
+	SarifOutputFormat   string = "sarif"
+	AsffOutputFormat    string = "asff"
+	OcsfOutputFormat    string = "ocsf"
+	// Graph database formats
+	CypherOutputFormat    string = "cypher"
+	GraphMLOutputFormat   string = "graphml"
+	GraphJsonOutputFormat string = "graph-json"
 )
 
-var availableOutputFormats = []string{CsvOutputFormat, TextOutputFormat, DotOutputFormat}
+var availableOutputFormats = []string{CsvOutputFormat, TextOutputFormat, DotOutputFormat, MermaidOutputFormat, JsonOutputFormat, YamlOutputFormat, SarifOutputFormat, AsffOutputFormat, OcsfOutputFormat, CypherOutputFormat, GraphMLOutputFormat, GraphJsonOutputFormat}
 
 const DefaultOutputFormat = TextOutputFormat
 
@@ -43,54 +54,60 @@ func buildEksRoleRelationshipsCommand() *cobra.Command {
 		Long:                  "Analyzes your EKS cluster and finds all service accounts that can assume AWS roles, based on their trust policies ",
 		DisableFlagsInUseLine: true,
 		PreRunE: func(cmd *cobra.Command, args []string) error {
-			if !slices.Contains(availableOutputFormats, outputFormat) {
-				return fmt.Errorf("invalid output format %s", outputFormat)
-			}
-			return nil
+			return validateOutputFormat(availableOutputFormats)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cluster := utils.GetEKSClusterName()
-			if cluster == "" {
-				// If we cannot determine the EKS cluster name automatically, give the user a chance to specify it on the CLI
-				cluster = eksClusterName
-			}
-			if cluster == "" {
-				return errors.New("unable to determine your current EKS cluster name. Try specifying it explicitely with the --eks-cluster-name flag")
+			cluster, err := getEKSClusterName()
+			if err != nil {
+				return err
 			}
-			return doFindRoleRelationshipsCommand(cluster)
+			return doFindRoleRelationshipsCommand(cmd.Context(), cluster)
 		},
 	}
 
 	eksRoleRelationshipsCommand.Flags().StringVarP(&outputFormat, "output-format", "f", DefaultOutputFormat, "Output format. Supported formats: "+strings.Join(availableOutputFormats, ", "))
 	eksRoleRelationshipsCommand.Flags().StringVarP(&outputFile, "output-file", "o", "", "Output file. If not specified, output will be printed to stdout.")
-	eksRoleRelationshipsCommand.Flags().StringVarP(&eksClusterName, "eks-cluster-name", "", "", "When the EKS cluster name cannot be automatically detected from your KubeConfig, specify this argument to pass the EKS cluster name of your current kubectl context")
+	addSecurityHubFlags(eksRoleRelationshipsCommand)
 	eksRoleRelationshipsCommand.Flags().BoolVarP(&showFullRoleArns, "show-full-role-arns", "", false, "Show full ARNs of roles instead of just the role name")
+	eksRoleRelationshipsCommand.Flags().BoolVarP(&collapseWorkloads, "collapse-workloads", "", false, "With the dot and mermaid output formats, draw a single node per workload (e.g. Deployment) instead of one per pod")
+	addNamespaceFilterFlags(eksRoleRelationshipsCommand)
+	addPodSelectorFlag(eksRoleRelationshipsCommand)
+	addServiceAccountSelectorFlag(eksRoleRelationshipsCommand)
+	addRoleFilterFlags(eksRoleRelationshipsCommand)
 	return eksRoleRelationshipsCommand
 }
 
 // Actual logic implementing the "find-role-relationships" command
-func doFindRoleRelationshipsCommand(targetCluster string) error {
-	resolver := role_relationships.EKSCluster{
-		K8sClient: utils.K8sClient(),
-		AwsClient: utils.AWSClient(),
-		Name:      targetCluster,
+func doFindRoleRelationshipsCommand(ctx context.Context, targetCluster string) error {
+	var resolver role_relationships.EKSCluster
+	var err error
+	if loadedSnapshot != nil {
+		resolver = role_relationships.EKSCluster{Name: targetCluster, Filters: getFilters()}
+		err = resolver.AnalyzeRoleRelationshipsFromSnapshot(loadedSnapshot.Filter(getFilters()))
+	} else {
+		resolver = role_relationships.EKSCluster{
+			DataSources: datasource.NewLiveDataSources(utils.AWSClient(), utils.K8sClient()),
+			Filters:     getFilters(),
+			Name:        targetCluster,
+		}
+		err = resolver.AnalyzeRoleRelationships(ctx)
 	}
-	err := resolver.AnalyzeRoleRelationships()
 	if err != nil {
 		log.Fatalf("unable to analyze cluster role relationships: %v", err)
 	}
+	logFargatePods(&resolver)
 
+	if isReportOutputFormat() {
+		return writeReport(report.NewRoleRelationshipsReport(&resolver, getReportCluster(targetCluster)))
+	}
+	if isFindingsOutputFormat() {
+		return writeFindings(ctx, "find-role-relationships", targetCluster, resolver.FindRisks())
+	}
 	output, err := getOutput(&resolver)
 	if err != nil {
 		return err
 	}
-	if outputFile != "" {
-		log.Println("Writing " + strings.ToUpper(outputFormat) + " output to " + outputFile)
-		return os.WriteFile(outputFile, []byte(output), 0644)
-	}
-
-	print(output)
-	return nil
+	return writeOutput(output)
 }
 
 func getOutput(resolver *role_relationships.EKSCluster) (string, error) {
@@ -98,9 +115,17 @@ func getOutput(resolver *role_relationships.EKSCluster) (string, error) {
 	case TextOutputFormat:
 		return getTextOutput(resolver)
 	case DotOutputFormat:
-		return getDotOutput(resolver)
+		return graph.Build(resolver).DOT(getRenderOptions()), nil
+	case MermaidOutputFormat:
+		return graph.Build(resolver).Mermaid(getRenderOptions()), nil
 	case CsvOutputFormat:
 		return getCsvOutput(resolver)
+	case CypherOutputFormat:
+		return graph.Build(resolver).Cypher(), nil
+	case GraphMLOutputFormat:
+		return graph.Build(resolver).GraphML()
+	case GraphJsonOutputFormat:
+		return graph.Build(resolver).JSON()
 	default:
 		return "", fmt.Errorf("unsupported output format %s", outputFormat)
 	}
@@ -127,7 +152,7 @@ func getTextOutput(resolver *role_relationships.EKSCluster) (string, error) {
 				continue
 			}
 			for _, role := range pod.ServiceAccount.AssumableRoles {
-				t.AppendRow([]interface{}{namespace, pod.ServiceAccount.Name, pod.Name, getRoleDisplayName(role.IAMRole), role.Reason})
+				t.AppendRow([]interface{}{namespace, pod.ServiceAccount.Name, getPodDisplayName(pod), getRoleDisplayName(role.IAMRole), getMechanismDisplayName(resolver, pod, role)})
 				found = true
 			}
 		}
@@ -139,92 +164,46 @@ func getTextOutput(resolver *role_relationships.EKSCluster) (string, error) {
 	return t.Render(), nil
 }
 
-type Vertex struct {
-	ID    int
-	Label string
-}
-
-func (v *Vertex) GetID() int {
-	return v.ID
-}
-
-func getDotOutput(resolver *role_relationships.EKSCluster) (string, error) {
-	graphAst, _ := gographviz.ParseString(`digraph G { }`)
-	graphViz := gographviz.NewGraph()
-	gographviz.Analyse(graphAst, graphViz)
-	graphViz.AddAttr("G", "rankdir", "LR")
-	graphViz.AddAttr("G", "splines", "polyline")
-	graphViz.AddAttr("G", "ranksep", "1.2")
-	graphViz.AddAttr("G", "nodesep", "0.8")
-	graphViz.AddAttr("G", "outputorder", "edgesfirst")
-	graphViz.AddAttr("G", "overlap", "false")
-	graphViz.AddAttr("G", "newrank", "true")
-
-	for namespace, pods := range resolver.PodsByNamespace {
-		subgraph := fmt.Sprintf(` "cluster_%s" `, namespace)
-		graphViz.AddSubGraph("G", subgraph, map[string]string{
-			"rank":  "same",
-			"label": fmt.Sprintf(`"%s"`, namespace),
-			"color": "lightgrey",
-			"style": "rounded",
-		})
-		for _, pod := range pods {
-			if pod.ServiceAccount == nil || len(pod.ServiceAccount.AssumableRoles) == 0 {
-				continue
-			}
-			podLabel := fmt.Sprintf(` "Pod %s" `, pod.Name)
-			graphViz.AddNode(subgraph, podLabel, map[string]string{
-				"fontname":  "Helvetica",
-				"shape":     "box",
-				"style":     "filled",
-				"fillcolor": "lightgrey",
-				"fontsize":  "12",
-			})
-			for _, role := range pod.ServiceAccount.AssumableRoles {
-				roleLabel := fmt.Sprintf(`"IAM role %s"`, getRoleName(role.IAMRole))
-				graphViz.AddNode("G", roleLabel, map[string]string{
-					"fontname":  "Helvetica",
-					"shape":     "box",
-					"style":     "filled",
-					"fillcolor": `"#BFEFFF"`,
-					"fontsize":  "12",
-				})
-				graphViz.AddEdge(podLabel, roleLabel, true, map[string]string{
-					"fontname": "Helvetica",
-					"color":    "black",
-					"penwidth": "1",
-					"fontsize": "10",
-					"weight":   "2.0",
-				})
-			}
-		}
-	}
-
-	return graphViz.String(), nil
+func getRenderOptions() graph.RenderOptions {
+	return graph.RenderOptions{CollapseWorkloads: collapseWorkloads, FullRoleArns: showFullRoleArns}
 }
 
 func getCsvOutput(resolver *role_relationships.EKSCluster) (string, error) {
 	sb := new(strings.Builder)
-	sb.WriteString("namespace,pod,service_account,role_arn,reason")
+	// Reasons are free text, which the CSV writer quotes when they contain commas
+	writer := csv.NewWriter(sb)
+	if err := writer.Write([]string{"namespace", "pod", "service_account", "role_arn", "reason", "fargate_profile", "ineffective_reason"}); err != nil {
+		return "", err
+	}
 	for namespace, pods := range resolver.PodsByNamespace {
 		for _, pod := range pods {
 			if pod.ServiceAccount == nil || len(pod.ServiceAccount.AssumableRoles) == 0 {
 				continue
 			}
 			for _, role := range pod.ServiceAccount.AssumableRoles {
-				sb.WriteString(fmt.Sprintf(
-					"%s,%s,%s,%s,%s",
+				fargateProfile := ""
+				if pod.FargateProfile != nil {
+					fargateProfile = pod.FargateProfile.Name
+				}
+				err := writer.Write([]string{
 					namespace,
 					pod.Name,
 					pod.ServiceAccount.Name,
 					getRoleDisplayName(role.IAMRole),
-					role.Reason,
-				))
-				sb.WriteRune('\n')
+					string(role.Reason),
+					fargateProfile,
+					resolver.PodIdentityIneffectiveReason(pod, role),
+				})
+				if err != nil {
+					return "", err
+				}
 			}
 		}
 	}
-
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return "", fmt.Errorf("unable to write CSV output: %v", err)
+	}
 	return sb.String(), nil
 }
 
@@ -239,3 +218,40 @@ func getRoleName(role *role_relationships.IAMRole) string {
 	parsedArn, _ := arn.Parse(role.Arn)
 	return strings.TrimPrefix(parsedArn.Resource, "role/")
 }
+
+func getMechanismDisplayName(resolver *role_relationships.EKSCluster, pod *role_relationships.K8sPod, role *role_relationships.AssumableIAMRole) string {
+	if reason := resolver.PodIdentityIneffectiveReason(pod, role); reason != "" {
+		return fmt.Sprintf("%s (ineffective: %s)", role.Reason, reason)
+	}
+	return string(role.Reason)
+}
+
+func getPodDisplayName(pod *role_relationships.K8sPod) string {
+	if pod.FargateProfile != nil {
+		return pod.Name + " (Fargate)"
+	}
+	return pod.Name
+}
+
+// logFargatePods reports pods running on Fargate, which can't access the IMDS of a node and whose kubelet uses the
+// pod execution role of their Fargate profile
+func logFargatePods(resolver *role_relationships.EKSCluster) {
+	podsByProfile := map[string]int{}
+	executionRoles := map[string]string{}
+	for _, pods := range resolver.PodsByNamespace {
+		for _, pod := range pods {
+			if pod.FargateProfile == nil {
+				continue
+			}
+			podsByProfile[pod.FargateProfile.Name]++
+			executionRoles[pod.FargateProfile.Name] = pod.FargateProfile.PodExecutionRoleArn
+		}
+	}
+	for profile, numPods := range podsByProfile {
+		executionRole := executionRoles[profile]
+		if executionRole == "" {
+			executionRole = "unknown"
+		}
+		log.Printf("%d pods run on Fargate profile %s (pod execution role: %s) and cannot access the IMDS of a node", numPods, profile, executionRole)
+	}
+}
@@ -0,0 +1,36 @@
+package eks
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/role_relationships"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCsvOutputQuotesReasons(t *testing.T) {
+	role := &role_relationships.AssumableIAMRole{
+		IAMRole: &role_relationships.IAMRole{Arn: "arn:aws:iam::111122223333:role/app"},
+		Reason:  role_relationships.AssumeIAMRoleReasonPodIdentity,
+	}
+	pod := &role_relationships.K8sPod{
+		Name:           "app-7d4b9c",
+		Namespace:      "default",
+		ServiceAccount: &role_relationships.K8sServiceAccount{Name: "app", Namespace: "default", AssumableRoles: []*role_relationships.AssumableIAMRole{role}},
+		FargateProfile: &role_relationships.FargateProfile{Name: "default"},
+	}
+	cluster := &role_relationships.EKSCluster{
+		PodsByNamespace:  map[string][]*role_relationships.K8sPod{"default": {pod}},
+		PodIdentityAgent: &role_relationships.PodIdentityAgentStatus{},
+	}
+
+	output, err := getCsvOutput(cluster)
+	assert.Nil(t, err)
+	records, err := csv.NewReader(strings.NewReader(output)).ReadAll()
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{
+		{"namespace", "pod", "service_account", "role_arn", "reason", "fargate_profile", "ineffective_reason"},
+		{"default", "app-7d4b9c", "app", "app", "Pod Identity", "default", "pod runs on Fargate, which does not support Pod Identity"},
+	}, records)
+}
@@ -0,0 +1,105 @@
+package eks
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/common-nighthawk/go-figure"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/manifests"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/report"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/secrets"
+	"github.com/fatih/color"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+)
+
+var helmValuesFiles []string
+var helmSetValues []string
+
+// Output formats of scan-manifests. Findings formats identify resources by cluster, which local files don't belong to.
+var scanManifestsOutputFormats = []string{TextOutputFormat, JsonOutputFormat, YamlOutputFormat}
+
+// BuildScanManifestsCommand builds the scan-manifests command, which runs offline and is therefore not an eks command
+func BuildScanManifestsCommand() *cobra.Command {
+	scanManifestsCommand := &cobra.Command{
+		Use:                   "scan-manifests <path>",
+		Short:                 "Find hardcoded credentials in local Kubernetes manifests, Helm charts and Kustomize overlays",
+		Long:                  "scan-manifests reads Kubernetes manifests from a file or directory, renders the Helm charts and builds the Kustomize overlays it contains, and scans the resulting objects for hardcoded credentials like find-secrets does, without connecting to a cluster. It fails if it finds any secret. It doesn't check the IAM roles of service accounts, whose trust policies aren't in the manifests: use eks find-role-relationships on the cluster for that.",
+		Example:               "mkat scan-manifests ./deploy --values values-prod.yaml",
+		Args:                  cobra.ExactArgs(1),
+		DisableFlagsInUseLine: true,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			figure.NewFigure("mkat", "", true).Print()
+			println()
+			return validateOutputFormat(scanManifestsOutputFormats)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return doScanManifestsCommand(args[0])
+		},
+	}
+
+	scanManifestsCommand.Flags().StringVarP(&outputFormat, "output-format", "f", DefaultOutputFormat, "Output format. Supported formats: "+strings.Join(scanManifestsOutputFormats, ", "))
+	scanManifestsCommand.Flags().StringVarP(&outputFile, "output-file", "o", "", "Output file. If not specified, output will be printed to stdout.")
+	scanManifestsCommand.Flags().StringSliceVarP(&helmValuesFiles, "values", "", nil, "Values file to render Helm charts with, like helm template --values. Can be repeated")
+	scanManifestsCommand.Flags().StringArrayVarP(&helmSetValues, "set", "", nil, "Value to render Helm charts with, like helm template --set, e.g. image.tag=latest. Can be repeated")
+	scanManifestsCommand.Flags().BoolVarP(&showValues, "show-values", "", false, "Show the values of secrets in the output. By default, only their first characters and a hash are shown")
+	return scanManifestsCommand
+}
+
+func doScanManifestsCommand(path string) error {
+	loader := manifests.Loader{HelmValuesFiles: helmValuesFiles, HelmSetValues: helmSetValues}
+	sources, err := loader.Load(path)
+	if err != nil {
+		return err
+	}
+	secretInfos, err := manifests.FindSecrets(sources)
+	if err != nil {
+		return err
+	}
+
+	if err := writeManifestSecrets(secretInfos); err != nil {
+		return err
+	}
+	logSkippedDirectories(loader.Skipped)
+	// Fail so that pipelines break before deploying the secrets
+	if len(secretInfos) > 0 {
+		return fmt.Errorf("found %d hardcoded secrets in %s", len(secretInfos), path)
+	}
+	return nil
+}
+
+// logSkippedDirectories summarizes the charts and overlays that weren't scanned, as they may contain secrets too
+func logSkippedDirectories(skipped []*manifests.SkippedDirectory) {
+	if len(skipped) == 0 {
+		return
+	}
+	log.Printf("[WARN] %d Helm charts or Kustomize overlays could not be rendered and were not scanned:", len(skipped))
+	for _, directory := range skipped {
+		log.Printf("[WARN]   %s %s: %s", directory.Type, directory.Path, directory.Reason)
+	}
+}
+
+// writeManifestSecrets writes the secrets found in manifests in the requested format
+func writeManifestSecrets(secretInfos []*secrets.SecretInfo) error {
+	if isReportOutputFormat() {
+		return writeReport(report.NewSecretsReport(secretInfos, report.Cluster{CollectedAt: time.Now().UTC()}, nil, showValues))
+	}
+
+	if len(secretInfos) == 0 {
+		log.Println("No hardcoded secrets found in your manifests")
+		return nil
+	}
+	t := table.NewWriter()
+	t.AppendHeader(table.Row{"File", "Namespace", "Type", "Name", "Value", "Key Type"})
+	secretColor := color.New(color.BgRed, color.FgWhite, color.Bold)
+	for _, secret := range secretInfos {
+		value := secret.Value
+		if !showValues {
+			value = secrets.MaskValue(value)
+		}
+		t.AppendRow(table.Row{secret.File, secret.Namespace, secret.Type, secret.Name, secretColor.Sprint(value), secret.KeyType})
+	}
+	return writeOutput(t.Render() + "\n")
+}
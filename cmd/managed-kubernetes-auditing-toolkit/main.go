@@ -1,6 +1,10 @@
 package main
 
 import (
+	"context"
+	"os"
+	"os/signal"
+
 	"github.com/datadog/managed-kubernetes-auditing-toolkit/cmd/managed-kubernetes-auditing-toolkit/eks"
 	"github.com/spf13/cobra"
 )
@@ -17,7 +21,9 @@ var rootCmd = &cobra.Command{
 func init() {
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
 
+	eks.ToolVersion = BuildVersion
 	rootCmd.AddCommand(eks.BuildEksSubcommand())
+	rootCmd.AddCommand(eks.BuildScanManifestsCommand())
 	rootCmd.AddCommand(&cobra.Command{
 		Use:   "version",
 		Short: "Display the current CLI version",
@@ -28,5 +34,12 @@ func init() {
 }
 
 func main() {
-	rootCmd.Execute()
+	// Cancel in-flight API calls on Ctrl+C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	// Exit with a non-zero status on errors, e.g. when find-secrets finds secrets that aren't in the baseline
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		stop()
+		os.Exit(1)
+	}
 }
@@ -9,22 +9,22 @@ import (
 type rawStatement struct {
 	Effect    string                            `json:"Effect"`
 	Action    interface{}                       `json:"Action"`
+	NotAction interface{}                       `json:"NotAction"`
 	Principal interface{}                       `json:"Principal"`
 	Condition map[string]map[string]interface{} `json:"Condition"`
 }
 
 type rawPolicy struct {
-	Statement []rawStatement `json:"Statement"`
+	Statement json.RawMessage `json:"Statement"` // a single statement or an array of statements
 }
 
 func ParseRoleTrustPolicy(policy string) (*Policy, error) {
-	var rawPolicy rawPolicy
-	resultPolicy := Policy{}
-	err := json.Unmarshal([]byte(policy), &rawPolicy)
+	rawStatements, err := parseRawStatements(policy)
 	if err != nil {
 		return nil, fmt.Errorf("unable to parse role trust policy from JSON: %v", err)
 	}
-	for _, rawStatement := range rawPolicy.Statement {
+	resultPolicy := Policy{}
+	for _, rawStatement := range rawStatements {
 		statement, err := parsePolicyStatement(&rawStatement)
 		if err != nil {
 			return nil, err
@@ -35,6 +35,41 @@ func ParseRoleTrustPolicy(policy string) (*Policy, error) {
 	return &resultPolicy, nil
 }
 
+// ParseIdentityPolicy parses a policy attached to an IAM user, group or role. Its statements have no principal, they
+// apply to the identity the policy is attached to.
+func ParseIdentityPolicy(policy string) (*Policy, error) {
+	rawStatements, err := parseRawStatements(policy)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse identity policy from JSON: %v", err)
+	}
+	resultPolicy := Policy{}
+	for _, rawStatement := range rawStatements {
+		statement, err := parseIdentityPolicyStatement(&rawStatement)
+		if err != nil {
+			return nil, err
+		}
+		resultPolicy.Statements = append(resultPolicy.Statements, statement)
+	}
+
+	return &resultPolicy, nil
+}
+
+func parseRawStatements(policy string) ([]rawStatement, error) {
+	var rawPolicy rawPolicy
+	if err := json.Unmarshal([]byte(policy), &rawPolicy); err != nil {
+		return nil, err
+	}
+	if len(rawPolicy.Statement) == 0 {
+		return nil, nil
+	}
+	var rawStatements []rawStatement
+	if strings.HasPrefix(strings.TrimSpace(string(rawPolicy.Statement)), "{") {
+		rawStatements = make([]rawStatement, 1)
+		return rawStatements, json.Unmarshal(rawPolicy.Statement, &rawStatements[0])
+	}
+	return rawStatements, json.Unmarshal(rawPolicy.Statement, &rawStatements)
+}
+
 func parsePolicyStatement(rawStatement *rawStatement) (*PolicyStatement, error) {
 
 	var statement PolicyStatement
@@ -66,6 +101,32 @@ func parsePolicyStatement(rawStatement *rawStatement) (*PolicyStatement, error)
 
 }
 
+func parseIdentityPolicyStatement(rawStatement *rawStatement) (*PolicyStatement, error) {
+	effect, err := parseStatementEffect(rawStatement.Effect)
+	if err != nil {
+		return nil, err
+	}
+	statement := PolicyStatement{
+		Effect:            effect,
+		AllowedPrincipals: []*Principal{{Type: PrincipalTypeAny}},
+	}
+
+	if rawStatement.NotAction != nil {
+		statement.NotActions, err = ensureStringArray(rawStatement.NotAction)
+	} else {
+		statement.AllowedActions, err = ensureStringArray(rawStatement.Action)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	statement.Conditions, err = parseConditions(rawStatement.Condition)
+	if err != nil {
+		return nil, err
+	}
+	return &statement, nil
+}
+
 func parseConditions(rawConditions map[string]map[string]interface{}) ([]*Condition, error) {
 	result := []*Condition{}
 	for conditionOperator, conditionValues := range rawConditions {
@@ -232,3 +232,49 @@ func Test_parseConditions(t *testing.T) {
 		})
 	}
 }
+
+func TestIdentityPolicyParser(t *testing.T) {
+	scenarios := []struct {
+		PolicyFile     string
+		WantStatements []*PolicyStatement
+	}{
+		{
+			PolicyFile: "identity_power_user",
+			WantStatements: []*PolicyStatement{
+				{
+					Effect:            AuthorizationDecisionAllow,
+					AllowedPrincipals: []*Principal{{Type: PrincipalTypeAny}},
+					NotActions:        []string{"iam:*", "organizations:*", "account:*"},
+					Conditions:        []*Condition{},
+				},
+				{
+					Effect:            AuthorizationDecisionAllow,
+					AllowedPrincipals: []*Principal{{Type: PrincipalTypeAny}},
+					AllowedActions:    []string{"iam:CreateServiceLinkedRole", "iam:ListRoles"},
+					Conditions:        []*Condition{},
+				},
+			},
+		},
+		{
+			PolicyFile: "identity_single_statement",
+			WantStatements: []*PolicyStatement{
+				{
+					Effect:            AuthorizationDecisionAllow,
+					AllowedPrincipals: []*Principal{{Type: PrincipalTypeAny}},
+					AllowedActions:    []string{"s3:GetObject"},
+					Conditions: []*Condition{
+						{Key: "aws:PrincipalTag/team", Operator: "StringEquals", AllowedValues: []string{"payments"}},
+					},
+				},
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.PolicyFile, func(t *testing.T) {
+			policy, err := ParseIdentityPolicy(getTestPolicyFile(scenario.PolicyFile))
+			assert.Nil(t, err)
+			assert.Equal(t, scenario.WantStatements, policy.Statements)
+		})
+	}
+}
@@ -76,3 +76,28 @@ func TestPolicy(t *testing.T) {
 		})
 	}
 }
+
+func TestIdentityPolicyAuthorization(t *testing.T) {
+	policy, err := ParseIdentityPolicy(getTestPolicyFile("identity_power_user"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scenarios := []struct {
+		Action string
+		Expect AuthorizationResult
+	}{
+		{Action: "s3:GetObject", Expect: AuthorizationResultAllow},
+		{Action: "iam:CreateAccessKey", Expect: AuthorizationResultDeny},
+		{Action: "iam:ListRoles", Expect: AuthorizationResultAllow},
+		{Action: "organizations:LeaveOrganization", Expect: AuthorizationResultDeny},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.Action, func(t *testing.T) {
+			result := *policy.Authorize(&AuthorizationContext{Action: scenario.Action, Principal: &Principal{PrincipalTypeAWS, "arn:aws:iam::111122223333:user/foo"}})
+			if result != scenario.Expect {
+				t.Errorf("Expected %v, got %v", scenario.Expect, result)
+			}
+		})
+	}
+}
@@ -25,6 +25,7 @@ type PolicyStatement struct {
 	Effect            AuthorizationDecision
 	AllowedPrincipals []*Principal
 	AllowedActions    []string
+	NotActions        []string // the statement applies to all actions but these ones, instead of AllowedActions
 	Conditions        []*Condition
 }
 
@@ -65,9 +66,16 @@ func (m *PolicyStatement) conditionsMatch(context *AuthorizationContext) bool {
 }
 
 func (m *PolicyStatement) actionMatches(action string) bool {
+	if len(m.NotActions) > 0 {
+		return !matchesAnyAction(m.NotActions, action)
+	}
+	return matchesAnyAction(m.AllowedActions, action)
+}
+
+func matchesAnyAction(patterns []string, action string) bool {
 	action = strings.ToLower(action)
-	for _, allowedAction := range m.AllowedActions {
-		if match, err := filepath.Match(strings.ToLower(allowedAction), action); match && err == nil {
+	for _, pattern := range patterns {
+		if match, err := filepath.Match(strings.ToLower(pattern), action); match && err == nil {
 			return true
 		}
 	}
@@ -0,0 +1,233 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// EKSClusterResolutionStrategy describes how the name of the current EKS cluster was determined
+type EKSClusterResolutionStrategy string
+
+const (
+	EKSClusterResolutionNativeAuth    EKSClusterResolutionStrategy = "native authentication"
+	EKSClusterResolutionExecArguments EKSClusterResolutionStrategy = "KubeConfig exec plugin arguments"
+	EKSClusterResolutionExecEnv       EKSClusterResolutionStrategy = "KubeConfig exec plugin environment"
+	EKSClusterResolutionKubeConfigArn EKSClusterResolutionStrategy = "KubeConfig cluster ARN"
+	EKSClusterResolutionEndpoint      EKSClusterResolutionStrategy = "API server endpoint lookup"
+)
+
+// EKSClusterResolution is the result of a successful cluster name resolution
+type EKSClusterResolution struct {
+	Name     string
+	Strategy EKSClusterResolutionStrategy
+}
+
+// Hostname suffixes of public EKS API server endpoints, including the AWS China partition
+// GovCloud endpoints use the standard suffix, e.g. <id>.gr7.us-gov-west-1.eks.amazonaws.com
+var eksHostnameSuffixes = []string{".eks.amazonaws.com", ".eks.amazonaws.com.cn"}
+
+// e.g. 0123456789ABCDEF.gr7.eu-west-1.eks.amazonaws.com
+var eksHostnameRegionPattern = regexp.MustCompile(`\.([a-z]{2}(-gov|-iso[a-z]?)?-[a-z]+-[0-9]+)\.eks\.amazonaws\.com(\.cn)?$`)
+
+// Environment variables that commonly hold the cluster name in wrapper scripts used as exec plugins
+var clusterNameEnvironmentVariables = []string{"EKS_CLUSTER_NAME", "AWS_EKS_CLUSTER_NAME", "CLUSTER_NAME"}
+
+// ResolveEKSCluster determines the name of the EKS cluster in the current context, trying increasingly expensive
+// strategies. The last one lists the EKS clusters of the account and matches their endpoint against the API server
+// URL, and is only attempted when awsConfig is not nil.
+// It returns nil if the cluster name could not be determined.
+func ResolveEKSCluster(awsConfig *aws.Config) *EKSClusterResolution {
+	if nativeAuthEKSClusterName != "" {
+		return &EKSClusterResolution{Name: nativeAuthEKSClusterName, Strategy: EKSClusterResolutionNativeAuth}
+	}
+
+	config := getConfig()
+	if config.ExecProvider != nil {
+		if name := clusterNameFromExecArguments(config.ExecProvider); name != "" {
+			return &EKSClusterResolution{Name: name, Strategy: EKSClusterResolutionExecArguments}
+		}
+		if name := clusterNameFromExecEnvironment(config.ExecProvider); name != "" {
+			return &EKSClusterResolution{Name: name, Strategy: EKSClusterResolutionExecEnv}
+		}
+	}
+
+	if clusterArn := getCurrentKubeConfigClusterName(); clusterArn != "" {
+		if name := clusterNameFromArn(clusterArn); name != "" {
+			return &EKSClusterResolution{Name: name, Strategy: EKSClusterResolutionKubeConfigArn}
+		}
+	}
+
+	if awsConfig != nil {
+		if name, err := findEKSClusterByEndpoint(context.Background(), awsConfig, config.Host); err == nil && name != "" {
+			return &EKSClusterResolution{Name: name, Strategy: EKSClusterResolutionEndpoint}
+		}
+	}
+
+	return nil
+}
+
+// clusterNameFromExecArguments extracts the cluster name from the arguments of the KubeConfig exec plugin. It
+// supports "aws eks get-token --cluster-name", "aws-iam-authenticator token -i" and "--cluster-id", as well as
+// wrappers such as "aws-vault exec profile -- aws eks get-token ..."
+func clusterNameFromExecArguments(execConfig *clientcmdapi.ExecConfig) string {
+	usesAuthenticator := strings.Contains(filepath.Base(execConfig.Command), "aws-iam-authenticator")
+	for _, arg := range execConfig.Args {
+		if strings.Contains(arg, "aws-iam-authenticator") {
+			usesAuthenticator = true
+		}
+	}
+
+	flags := []string{"--cluster-name", "--cluster-id"}
+	if usesAuthenticator {
+		flags = append(flags, "-i")
+	}
+
+	for i, arg := range execConfig.Args {
+		for _, flag := range flags {
+			if arg == flag && i+1 < len(execConfig.Args) {
+				return execConfig.Args[i+1]
+			}
+			if strings.HasPrefix(arg, flag+"=") {
+				return strings.TrimPrefix(arg, flag+"=")
+			}
+		}
+	}
+	return ""
+}
+
+func clusterNameFromExecEnvironment(execConfig *clientcmdapi.ExecConfig) string {
+	for _, variable := range clusterNameEnvironmentVariables {
+		for _, env := range execConfig.Env {
+			if env.Name == variable && env.Value != "" {
+				return env.Value
+			}
+		}
+	}
+	return ""
+}
+
+// clusterNameFromArn returns the name of an EKS cluster from its ARN, in any partition
+func clusterNameFromArn(rawArn string) string {
+	parsedArn, err := arn.Parse(rawArn)
+	if err != nil || parsedArn.Service != "eks" || !strings.HasPrefix(parsedArn.Resource, "cluster/") {
+		return ""
+	}
+	return strings.TrimPrefix(parsedArn.Resource, "cluster/")
+}
+
+// getCurrentKubeConfigClusterName returns the name of the cluster of the current KubeConfig context. When the
+// KubeConfig was generated by "aws eks update-kubeconfig", this is the ARN of the EKS cluster.
+func getCurrentKubeConfigClusterName() string {
+	kubeConfigPath := getKubeConfigPath()
+	if kubeConfigPath == "" {
+		return ""
+	}
+	rawConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeConfigPath},
+		&clientcmd.ConfigOverrides{},
+	).RawConfig()
+	if err != nil {
+		return ""
+	}
+	currentContext, ok := rawConfig.Contexts[rawConfig.CurrentContext]
+	if !ok {
+		return ""
+	}
+	if clusterNameFromArn(currentContext.Cluster) == "" && clusterNameFromArn(rawConfig.CurrentContext) != "" {
+		return rawConfig.CurrentContext
+	}
+	return currentContext.Cluster
+}
+
+// findEKSClusterByEndpoint lists the EKS clusters of the account and returns the one whose endpoint is apiServerURL
+func findEKSClusterByEndpoint(ctx context.Context, awsConfig *aws.Config, apiServerURL string) (string, error) {
+	eksClient := eks.NewFromConfig(*awsConfig, func(options *eks.Options) {
+		// If the API server hostname tells us the region of the cluster, use it
+		if region := regionFromEKSHostname(apiServerURL); region != "" {
+			options.Region = region
+		}
+	})
+
+	paginator := eks.NewListClustersPaginator(eksClient, &eks.ListClustersInput{})
+	for paginator.HasMorePages() {
+		clusters, err := paginator.NextPage(ctx)
+		if err != nil {
+			return "", fmt.Errorf("unable to list EKS clusters: %v", err)
+		}
+		for i := range clusters.Clusters {
+			clusterName := clusters.Clusters[i]
+			clusterInfo, err := eksClient.DescribeCluster(ctx, &eks.DescribeClusterInput{Name: &clusterName})
+			if err != nil || clusterInfo.Cluster.Endpoint == nil {
+				continue
+			}
+			if endpointsMatch(*clusterInfo.Cluster.Endpoint, apiServerURL) {
+				return clusterName, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+func endpointsMatch(endpoint1 string, endpoint2 string) bool {
+	return normalizeEndpoint(endpoint1) != "" && normalizeEndpoint(endpoint1) == normalizeEndpoint(endpoint2)
+}
+
+func normalizeEndpoint(endpoint string) string {
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	parsedUrl, err := url.Parse(endpoint)
+	if err != nil {
+		return ""
+	}
+	host := strings.ToLower(parsedUrl.Hostname())
+	if port := parsedUrl.Port(); port != "" && port != "443" {
+		host = host + ":" + port
+	}
+	return host
+}
+
+func isEKSHostname(host string) bool {
+	host = strings.ToLower(host)
+	for _, suffix := range eksHostnameSuffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func regionFromEKSHostname(apiServerURL string) string {
+	match := eksHostnameRegionPattern.FindStringSubmatch(normalizeEndpoint(apiServerURL))
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// usesEKSExecPlugin determines if the KubeConfig exec plugin retrieves EKS authentication tokens
+func usesEKSExecPlugin(execConfig *clientcmdapi.ExecConfig) bool {
+	if execConfig == nil {
+		return false
+	}
+	commandLine := append([]string{filepath.Base(execConfig.Command)}, execConfig.Args...)
+	for i, arg := range commandLine {
+		if strings.Contains(arg, "aws-iam-authenticator") {
+			return true
+		}
+		if arg == "eks" && i+1 < len(commandLine) && commandLine[i+1] == "get-token" {
+			return true
+		}
+	}
+	return false
+}
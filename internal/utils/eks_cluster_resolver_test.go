@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestExtractsClusterNameFromExecArguments(t *testing.T) {
+	scenarios := []struct {
+		Name         string
+		ExecConfig   *clientcmdapi.ExecConfig
+		ExpectedName string
+	}{
+		{
+			Name:         "aws eks get-token",
+			ExecConfig:   &clientcmdapi.ExecConfig{Command: "aws", Args: []string{"--region", "us-east-1", "eks", "get-token", "--cluster-name", "my-cluster"}},
+			ExpectedName: "my-cluster",
+		},
+		{
+			Name:         "aws eks get-token with an equal sign",
+			ExecConfig:   &clientcmdapi.ExecConfig{Command: "aws", Args: []string{"eks", "get-token", "--cluster-name=my-cluster"}},
+			ExpectedName: "my-cluster",
+		},
+		{
+			Name:         "aws eks get-token with a cluster ID",
+			ExecConfig:   &clientcmdapi.ExecConfig{Command: "/usr/local/bin/aws", Args: []string{"eks", "get-token", "--cluster-id", "my-cluster"}},
+			ExpectedName: "my-cluster",
+		},
+		{
+			Name:         "aws-iam-authenticator with -i",
+			ExecConfig:   &clientcmdapi.ExecConfig{Command: "aws-iam-authenticator", Args: []string{"token", "-i", "my-cluster"}},
+			ExpectedName: "my-cluster",
+		},
+		{
+			Name:         "aws-iam-authenticator with --cluster-id",
+			ExecConfig:   &clientcmdapi.ExecConfig{Command: "/opt/bin/aws-iam-authenticator", Args: []string{"token", "--cluster-id", "my-cluster"}},
+			ExpectedName: "my-cluster",
+		},
+		{
+			Name:         "aws-iam-authenticator wrapped in aws-vault",
+			ExecConfig:   &clientcmdapi.ExecConfig{Command: "aws-vault", Args: []string{"exec", "prod", "--", "aws-iam-authenticator", "token", "-i", "my-cluster"}},
+			ExpectedName: "my-cluster",
+		},
+		{
+			Name:         "aws eks get-token wrapped in a script",
+			ExecConfig:   &clientcmdapi.ExecConfig{Command: "./get-token.sh", Args: []string{"--profile", "prod", "--cluster-name", "my-cluster"}},
+			ExpectedName: "my-cluster",
+		},
+		{
+			Name:         "-i is ambiguous outside of aws-iam-authenticator",
+			ExecConfig:   &clientcmdapi.ExecConfig{Command: "./get-token.sh", Args: []string{"-i", "my-cluster"}},
+			ExpectedName: "",
+		},
+		{
+			Name:         "flag without a value",
+			ExecConfig:   &clientcmdapi.ExecConfig{Command: "aws", Args: []string{"eks", "get-token", "--cluster-name"}},
+			ExpectedName: "",
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.Name, func(t *testing.T) {
+			assert.Equal(t, scenario.ExpectedName, clusterNameFromExecArguments(scenario.ExecConfig))
+		})
+	}
+}
+
+func TestExtractsClusterNameFromExecEnvironment(t *testing.T) {
+	execConfig := &clientcmdapi.ExecConfig{Command: "./get-token.sh", Env: []clientcmdapi.ExecEnvVar{
+		{Name: "AWS_PROFILE", Value: "prod"},
+		{Name: "EKS_CLUSTER_NAME", Value: "my-cluster"},
+	}}
+	assert.Equal(t, "my-cluster", clusterNameFromExecEnvironment(execConfig))
+	assert.Equal(t, "", clusterNameFromExecEnvironment(&clientcmdapi.ExecConfig{Command: "./get-token.sh"}))
+}
+
+func TestExtractsClusterNameFromArn(t *testing.T) {
+	scenarios := []struct {
+		Arn          string
+		ExpectedName string
+	}{
+		{"arn:aws:eks:us-east-1:012345678901:cluster/my-cluster", "my-cluster"},
+		{"arn:aws-cn:eks:cn-north-1:012345678901:cluster/my-cluster", "my-cluster"},
+		{"arn:aws-us-gov:eks:us-gov-west-1:012345678901:cluster/my-cluster", "my-cluster"},
+		{"arn:aws:iam::012345678901:role/my-cluster", ""},
+		{"my-cluster", ""},
+		{"", ""},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.Arn, func(t *testing.T) {
+			assert.Equal(t, scenario.ExpectedName, clusterNameFromArn(scenario.Arn))
+		})
+	}
+}
+
+func TestRecognizesEKSHostnames(t *testing.T) {
+	assert.True(t, isEKSHostname("0123456789ABCDEF0123456789ABCDEF.gr7.us-east-1.eks.amazonaws.com"))
+	assert.True(t, isEKSHostname("0123456789ABCDEF0123456789ABCDEF.yl4.cn-north-1.eks.amazonaws.com.cn"))
+	assert.True(t, isEKSHostname("0123456789ABCDEF0123456789ABCDEF.gr7.us-gov-west-1.eks.amazonaws.com"))
+	assert.False(t, isEKSHostname("kubernetes.internal.example.com"))
+	assert.False(t, isEKSHostname("eks.amazonaws.com.example.com"))
+}
+
+func TestExtractsRegionFromEKSHostname(t *testing.T) {
+	assert.Equal(t, "us-east-1", regionFromEKSHostname("https://0123456789ABCDEF.gr7.us-east-1.eks.amazonaws.com"))
+	assert.Equal(t, "cn-north-1", regionFromEKSHostname("https://0123456789ABCDEF.yl4.cn-north-1.eks.amazonaws.com.cn"))
+	assert.Equal(t, "us-gov-west-1", regionFromEKSHostname("https://0123456789ABCDEF.gr7.us-gov-west-1.eks.amazonaws.com"))
+	assert.Equal(t, "", regionFromEKSHostname("https://kubernetes.internal.example.com"))
+}
+
+func TestMatchesEndpoints(t *testing.T) {
+	assert.True(t, endpointsMatch("https://ABCDEF.gr7.us-east-1.eks.amazonaws.com", "https://abcdef.gr7.us-east-1.eks.amazonaws.com:443/"))
+	assert.True(t, endpointsMatch("https://ABCDEF.gr7.us-east-1.eks.amazonaws.com", "abcdef.gr7.us-east-1.eks.amazonaws.com"))
+	assert.False(t, endpointsMatch("https://ABCDEF.gr7.us-east-1.eks.amazonaws.com", "https://ABCDEF.gr7.us-east-1.eks.amazonaws.com:8443"))
+	assert.False(t, endpointsMatch("https://ABCDEF.gr7.us-east-1.eks.amazonaws.com", "https://FEDCBA.gr7.us-east-1.eks.amazonaws.com"))
+	assert.False(t, endpointsMatch("", ""))
+}
+
+func TestRecognizesEKSExecPlugins(t *testing.T) {
+	assert.True(t, usesEKSExecPlugin(&clientcmdapi.ExecConfig{Command: "aws", Args: []string{"--region", "us-east-1", "eks", "get-token", "--cluster-name", "foo"}}))
+	assert.True(t, usesEKSExecPlugin(&clientcmdapi.ExecConfig{Command: "/usr/bin/aws-iam-authenticator", Args: []string{"token", "-i", "foo"}}))
+	assert.True(t, usesEKSExecPlugin(&clientcmdapi.ExecConfig{Command: "aws-vault", Args: []string{"exec", "prod", "--", "aws", "eks", "get-token", "--cluster-name", "foo"}}))
+	assert.False(t, usesEKSExecPlugin(&clientcmdapi.ExecConfig{Command: "gke-gcloud-auth-plugin"}))
+	assert.False(t, usesEKSExecPlugin(nil))
+}
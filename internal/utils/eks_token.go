@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// EKS authentication tokens are pre-signed STS GetCallerIdentity URLs, prefixed with this value
+	// c.f. https://github.com/kubernetes-sigs/aws-iam-authenticator#api-authorization-from-outside-a-cluster
+	eksTokenPrefix = "k8s-aws-v1."
+
+	// Header binding the pre-signed URL to a specific EKS cluster
+	eksClusterIDHeader = "x-k8s-aws-id"
+
+	// The EKS API server accepts tokens for 15 minutes after they've been signed
+	eksTokenValidity = 15 * time.Minute
+
+	// We generate a new token slightly before the current one expires, to account for clock skew
+	eksTokenRefreshMargin = 1 * time.Minute
+)
+
+// EKSToken is a bearer token that can be used to authenticate against the API server of an EKS cluster
+type EKSToken struct {
+	Token      string
+	Expiration time.Time
+}
+
+// GenerateEKSToken generates an authentication token for an EKS cluster, in the same way as "aws eks get-token" does
+func GenerateEKSToken(ctx context.Context, awsConfig *aws.Config, clusterName string) (*EKSToken, error) {
+	presignClient := sts.NewPresignClient(sts.NewFromConfig(*awsConfig))
+	signedAt := time.Now()
+	presignedRequest, err := presignClient.PresignGetCallerIdentity(ctx, &sts.GetCallerIdentityInput{}, func(options *sts.PresignOptions) {
+		options.ClientOptions = append(options.ClientOptions, func(options *sts.Options) {
+			options.APIOptions = append(options.APIOptions, addEKSTokenParameters(clusterName))
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to pre-sign STS GetCallerIdentity request: %v", err)
+	}
+
+	return &EKSToken{
+		Token:      eksTokenPrefix + base64.RawURLEncoding.EncodeToString([]byte(presignedRequest.URL)),
+		Expiration: signedAt.Add(eksTokenValidity),
+	}, nil
+}
+
+// addEKSTokenParameters adds the cluster ID header to the pre-signed request, so that it's part of the signature
+func addEKSTokenParameters(clusterName string) func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		return stack.Build.Add(middleware.BuildMiddlewareFunc("AddEKSTokenParameters", func(
+			ctx context.Context, in middleware.BuildInput, next middleware.BuildHandler,
+		) (middleware.BuildOutput, middleware.Metadata, error) {
+			if request, ok := in.Request.(*smithyhttp.Request); ok {
+				request.Header.Add(eksClusterIDHeader, clusterName)
+				query := request.URL.Query()
+				query.Set("X-Amz-Expires", "60")
+				request.URL.RawQuery = query.Encode()
+			}
+			return next.HandleBuild(ctx, in)
+		}), middleware.After)
+	}
+}
+
+// eksTokenSource caches an EKS token and transparently generates a new one when it's about to expire
+type eksTokenSource struct {
+	generate func(ctx context.Context) (*EKSToken, error)
+	mutex    sync.Mutex
+	current  *EKSToken
+}
+
+func newEKSTokenSource(awsConfig *aws.Config, clusterName string) *eksTokenSource {
+	return &eksTokenSource{
+		generate: func(ctx context.Context) (*EKSToken, error) {
+			return GenerateEKSToken(ctx, awsConfig, clusterName)
+		},
+	}
+}
+
+func (m *eksTokenSource) Token(ctx context.Context) (string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.current == nil || time.Now().Add(eksTokenRefreshMargin).After(m.current.Expiration) {
+		token, err := m.generate(ctx)
+		if err != nil {
+			return "", err
+		}
+		m.current = token
+	}
+	return m.current.Token, nil
+}
+
+// eksTokenRoundTripper injects an up-to-date EKS token in every request sent to the API server
+type eksTokenRoundTripper struct {
+	source *eksTokenSource
+	next   http.RoundTripper
+}
+
+func (m *eksTokenRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	token, err := m.source.Token(request.Context())
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate EKS authentication token: %v", err)
+	}
+	authenticatedRequest := request.Clone(request.Context())
+	authenticatedRequest.Header.Set("Authorization", "Bearer "+token)
+	return m.next.RoundTrip(authenticatedRequest)
+}
+
+// EKSRestConfig builds a Kubernetes client configuration for an EKS cluster from its name only, without
+// relying on a KubeConfig file or on the AWS CLI being installed
+func EKSRestConfig(ctx context.Context, awsConfig *aws.Config, clusterName string) (*rest.Config, error) {
+	clusterInfo, err := eks.NewFromConfig(*awsConfig).DescribeCluster(ctx, &eks.DescribeClusterInput{
+		Name: &clusterName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to describe EKS cluster %s: %v", clusterName, err)
+	}
+	cluster := clusterInfo.Cluster
+	if cluster.Endpoint == nil || cluster.CertificateAuthority == nil || cluster.CertificateAuthority.Data == nil {
+		return nil, fmt.Errorf("EKS cluster %s has no API server endpoint or certificate authority (status: %s)", clusterName, cluster.Status)
+	}
+	certificateAuthority, err := base64.StdEncoding.DecodeString(*cluster.CertificateAuthority.Data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode the certificate authority of EKS cluster %s: %v", clusterName, err)
+	}
+
+	// Generate a first token right away, so that authentication issues surface early
+	tokenSource := newEKSTokenSource(awsConfig, clusterName)
+	if _, err := tokenSource.Token(ctx); err != nil {
+		return nil, err
+	}
+
+	return &rest.Config{
+		Host:            *cluster.Endpoint,
+		TLSClientConfig: rest.TLSClientConfig{CAData: certificateAuthority},
+		WrapTransport: func(roundTripper http.RoundTripper) http.RoundTripper {
+			return &eksTokenRoundTripper{source: tokenSource, next: roundTripper}
+		},
+	}, nil
+}
+
+// K8sClientForEKSCluster returns a Kubernetes client for an arbitrary EKS cluster of the current AWS account and region
+func K8sClientForEKSCluster(ctx context.Context, awsConfig *aws.Config, clusterName string) (*kubernetes.Clientset, error) {
+	config, err := EKSRestConfig(ctx, awsConfig, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
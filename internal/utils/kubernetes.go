@@ -1,11 +1,11 @@
 package utils
 
 import (
+	"context"
 	"log"
 	"net/url"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -31,11 +31,34 @@ func getKubeConfigPath() string {
 	return ""
 }
 
+// Name of the EKS cluster to authenticate against without a KubeConfig, see UseEKSNativeAuthentication
+var nativeAuthEKSClusterName string
+
+var cachedConfig *rest.Config
+
+// UseEKSNativeAuthentication makes all subsequent Kubernetes clients authenticate against the specified EKS
+// cluster by generating tokens in-process, instead of using the current KubeConfig context
+func UseEKSNativeAuthentication(clusterName string) {
+	nativeAuthEKSClusterName = clusterName
+	cachedConfig = nil
+}
+
 func getConfig() *rest.Config {
-	config, err := clientcmd.BuildConfigFromFlags("", getKubeConfigPath())
+	if cachedConfig != nil {
+		return cachedConfig
+	}
+
+	var config *rest.Config
+	var err error
+	if nativeAuthEKSClusterName != "" {
+		config, err = EKSRestConfig(context.Background(), AWSClient(), nativeAuthEKSClusterName)
+	} else {
+		config, err = clientcmd.BuildConfigFromFlags("", getKubeConfigPath())
+	}
 	if err != nil {
 		log.Fatalf("unable to build kube config: %v", err)
 	}
+	cachedConfig = config
 	return config
 }
 
@@ -47,28 +70,15 @@ func K8sClient() *kubernetes.Clientset {
 	return k8sClient
 }
 
-// IsEKS determines if the cluster in the current context does appear to be an EKS cluster
+// IsEKS determines if the cluster in the current context does appear to be an EKS cluster. Clusters with a private
+// or custom DNS endpoint are recognized through the KubeConfig exec plugin or cluster ARN.
 func IsEKS() bool {
-	parsedUrl, err := url.Parse(getConfig().Host)
-	if err != nil {
-		return false
-	}
-	return strings.HasSuffix(parsedUrl.Host, ".eks.amazonaws.com")
-}
-
-func GetEKSClusterName() string {
-	// Most of (if not all) the time, the KubeConfig file generated by "aws eks update-kubeconfig" will have an
-	// ExecProvider section that runs "aws eks get-token <...> --cluster-name foo"
-	// We parse it and extract the cluster name from there
-
-	execProvider := getConfig().ExecProvider
-	if execProvider == nil || execProvider.Command != "aws" {
-		return ""
+	if nativeAuthEKSClusterName != "" {
+		return true // the API server endpoint was retrieved from the EKS API
 	}
-	for i, arg := range execProvider.Args {
-		if arg == "--cluster-name" && i+1 < len(execProvider.Args) {
-			return execProvider.Args[i+1]
-		}
+	config := getConfig()
+	if parsedUrl, err := url.Parse(config.Host); err == nil && isEKSHostname(parsedUrl.Hostname()) {
+		return true
 	}
-	return ""
+	return usesEKSExecPlugin(config.ExecProvider) || clusterNameFromArn(getCurrentKubeConfigClusterName()) != ""
 }
@@ -0,0 +1,188 @@
+package asff
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/secrets"
+)
+
+const (
+	SchemaVersion = "2018-10-08"
+
+	productName = "mkat"
+	companyName = "Datadog"
+
+	// Maximum lengths enforced by BatchImportFindings
+	maxTitleLength       = 256
+	maxDescriptionLength = 1024
+	maxRemediationLength = 512
+
+	defaultFindingType = "Software and Configuration Checks/AWS Security Best Practices"
+	// ASFF resource type for resources Security Hub doesn't know about, such as Kubernetes resources
+	resourceTypeOther = "Other"
+)
+
+// Finding is a finding in the AWS Security Finding Format. Only the attributes MKAT uses are modeled.
+// c.f. https://docs.aws.amazon.com/securityhub/latest/userguide/securityhub-findings-format-syntax.html
+type Finding struct {
+	SchemaVersion         string                 `json:"SchemaVersion"`
+	Id                    string                 `json:"Id"`
+	ProductArn            string                 `json:"ProductArn"`
+	ProductName           string                 `json:"ProductName"`
+	CompanyName           string                 `json:"CompanyName"`
+	GeneratorId           string                 `json:"GeneratorId"`
+	AwsAccountId          string                 `json:"AwsAccountId"`
+	Region                string                 `json:"Region"`
+	Types                 []string               `json:"Types"`
+	CreatedAt             string                 `json:"CreatedAt"`
+	UpdatedAt             string                 `json:"UpdatedAt"`
+	Severity              Severity               `json:"Severity"`
+	Title                 string                 `json:"Title"`
+	Description           string                 `json:"Description"`
+	Remediation           *Remediation           `json:"Remediation,omitempty"`
+	ProductFields         map[string]string      `json:"ProductFields"`
+	Resources             []*Resource            `json:"Resources"`
+	RecordState           string                 `json:"RecordState"`
+	FindingProviderFields *FindingProviderFields `json:"FindingProviderFields,omitempty"`
+}
+
+type Severity struct {
+	Label    string `json:"Label"`
+	Original string `json:"Original"`
+}
+
+type FindingProviderFields struct {
+	Severity Severity `json:"Severity"`
+	Types    []string `json:"Types"`
+}
+
+type Remediation struct {
+	Recommendation Recommendation `json:"Recommendation"`
+}
+
+type Recommendation struct {
+	Text string `json:"Text"`
+}
+
+type Resource struct {
+	Type      string           `json:"Type"`
+	Id        string           `json:"Id"`
+	Partition string           `json:"Partition"`
+	Region    string           `json:"Region"`
+	Details   *ResourceDetails `json:"Details,omitempty"`
+}
+
+type ResourceDetails struct {
+	Other map[string]string `json:"Other,omitempty"`
+}
+
+var severityLabels = map[findings.Severity]string{
+	findings.SeverityInformational: "INFORMATIONAL",
+	findings.SeverityLow:           "LOW",
+	findings.SeverityMedium:        "MEDIUM",
+	findings.SeverityHigh:          "HIGH",
+	findings.SeverityCritical:      "CRITICAL",
+}
+
+// Finding type of hardcoded credentials that have no more specific type
+const hardcodedCredentialsFindingType = "Sensitive Data Identifications/Security"
+
+// Finding types of rules that aren't about misconfigurations
+var findingTypes = map[string]string{
+	secrets.RuleIDHardcodedAWSCredentials:          "Sensitive Data Identifications/Security/AWS Credentials",
+	secrets.RuleIDHardcodedAWSTemporaryCredentials: "Sensitive Data Identifications/Security/AWS Credentials",
+	secrets.RuleIDHardcodedDatabaseURL:             "Sensitive Data Identifications/Passwords",
+}
+
+// ConvertFindings converts findings about a cluster to ASFF. The account and region of findings are the ones of the
+// cluster, as identified by its ARN.
+func ConvertFindings(mkatFindings []*findings.Finding, clusterArn string, toolVersion string, now time.Time) ([]*Finding, error) {
+	parsedClusterArn, err := arn.Parse(clusterArn)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse cluster ARN %s: %v", clusterArn, err)
+	}
+	timestamp := now.UTC().Format(time.RFC3339)
+
+	result := make([]*Finding, 0, len(mkatFindings))
+	for _, mkatFinding := range mkatFindings {
+		findingType := defaultFindingType
+		if specificType, found := findingTypes[mkatFinding.RuleID]; found {
+			findingType = specificType
+		} else if strings.HasPrefix(mkatFinding.RuleID, secrets.RuleIDPrefix) {
+			findingType = hardcodedCredentialsFindingType
+		}
+		severity := Severity{Label: severityLabels[mkatFinding.Severity], Original: string(mkatFinding.Severity)}
+		finding := &Finding{
+			SchemaVersion: SchemaVersion,
+			// Security Hub updates findings with the same ID, so that findings are deduplicated across runs
+			Id:           clusterArn + "/" + mkatFinding.Fingerprint(),
+			ProductArn:   fmt.Sprintf("arn:%s:securityhub:%s:%s:product/%s/default", parsedClusterArn.Partition, parsedClusterArn.Region, parsedClusterArn.AccountID, parsedClusterArn.AccountID),
+			ProductName:  productName,
+			CompanyName:  companyName,
+			GeneratorId:  productName + "/" + mkatFinding.RuleID,
+			AwsAccountId: parsedClusterArn.AccountID,
+			Region:       parsedClusterArn.Region,
+			Types:        []string{findingType},
+			CreatedAt:    timestamp,
+			UpdatedAt:    timestamp,
+			Severity:     severity,
+			Title:        truncate(mkatFinding.Title, maxTitleLength),
+			Description:  truncate(mkatFinding.Description, maxDescriptionLength),
+			ProductFields: map[string]string{
+				"mkat/RuleId":  mkatFinding.RuleID,
+				"mkat/Version": toolVersion,
+			},
+			Resources:             convertResources(mkatFinding.Resource, parsedClusterArn),
+			RecordState:           "ACTIVE",
+			FindingProviderFields: &FindingProviderFields{Severity: severity, Types: []string{findingType}},
+		}
+		if mkatFinding.Remediation != "" {
+			finding.Remediation = &Remediation{Recommendation: Recommendation{Text: truncate(mkatFinding.Remediation, maxRemediationLength)}}
+		}
+		result = append(result, finding)
+	}
+	return result, nil
+}
+
+// convertResources returns the resource of a finding, followed by the cluster when it's a Kubernetes resource
+func convertResources(resource findings.Resource, clusterArn arn.ARN) []*Resource {
+	clusterResource := &Resource{Type: string(findings.ResourceTypeEKSCluster), Id: clusterArn.String(), Partition: clusterArn.Partition, Region: clusterArn.Region}
+	switch resource.Type {
+	case findings.ResourceTypeEKSCluster:
+		return []*Resource{clusterResource}
+	case findings.ResourceTypeIAMRole:
+		// IAM is a global service, but Security Hub expects the region of the finding
+		return []*Resource{{Type: string(resource.Type), Id: resource.ID, Partition: clusterArn.Partition, Region: clusterArn.Region}}
+	default:
+		details := map[string]string{"KubernetesResourceType": string(resource.Type), "Namespace": resource.Namespace}
+		if resource.Type != findings.ResourceTypeKubernetesNamespace {
+			details["Name"] = resource.Name
+		}
+		workload := &Resource{Type: resourceTypeOther, Id: resource.ID, Partition: clusterArn.Partition, Region: clusterArn.Region, Details: &ResourceDetails{Other: details}}
+		return []*Resource{workload, clusterResource}
+	}
+}
+
+func truncate(value string, maxLength int) string {
+	if len(value) <= maxLength {
+		return value
+	}
+	return strings.ToValidUTF8(value[:maxLength-3], "") + "..."
+}
+
+// Marshal serializes findings to a JSON array, the format expected by BatchImportFindings
+func Marshal(asffFindings []*Finding) ([]byte, error) {
+	if asffFindings == nil {
+		asffFindings = []*Finding{}
+	}
+	output, err := json.MarshalIndent(asffFindings, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialize ASFF findings: %v", err)
+	}
+	return append(output, '\n'), nil
+}
@@ -0,0 +1,102 @@
+package asff
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+	"github.com/stretchr/testify/assert"
+)
+
+const testClusterArn = "arn:aws:eks:eu-west-1:123456789012:cluster/my-cluster"
+
+func TestConvertFindings(t *testing.T) {
+	type Scenario struct {
+		Name              string
+		Finding           *findings.Finding
+		ExpectedTypes     []string
+		ExpectedResources []*Resource
+	}
+	clusterResource := &Resource{Type: "AwsEksCluster", Id: testClusterArn, Partition: "aws", Region: "eu-west-1"}
+	scenarios := []Scenario{
+		{
+			Name: "cluster finding",
+			Finding: &findings.Finding{
+				RuleID:   "eks-private-endpoint-disabled",
+				Severity: findings.SeverityMedium,
+				Resource: findings.Resource{Type: findings.ResourceTypeEKSCluster, ID: testClusterArn, Name: "my-cluster"},
+			},
+			ExpectedTypes:     []string{"Software and Configuration Checks/AWS Security Best Practices"},
+			ExpectedResources: []*Resource{clusterResource},
+		},
+		{
+			Name: "IAM role finding",
+			Finding: &findings.Finding{
+				RuleID:   "eks-irsa-role-trusts-any-service-account",
+				Severity: findings.SeverityHigh,
+				Resource: findings.IAMRoleResource("arn:aws:iam::123456789012:role/my-role"),
+			},
+			ExpectedTypes: []string{"Software and Configuration Checks/AWS Security Best Practices"},
+			ExpectedResources: []*Resource{
+				{Type: "AwsIamRole", Id: "arn:aws:iam::123456789012:role/my-role", Partition: "aws", Region: "eu-west-1"},
+			},
+		},
+		{
+			Name: "Kubernetes workload finding",
+			Finding: &findings.Finding{
+				RuleID:   "k8s-hardcoded-aws-credentials",
+				Severity: findings.SeverityHigh,
+				Resource: findings.KubernetesResource("my-cluster", findings.ResourceTypeKubernetesPod, "app", "web"),
+			},
+			ExpectedTypes: []string{"Sensitive Data Identifications/Security/AWS Credentials"},
+			ExpectedResources: []*Resource{
+				{Type: "Other", Id: "k8s://my-cluster/namespaces/app/pods/web", Partition: "aws", Region: "eu-west-1", Details: &ResourceDetails{
+					Other: map[string]string{"KubernetesResourceType": "KubernetesPod", "Namespace": "app", "Name": "web"},
+				}},
+				clusterResource,
+			},
+		},
+	}
+
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	for _, scenario := range scenarios {
+		t.Run(scenario.Name, func(t *testing.T) {
+			result, err := ConvertFindings([]*findings.Finding{scenario.Finding}, testClusterArn, "1.2.3", now)
+			assert.Nil(t, err)
+			assert.Len(t, result, 1)
+			finding := result[0]
+			assert.Equal(t, "123456789012", finding.AwsAccountId)
+			assert.Equal(t, "eu-west-1", finding.Region)
+			assert.Equal(t, "arn:aws:securityhub:eu-west-1:123456789012:product/123456789012/default", finding.ProductArn)
+			assert.Equal(t, testClusterArn+"/"+scenario.Finding.Fingerprint(), finding.Id)
+			assert.Equal(t, "mkat/"+scenario.Finding.RuleID, finding.GeneratorId)
+			assert.Equal(t, "2024-01-01T10:00:00Z", finding.CreatedAt)
+			assert.Equal(t, strings.ToUpper(string(scenario.Finding.Severity)), finding.Severity.Label)
+			assert.Equal(t, scenario.ExpectedTypes, finding.Types)
+			assert.Equal(t, scenario.ExpectedResources, finding.Resources)
+		})
+	}
+}
+
+func TestConvertFindingsTruncatesLongFields(t *testing.T) {
+	finding := &findings.Finding{
+		RuleID:      "eks-service-account-can-assume-role",
+		Title:       strings.Repeat("t", 300),
+		Description: strings.Repeat("d", 2000),
+		Severity:    findings.SeverityInformational,
+		Resource:    findings.KubernetesResource("my-cluster", findings.ResourceTypeKubernetesNamespace, "app", ""),
+	}
+	result, err := ConvertFindings([]*findings.Finding{finding}, testClusterArn, "", time.Now())
+	assert.Nil(t, err)
+	assert.Len(t, result[0].Title, 256)
+	assert.Len(t, result[0].Description, 1024)
+	assert.Nil(t, result[0].Remediation)
+	assert.Equal(t, "INFORMATIONAL", result[0].Severity.Label)
+	assert.NotContains(t, result[0].Resources[0].Details.Other, "Name")
+}
+
+func TestConvertFindingsInvalidClusterArn(t *testing.T) {
+	_, err := ConvertFindings(nil, "my-cluster", "", time.Now())
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,103 @@
+package asff
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/securityhub"
+	"github.com/aws/aws-sdk-go-v2/service/securityhub/types"
+)
+
+// Maximum number of findings per BatchImportFindings call
+const batchImportFindingsMaxSize = 100
+
+// SecurityHubSender imports findings into AWS Security Hub with BatchImportFindings
+type SecurityHubSender struct {
+	AWSConfig *aws.Config
+	// Optional, e.g. http://localhost:4566 to send findings to LocalStack. Defaults to the endpoint of Security Hub in
+	// the region of the findings.
+	Endpoint string
+}
+
+// BatchImportFindings imports findings in batches of 100. Findings must all belong to the same region.
+func (m *SecurityHubSender) BatchImportFindings(ctx context.Context, asffFindings []*Finding) error {
+	if len(asffFindings) == 0 {
+		return nil
+	}
+	client := securityhub.NewFromConfig(*m.AWSConfig, func(options *securityhub.Options) {
+		options.Region = asffFindings[0].Region
+		if m.Endpoint != "" {
+			options.BaseEndpoint = aws.String(m.Endpoint)
+		}
+	})
+	var failures []string
+	for start := 0; start < len(asffFindings); start += batchImportFindingsMaxSize {
+		end := start + batchImportFindingsMaxSize
+		if end > len(asffFindings) {
+			end = len(asffFindings)
+		}
+		batch := make([]types.AwsSecurityFinding, 0, end-start)
+		for _, finding := range asffFindings[start:end] {
+			batch = append(batch, finding.securityHubFinding())
+		}
+		response, err := client.BatchImportFindings(ctx, &securityhub.BatchImportFindingsInput{Findings: batch})
+		if err != nil {
+			return fmt.Errorf("unable to call BatchImportFindings: %v", err)
+		}
+		for _, failedFinding := range response.FailedFindings {
+			failures = append(failures, fmt.Sprintf("%s (%s: %s)", aws.ToString(failedFinding.Id), aws.ToString(failedFinding.ErrorCode), aws.ToString(failedFinding.ErrorMessage)))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("unable to import %d findings into Security Hub: %s", len(failures), strings.Join(failures, ", "))
+	}
+	log.Printf("Imported %d findings into Security Hub", len(asffFindings))
+	return nil
+}
+
+// securityHubFinding converts a finding to the type of the Security Hub API
+func (m *Finding) securityHubFinding() types.AwsSecurityFinding {
+	finding := types.AwsSecurityFinding{
+		SchemaVersion: aws.String(m.SchemaVersion),
+		Id:            aws.String(m.Id),
+		ProductArn:    aws.String(m.ProductArn),
+		ProductName:   aws.String(m.ProductName),
+		CompanyName:   aws.String(m.CompanyName),
+		GeneratorId:   aws.String(m.GeneratorId),
+		AwsAccountId:  aws.String(m.AwsAccountId),
+		Region:        aws.String(m.Region),
+		Types:         m.Types,
+		CreatedAt:     aws.String(m.CreatedAt),
+		UpdatedAt:     aws.String(m.UpdatedAt),
+		Severity:      &types.Severity{Label: types.SeverityLabel(m.Severity.Label), Original: aws.String(m.Severity.Original)},
+		Title:         aws.String(m.Title),
+		Description:   aws.String(m.Description),
+		ProductFields: m.ProductFields,
+		RecordState:   types.RecordState(m.RecordState),
+	}
+	if m.Remediation != nil {
+		finding.Remediation = &types.Remediation{Recommendation: &types.Recommendation{Text: aws.String(m.Remediation.Recommendation.Text)}}
+	}
+	if m.FindingProviderFields != nil {
+		finding.FindingProviderFields = &types.FindingProviderFields{
+			Severity: &types.FindingProviderSeverity{Label: types.SeverityLabel(m.FindingProviderFields.Severity.Label), Original: aws.String(m.FindingProviderFields.Severity.Original)},
+			Types:    m.FindingProviderFields.Types,
+		}
+	}
+	for _, resource := range m.Resources {
+		securityHubResource := types.Resource{
+			Type:      aws.String(resource.Type),
+			Id:        aws.String(resource.Id),
+			Partition: types.Partition(resource.Partition),
+			Region:    aws.String(resource.Region),
+		}
+		if resource.Details != nil {
+			securityHubResource.Details = &types.ResourceDetails{Other: resource.Details.Other}
+		}
+		finding.Resources = append(finding.Resources, securityHubResource)
+	}
+	return finding
+}
@@ -0,0 +1,123 @@
+package asff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+	"github.com/stretchr/testify/assert"
+)
+
+type batchImportFindingsRequest struct {
+	Findings []*Finding
+}
+
+type batchImportFindingsResponse struct {
+	FailedCount    int
+	SuccessCount   int
+	FailedFindings []map[string]string
+}
+
+// testSecurityHubFindings returns findings about a cluster with the given IDs
+func testSecurityHubFindings(t *testing.T, clusterArn string, ids ...string) []*Finding {
+	var mkatFindings []*findings.Finding
+	for range ids {
+		mkatFindings = append(mkatFindings, &findings.Finding{
+			RuleID:   "eks-private-endpoint-disabled",
+			Title:    "Private endpoint disabled",
+			Severity: findings.SeverityMedium,
+			Resource: findings.Resource{Type: findings.ResourceTypeEKSCluster, ID: clusterArn, Name: "my-cluster"},
+		})
+	}
+	asffFindings, err := ConvertFindings(mkatFindings, clusterArn, "1.2.3", time.Now())
+	assert.Nil(t, err)
+	for i, id := range ids {
+		asffFindings[i].Id = id
+	}
+	return asffFindings
+}
+
+func TestBatchImportFindings(t *testing.T) {
+	var batchSizes []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/findings/import", r.URL.Path)
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIA/"))
+		assert.Contains(t, r.Header.Get("Authorization"), "/eu-west-1/securityhub/aws4_request")
+		var request batchImportFindingsRequest
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&request))
+		batchSizes = append(batchSizes, len(request.Findings))
+
+		response := batchImportFindingsResponse{SuccessCount: len(request.Findings)}
+		if request.Findings[0].Id == "fail" {
+			response.SuccessCount--
+			response.FailedCount++
+			response.FailedFindings = append(response.FailedFindings, map[string]string{"Id": "fail", "ErrorCode": "InvalidInput", "ErrorMessage": "invalid finding"})
+		}
+		assert.Nil(t, json.NewEncoder(w).Encode(response))
+	}))
+	defer server.Close()
+
+	sender := SecurityHubSender{
+		AWSConfig: &aws.Config{Credentials: credentials.NewStaticCredentialsProvider("AKIA", "secret", "")},
+		Endpoint:  server.URL,
+	}
+	var ids []string
+	for i := 0; i < 250; i++ {
+		ids = append(ids, fmt.Sprint(i))
+	}
+	asffFindings := testSecurityHubFindings(t, testClusterArn, ids...)
+	assert.Nil(t, sender.BatchImportFindings(context.Background(), asffFindings))
+	assert.Equal(t, []int{100, 100, 50}, batchSizes)
+
+	err := sender.BatchImportFindings(context.Background(), testSecurityHubFindings(t, testClusterArn, "fail"))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "InvalidInput")
+}
+
+func TestBatchImportFindingsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"message":"not subscribed"}`))
+	}))
+	defer server.Close()
+
+	sender := SecurityHubSender{
+		AWSConfig: &aws.Config{Credentials: credentials.NewStaticCredentialsProvider("AKIA", "secret", "")},
+		Endpoint:  server.URL,
+	}
+	err := sender.BatchImportFindings(context.Background(), testSecurityHubFindings(t, testClusterArn, "1"))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "403")
+}
+
+// hostRecorder sends requests to a test server, recording the hosts they were meant for
+type hostRecorder struct {
+	server *httptest.Server
+	hosts  []string
+}
+
+func (m *hostRecorder) Do(request *http.Request) (*http.Response, error) {
+	m.hosts = append(m.hosts, request.URL.Host)
+	request.URL.Scheme, request.URL.Host = "http", strings.TrimPrefix(m.server.URL, "http://")
+	return http.DefaultClient.Do(request)
+}
+
+func TestBatchImportFindingsUsesTheEndpointOfThePartition(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"FailedCount": 0, "SuccessCount": 1, "FailedFindings": []}`))
+	}))
+	defer server.Close()
+	recorder := &hostRecorder{server: server}
+
+	sender := SecurityHubSender{AWSConfig: &aws.Config{Credentials: credentials.NewStaticCredentialsProvider("AKIA", "secret", ""), HTTPClient: recorder}}
+	assert.Nil(t, sender.BatchImportFindings(context.Background(), testSecurityHubFindings(t, "arn:aws-cn:eks:cn-north-1:123456789012:cluster/my-cluster", "1")))
+	assert.Equal(t, []string{"securityhub.cn-north-1.amazonaws.com.cn"}, recorder.hosts)
+}
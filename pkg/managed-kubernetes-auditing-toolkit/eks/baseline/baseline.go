@@ -0,0 +1,199 @@
+package baseline
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+	"sigs.k8s.io/yaml"
+)
+
+// FormatVersion is the version of the baseline file format. It's incremented on every breaking change.
+const FormatVersion = 1
+
+// DefaultExpiry is how long findings added to a baseline by Update stay suppressed
+const DefaultExpiry = 90 * 24 * time.Hour
+
+// Expiry dates are days, so that they're easy to write by hand
+const dateLayout = "2006-01-02"
+
+// Baseline lists findings that were accepted, so that they're suppressed until they expire. Findings are identified by
+// their fingerprint, which doesn't depend on their description or severity, and by the secret they're about, so that
+// a new secret leaked at the same place is reported.
+type Baseline struct {
+	Version int `json:"version"`
+	// Random key of the hashes of secrets in fingerprints, generated when the baseline is created, so that they can't be
+	// matched against the hashes of guessed values
+	Key     string   `json:"key,omitempty"`
+	Entries []*Entry `json:"entries"`
+}
+
+// Entry is an accepted finding
+type Entry struct {
+	Fingerprint string `json:"fingerprint"`
+	// Rule, resource and description of the finding when it was added, so that humans can review the baseline
+	RuleID      string `json:"ruleId,omitempty"`
+	Resource    string `json:"resource,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Why the finding was accepted
+	Justification string `json:"justification"`
+	// Set by Update on entries without justification, so that reviewers of the baseline notice them
+	NeedsReview bool `json:"needsReview,omitempty"`
+	// Date from which the finding is reported again, e.g. 2024-06-30. Entries without one never expire.
+	Expires string `json:"expires,omitempty"`
+}
+
+// Load reads a baseline file, in YAML or JSON
+func Load(path string) (*Baseline, error) {
+	rawBaseline, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read baseline file %s: %v", path, err)
+	}
+	var baseline Baseline
+	if err := yaml.UnmarshalStrict(rawBaseline, &baseline); err != nil {
+		return nil, fmt.Errorf("unable to parse baseline file %s: %v", path, err)
+	}
+	if baseline.Version < 1 || baseline.Version > FormatVersion {
+		return nil, fmt.Errorf("unsupported baseline format version %d (this version of MKAT supports version %d)", baseline.Version, FormatVersion)
+	}
+	for _, entry := range baseline.Entries {
+		if entry.Fingerprint == "" {
+			return nil, fmt.Errorf("baseline file %s contains an entry without fingerprint", path)
+		}
+		if _, err := entry.expiryDate(); err != nil {
+			return nil, fmt.Errorf("invalid expiry date of baseline entry %s: %v", entry.Fingerprint, err)
+		}
+	}
+	return &baseline, nil
+}
+
+// LoadOrCreate reads a baseline file, or returns an empty baseline if it doesn't exist yet
+func LoadOrCreate(path string) (*Baseline, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return &Baseline{Version: FormatVersion}, nil
+	}
+	return Load(path)
+}
+
+// Save writes the baseline to a YAML file
+func (m *Baseline) Save(path string) error {
+	rawBaseline, err := yaml.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("unable to serialize baseline: %v", err)
+	}
+	return os.WriteFile(path, rawBaseline, 0644)
+}
+
+// Entry returns the entry of a finding, or nil if the finding isn't in the baseline
+func (m *Baseline) Entry(finding *findings.Finding) *Entry {
+	fingerprint := m.fingerprint(finding)
+	for _, entry := range m.Entries {
+		if entry.Fingerprint == fingerprint {
+			return entry
+		}
+	}
+	return nil
+}
+
+// EntriesNeedingReview returns the entries that don't have a justification
+func (m *Baseline) EntriesNeedingReview() []*Entry {
+	var entries []*Entry
+	for _, entry := range m.Entries {
+		if entry.Justification == "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// Suppresses determines if a finding is in the baseline, and hasn't expired
+func (m *Baseline) Suppresses(finding *findings.Finding, now time.Time) bool {
+	entry := m.Entry(finding)
+	return entry != nil && !entry.IsExpired(now)
+}
+
+// Update adds the current findings to the baseline. Existing entries keep their justification and expiry date, even
+// if they expired, so that accepting a finding again is an explicit decision. New findings expire after DefaultExpiry,
+// and entries without justification are marked as needing review.
+// Entries of findings that weren't found are only removed if isInScope returns true for them, i.e. if their resource
+// was scanned, so that scanning a part of the cluster doesn't drop the others. A nil isInScope keeps them all.
+func (m *Baseline) Update(currentFindings []*findings.Finding, now time.Time, isInScope func(entry *Entry) bool) {
+	if m.Key == "" {
+		m.Key = newKey()
+	}
+	var entries []*Entry
+	seen := map[string]bool{}
+	for _, finding := range currentFindings {
+		fingerprint := m.fingerprint(finding)
+		if seen[fingerprint] {
+			continue
+		}
+		seen[fingerprint] = true
+		entry := m.Entry(finding)
+		if entry == nil {
+			entry = &Entry{Fingerprint: fingerprint, Expires: now.Add(DefaultExpiry).UTC().Format(dateLayout)}
+		}
+		entry.RuleID = finding.RuleID
+		entry.Resource = finding.Resource.ID
+		entry.Description = finding.Description
+		entry.NeedsReview = entry.Justification == ""
+		entries = append(entries, entry)
+	}
+	for _, entry := range m.Entries {
+		if !seen[entry.Fingerprint] && (isInScope == nil || !isInScope(entry)) {
+			entries = append(entries, entry)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Resource != entries[j].Resource {
+			return entries[i].Resource < entries[j].Resource
+		}
+		return entries[i].Fingerprint < entries[j].Fingerprint
+	})
+	m.Version = FormatVersion
+	m.Entries = entries
+}
+
+// fingerprint identifies a finding in the baseline. The fingerprint of findings about a secret is combined with a hash
+// of the secret, keyed with the key of the baseline.
+func (m *Baseline) fingerprint(finding *findings.Finding) string {
+	if finding.Secret == "" {
+		return finding.Fingerprint()
+	}
+	mac := hmac.New(sha256.New, []byte(m.Key))
+	mac.Write([]byte(finding.Fingerprint() + "\x00" + finding.Secret))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newKey() string {
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("unable to generate the key of the baseline: %v", err))
+	}
+	return hex.EncodeToString(key)
+}
+
+// IsExpired determines if the finding of the entry should be reported again
+func (m *Entry) IsExpired(now time.Time) bool {
+	expiryDate, err := m.expiryDate()
+	return err == nil && expiryDate != nil && !now.Before(*expiryDate)
+}
+
+// expiryDate returns the start of the day the entry expires, in UTC, or nil if it never expires
+func (m *Entry) expiryDate() (*time.Time, error) {
+	if m.Expires == "" {
+		return nil, nil
+	}
+	expiryDate, err := time.Parse(dateLayout, m.Expires)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a date in the YYYY-MM-DD format", m.Expires)
+	}
+	return &expiryDate, nil
+}
@@ -0,0 +1,205 @@
+package cluster_config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+	"github.com/hashicorp/go-version"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	RuleIDPublicEndpointOpenToInternet = "eks-public-endpoint-open-to-internet"
+	RuleIDPrivateEndpointDisabled      = "eks-private-endpoint-disabled"
+	RuleIDControlPlaneLoggingDisabled  = "eks-control-plane-logging-disabled"
+	RuleIDSecretsEncryptionDisabled    = "eks-secrets-encryption-disabled"
+	RuleIDKubernetesVersionUnsupported = "eks-kubernetes-version-unsupported"
+	RuleIDAuthenticationModeConfigMap  = "eks-authentication-mode-config-map"
+)
+
+// End of standard support for each EKS Kubernetes version
+// c.f. https://docs.aws.amazon.com/eks/latest/userguide/kubernetes-versions.html
+var EndOfStandardSupport = map[string]time.Time{
+	"1.23": time.Date(2023, time.October, 11, 0, 0, 0, 0, time.UTC),
+	"1.24": time.Date(2024, time.January, 31, 0, 0, 0, 0, time.UTC),
+	"1.25": time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC),
+	"1.26": time.Date(2024, time.June, 11, 0, 0, 0, 0, time.UTC),
+	"1.27": time.Date(2024, time.July, 24, 0, 0, 0, 0, time.UTC),
+	"1.28": time.Date(2024, time.November, 26, 0, 0, 0, 0, time.UTC),
+	"1.29": time.Date(2025, time.March, 23, 0, 0, 0, 0, time.UTC),
+	"1.30": time.Date(2025, time.July, 23, 0, 0, 0, 0, time.UTC),
+	"1.31": time.Date(2025, time.November, 26, 0, 0, 0, 0, time.UTC),
+	"1.32": time.Date(2026, time.March, 23, 0, 0, 0, 0, time.UTC),
+	"1.33": time.Date(2026, time.July, 29, 0, 0, 0, 0, time.UTC),
+	"1.34": time.Date(2026, time.December, 2, 0, 0, 0, 0, time.UTC),
+}
+
+// Oldest version for which we know the end of standard support date. Anything older is unsupported.
+const oldestKnownKubernetesVersion = "1.23"
+
+// Control plane log types that are the most useful to investigate an incident
+var securityRelevantLogTypes = []types.LogType{types.LogTypeAudit, types.LogTypeAuthenticator}
+
+type clusterCheck func(cluster *types.Cluster, now time.Time) *findings.Finding
+
+var clusterChecks = []clusterCheck{
+	checkPublicEndpoint,
+	checkPrivateEndpoint,
+	checkControlPlaneLogging,
+	checkSecretsEncryption,
+	checkKubernetesVersion,
+	checkAuthenticationMode,
+}
+
+// AuditClusterConfiguration runs all configuration checks against the DescribeCluster response of an EKS cluster
+func AuditClusterConfiguration(cluster *types.Cluster, now time.Time) []*findings.Finding {
+	var results []*findings.Finding
+	for _, check := range clusterChecks {
+		if finding := check(cluster, now); finding != nil {
+			finding.Resource = clusterResource(cluster)
+			results = append(results, finding)
+		}
+	}
+	return results
+}
+
+func checkPublicEndpoint(cluster *types.Cluster, _ time.Time) *findings.Finding {
+	vpcConfig := cluster.ResourcesVpcConfig
+	if vpcConfig == nil || !vpcConfig.EndpointPublicAccess || !slices.Contains(vpcConfig.PublicAccessCidrs, "0.0.0.0/0") {
+		return nil
+	}
+	return &findings.Finding{
+		RuleID:      RuleIDPublicEndpointOpenToInternet,
+		Title:       "Public API server endpoint is open to the Internet",
+		Description: "The Kubernetes API server of the cluster is reachable from any IP address (0.0.0.0/0)",
+		Severity:    findings.SeverityHigh,
+		Remediation: "Disable the public endpoint, or restrict it to the IP ranges of your corporate network and CI systems using publicAccessCidrs",
+	}
+}
+
+func checkPrivateEndpoint(cluster *types.Cluster, _ time.Time) *findings.Finding {
+	vpcConfig := cluster.ResourcesVpcConfig
+	if vpcConfig == nil || vpcConfig.EndpointPrivateAccess {
+		return nil
+	}
+	return &findings.Finding{
+		RuleID:      RuleIDPrivateEndpointDisabled,
+		Title:       "Private API server endpoint is disabled",
+		Description: "Worker nodes and in-VPC clients reach the Kubernetes API server through its public endpoint",
+		Severity:    findings.SeverityMedium,
+		Remediation: "Enable the private endpoint so that traffic from the VPC to the API server does not leave it",
+	}
+}
+
+func checkControlPlaneLogging(cluster *types.Cluster, _ time.Time) *findings.Finding {
+	enabledLogTypes := map[types.LogType]bool{}
+	if cluster.Logging != nil {
+		for _, logSetup := range cluster.Logging.ClusterLogging {
+			if logSetup.Enabled == nil || !*logSetup.Enabled {
+				continue
+			}
+			for _, logType := range logSetup.Types {
+				enabledLogTypes[logType] = true
+			}
+		}
+	}
+
+	var disabledLogTypes []string
+	severity := findings.SeverityLow
+	for _, logType := range types.LogType("").Values() {
+		if enabledLogTypes[logType] {
+			continue
+		}
+		disabledLogTypes = append(disabledLogTypes, string(logType))
+		if slices.Contains(securityRelevantLogTypes, logType) {
+			severity = findings.SeverityMedium
+		}
+	}
+	if len(disabledLogTypes) == 0 {
+		return nil
+	}
+
+	return &findings.Finding{
+		RuleID:      RuleIDControlPlaneLoggingDisabled,
+		Title:       "Control plane logging is partially or fully disabled",
+		Description: "The following control plane log types are not sent to CloudWatch: " + strings.Join(disabledLogTypes, ", "),
+		Severity:    severity,
+		Remediation: "Enable at least the audit and authenticator control plane log types, to be able to investigate suspicious activity in the cluster",
+	}
+}
+
+func checkSecretsEncryption(cluster *types.Cluster, _ time.Time) *findings.Finding {
+	for _, encryptionConfig := range cluster.EncryptionConfig {
+		if slices.Contains(encryptionConfig.Resources, "secrets") && encryptionConfig.Provider != nil && encryptionConfig.Provider.KeyArn != nil {
+			return nil
+		}
+	}
+	return &findings.Finding{
+		RuleID:      RuleIDSecretsEncryptionDisabled,
+		Title:       "Kubernetes secrets are not encrypted with a KMS key",
+		Description: "The cluster does not use KMS envelope encryption for Kubernetes secrets",
+		Severity:    findings.SeverityMedium,
+		Remediation: "Associate a KMS key with the cluster to encrypt Kubernetes secrets (envelope encryption cannot be disabled once enabled)",
+	}
+}
+
+func checkKubernetesVersion(cluster *types.Cluster, now time.Time) *findings.Finding {
+	if cluster.Version == nil {
+		return nil
+	}
+	clusterVersion, err := version.NewVersion(*cluster.Version)
+	if err != nil {
+		return nil
+	}
+
+	endOfSupport, known := EndOfStandardSupport[*cluster.Version]
+	oldestKnownVersion, _ := version.NewVersion(oldestKnownKubernetesVersion)
+	isTooOld := !known && clusterVersion.LessThan(oldestKnownVersion)
+	if !isTooOld && (!known || now.Before(endOfSupport)) {
+		return nil
+	}
+
+	description := fmt.Sprintf("The cluster runs Kubernetes %s, which is past its end of standard support", *cluster.Version)
+	if known {
+		description += fmt.Sprintf(" (%s)", endOfSupport.Format("2006-01-02"))
+	}
+	return &findings.Finding{
+		RuleID:      RuleIDKubernetesVersionUnsupported,
+		Title:       "Kubernetes version is past end of standard support",
+		Description: description,
+		Severity:    findings.SeverityMedium,
+		Remediation: "Upgrade the cluster to a Kubernetes version in standard support, to keep receiving security patches",
+	}
+}
+
+func checkAuthenticationMode(cluster *types.Cluster, _ time.Time) *findings.Finding {
+	// Clusters created before access entries were released don't return an access configuration
+	authenticationMode := types.AuthenticationModeConfigMap
+	if cluster.AccessConfig != nil && cluster.AccessConfig.AuthenticationMode != "" {
+		authenticationMode = cluster.AccessConfig.AuthenticationMode
+	}
+	if authenticationMode != types.AuthenticationModeConfigMap {
+		return nil
+	}
+	return &findings.Finding{
+		RuleID:      RuleIDAuthenticationModeConfigMap,
+		Title:       "Cluster authentication relies only on the aws-auth ConfigMap",
+		Description: "The authentication mode of the cluster is CONFIG_MAP, meaning that access is managed exclusively through the aws-auth ConfigMap",
+		Severity:    findings.SeverityLow,
+		Remediation: "Switch the authentication mode to API_AND_CONFIG_MAP, then to API, and manage cluster access through EKS access entries",
+	}
+}
+
+func clusterResource(cluster *types.Cluster) findings.Resource {
+	resource := findings.Resource{Type: findings.ResourceTypeEKSCluster}
+	if cluster.Arn != nil {
+		resource.ID = *cluster.Arn
+	}
+	if cluster.Name != nil {
+		resource.Name = *cluster.Name
+	}
+	return resource
+}
@@ -0,0 +1,165 @@
+package cluster_config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// secureCluster returns the DescribeCluster response of a cluster that passes all checks
+func secureCluster() *types.Cluster {
+	return &types.Cluster{
+		Arn:     aws.String("arn:aws:eks:us-east-1:012345678901:cluster/my-cluster"),
+		Name:    aws.String("my-cluster"),
+		Version: aws.String("1.30"),
+		ResourcesVpcConfig: &types.VpcConfigResponse{
+			EndpointPublicAccess:  true,
+			EndpointPrivateAccess: true,
+			PublicAccessCidrs:     []string{"203.0.113.0/24"},
+		},
+		Logging: &types.Logging{ClusterLogging: []types.LogSetup{
+			{Enabled: aws.Bool(true), Types: types.LogType("").Values()},
+		}},
+		EncryptionConfig: []types.EncryptionConfig{{
+			Resources: []string{"secrets"},
+			Provider:  &types.Provider{KeyArn: aws.String("arn:aws:kms:us-east-1:012345678901:key/foo")},
+		}},
+		AccessConfig: &types.AccessConfigResponse{AuthenticationMode: types.AuthenticationModeApi},
+	}
+}
+
+func TestAuditsClusterConfiguration(t *testing.T) {
+	now := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	scenarios := []struct {
+		Name            string
+		Cluster         func(cluster *types.Cluster)
+		ExpectedRuleIDs []string
+	}{
+		{
+			Name:            "secure cluster",
+			Cluster:         func(cluster *types.Cluster) {},
+			ExpectedRuleIDs: nil,
+		},
+		{
+			Name: "public endpoint open to the Internet",
+			Cluster: func(cluster *types.Cluster) {
+				cluster.ResourcesVpcConfig.PublicAccessCidrs = []string{"0.0.0.0/0"}
+			},
+			ExpectedRuleIDs: []string{RuleIDPublicEndpointOpenToInternet},
+		},
+		{
+			Name: "public endpoint disabled but with the default CIDR",
+			Cluster: func(cluster *types.Cluster) {
+				cluster.ResourcesVpcConfig.EndpointPublicAccess = false
+				cluster.ResourcesVpcConfig.PublicAccessCidrs = []string{"0.0.0.0/0"}
+			},
+			ExpectedRuleIDs: nil,
+		},
+		{
+			Name: "private endpoint disabled",
+			Cluster: func(cluster *types.Cluster) {
+				cluster.ResourcesVpcConfig.EndpointPrivateAccess = false
+			},
+			ExpectedRuleIDs: []string{RuleIDPrivateEndpointDisabled},
+		},
+		{
+			Name: "some log types disabled",
+			Cluster: func(cluster *types.Cluster) {
+				cluster.Logging.ClusterLogging = []types.LogSetup{
+					{Enabled: aws.Bool(true), Types: []types.LogType{types.LogTypeApi, types.LogTypeAudit}},
+					{Enabled: aws.Bool(false), Types: []types.LogType{types.LogTypeAuthenticator}},
+				}
+			},
+			ExpectedRuleIDs: []string{RuleIDControlPlaneLoggingDisabled},
+		},
+		{
+			Name: "no logging configuration",
+			Cluster: func(cluster *types.Cluster) {
+				cluster.Logging = nil
+			},
+			ExpectedRuleIDs: []string{RuleIDControlPlaneLoggingDisabled},
+		},
+		{
+			Name: "no secrets encryption",
+			Cluster: func(cluster *types.Cluster) {
+				cluster.EncryptionConfig = nil
+			},
+			ExpectedRuleIDs: []string{RuleIDSecretsEncryptionDisabled},
+		},
+		{
+			Name: "Kubernetes version past end of standard support",
+			Cluster: func(cluster *types.Cluster) {
+				cluster.Version = aws.String("1.27")
+			},
+			ExpectedRuleIDs: []string{RuleIDKubernetesVersionUnsupported},
+		},
+		{
+			Name: "Kubernetes version older than all known versions",
+			Cluster: func(cluster *types.Cluster) {
+				cluster.Version = aws.String("1.21")
+			},
+			ExpectedRuleIDs: []string{RuleIDKubernetesVersionUnsupported},
+		},
+		{
+			Name: "Kubernetes version newer than all known versions",
+			Cluster: func(cluster *types.Cluster) {
+				cluster.Version = aws.String("1.99")
+			},
+			ExpectedRuleIDs: nil,
+		},
+		{
+			Name: "authentication mode CONFIG_MAP",
+			Cluster: func(cluster *types.Cluster) {
+				cluster.AccessConfig.AuthenticationMode = types.AuthenticationModeConfigMap
+			},
+			ExpectedRuleIDs: []string{RuleIDAuthenticationModeConfigMap},
+		},
+		{
+			Name: "no access configuration",
+			Cluster: func(cluster *types.Cluster) {
+				cluster.AccessConfig = nil
+			},
+			ExpectedRuleIDs: []string{RuleIDAuthenticationModeConfigMap},
+		},
+		{
+			Name: "authentication mode API_AND_CONFIG_MAP",
+			Cluster: func(cluster *types.Cluster) {
+				cluster.AccessConfig.AuthenticationMode = types.AuthenticationModeApiAndConfigMap
+			},
+			ExpectedRuleIDs: nil,
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.Name, func(t *testing.T) {
+			cluster := secureCluster()
+			scenario.Cluster(cluster)
+			var ruleIDs []string
+			for _, finding := range AuditClusterConfiguration(cluster, now) {
+				ruleIDs = append(ruleIDs, finding.RuleID)
+				assert.Equal(t, "arn:aws:eks:us-east-1:012345678901:cluster/my-cluster", finding.Resource.ID)
+				assert.NotEmpty(t, finding.Remediation)
+			}
+			assert.ElementsMatch(t, scenario.ExpectedRuleIDs, ruleIDs)
+		})
+	}
+}
+
+func TestControlPlaneLoggingSeverity(t *testing.T) {
+	cluster := secureCluster()
+	cluster.Logging.ClusterLogging = []types.LogSetup{
+		{Enabled: aws.Bool(true), Types: []types.LogType{types.LogTypeAudit, types.LogTypeAuthenticator}},
+	}
+	finding := checkControlPlaneLogging(cluster, time.Now())
+	assert.Equal(t, "low", string(finding.Severity))
+
+	cluster.Logging.ClusterLogging = []types.LogSetup{
+		{Enabled: aws.Bool(true), Types: []types.LogType{types.LogTypeApi}},
+	}
+	finding = checkControlPlaneLogging(cluster, time.Now())
+	assert.Equal(t, "medium", string(finding.Severity))
+	assert.Contains(t, finding.Description, "audit")
+}
@@ -0,0 +1,54 @@
+package datasource
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultConcurrency is the default number of concurrent API calls made by live data sources
+const DefaultConcurrency = 10
+
+// forEachConcurrently calls fn for every index of a slice of the given length, using at most `concurrency` workers.
+// It stops scheduling new calls on the first error or when the context is cancelled, and returns the first error.
+func forEachConcurrently(ctx context.Context, concurrency int, length int, fn func(ctx context.Context, i int) error) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	indexes := make(chan int)
+	var firstErr error
+	var errOnce sync.Once
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrency && worker < length; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := fn(ctx, i); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+schedule:
+	for i := 0; i < length; i++ {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break schedule
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
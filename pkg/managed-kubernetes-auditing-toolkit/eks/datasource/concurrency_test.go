@@ -0,0 +1,63 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForEachConcurrentlyBoundsConcurrency(t *testing.T) {
+	var inFlight, maxInFlight int32
+	var mutex sync.Mutex
+	processed := map[int]bool{}
+	err := forEachConcurrently(context.Background(), 3, 20, func(ctx context.Context, i int) error {
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		mutex.Lock()
+		if current > maxInFlight {
+			maxInFlight = current
+		}
+		processed[i] = true
+		mutex.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Len(t, processed, 20)
+	assert.LessOrEqual(t, maxInFlight, int32(3))
+}
+
+func TestForEachConcurrentlyStopsOnFirstError(t *testing.T) {
+	var calls int32
+	err := forEachConcurrently(context.Background(), 1, 100, func(ctx context.Context, i int) error {
+		atomic.AddInt32(&calls, 1)
+		if i == 2 {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	assert.EqualError(t, err, "failed")
+	assert.Less(t, atomic.LoadInt32(&calls), int32(100))
+}
+
+func TestForEachConcurrentlyHonorsCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := forEachConcurrently(ctx, 2, 100, func(ctx context.Context, i int) error {
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestForEachConcurrentlyWithNoItems(t *testing.T) {
+	err := forEachConcurrently(context.Background(), 2, 0, func(ctx context.Context, i int) error {
+		t.Fatal("should not be called")
+		return nil
+	})
+	assert.Nil(t, err)
+}
@@ -0,0 +1,78 @@
+package datasource
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ClusterDataSource provides information about an EKS cluster, as returned by the EKS API
+type ClusterDataSource interface {
+	DescribeCluster(ctx context.Context, clusterName string) (*types.Cluster, error)
+	ListFargateProfiles(ctx context.Context, clusterName string) ([]*types.FargateProfile, error)
+	ListPodIdentityAssociations(ctx context.Context, clusterName string) ([]*PodIdentityAssociation, error)
+
+	// DescribeAddon returns nil (without error) if the add-on is not installed
+	DescribeAddon(ctx context.Context, clusterName string, addonName string) (*types.Addon, error)
+}
+
+// IAMDataSource provides information about the IAM roles of an AWS account
+type IAMDataSource interface {
+	ListRoles(ctx context.Context) ([]*IAMRole, error)
+}
+
+// KubernetesDataSource provides Kubernetes resources. List methods call handlePage for every page of results, so
+// that callers can process large clusters without holding all resources in memory.
+type KubernetesDataSource interface {
+	ListServiceAccounts(ctx context.Context, options ListOptions, handlePage func([]corev1.ServiceAccount) error) error
+	ListPods(ctx context.Context, options ListOptions, handlePage func([]corev1.Pod) error) error
+	ListConfigMaps(ctx context.Context, options ListOptions, handlePage func([]corev1.ConfigMap) error) error
+	ListSecrets(ctx context.Context, options ListOptions, handlePage func([]corev1.Secret) error) error
+	ListNamespaces(ctx context.Context, handlePage func([]corev1.Namespace) error) error
+
+	// Workloads, whose pod templates may hold the same data as their pods
+	ListDeployments(ctx context.Context, options ListOptions, handlePage func([]appsv1.Deployment) error) error
+	ListStatefulSets(ctx context.Context, options ListOptions, handlePage func([]appsv1.StatefulSet) error) error
+	ListDaemonSets(ctx context.Context, options ListOptions, handlePage func([]appsv1.DaemonSet) error) error
+	ListReplicaSets(ctx context.Context, options ListOptions, handlePage func([]appsv1.ReplicaSet) error) error
+	ListJobs(ctx context.Context, options ListOptions, handlePage func([]batchv1.Job) error) error
+	ListCronJobs(ctx context.Context, options ListOptions, handlePage func([]batchv1.CronJob) error) error
+
+	// GetDaemonSet returns nil (without error) if the DaemonSet does not exist
+	GetDaemonSet(ctx context.Context, namespace string, name string) (*appsv1.DaemonSet, error)
+	// GetConfigMap returns nil (without error) if the ConfigMap does not exist
+	GetConfigMap(ctx context.Context, namespace string, name string) (*corev1.ConfigMap, error)
+	// GetSecret returns nil (without error) if the Secret does not exist
+	GetSecret(ctx context.Context, namespace string, name string) (*corev1.Secret, error)
+}
+
+// ListOptions restricts the Kubernetes resources to list
+type ListOptions struct {
+	Namespace     string // empty for all namespaces
+	LabelSelector string // e.g. "app=nginx", empty for all resources
+	FieldSelector string // e.g. "metadata.namespace!=kube-system", empty for all resources
+}
+
+// DataSources groups the data sources needed to analyze an EKS cluster
+type DataSources struct {
+	Cluster    ClusterDataSource
+	IAM        IAMDataSource
+	Kubernetes KubernetesDataSource
+
+	Stats *APICallStats // nil if the data sources don't make API calls
+}
+
+type IAMRole struct {
+	Arn         string `json:"arn"`
+	TrustPolicy string `json:"trustPolicy"`
+}
+
+type PodIdentityAssociation struct {
+	ID                 string `json:"id"`
+	Namespace          string `json:"namespace"`
+	ServiceAccountName string `json:"serviceAccountName"`
+	RoleArn            string `json:"roleArn"`
+}
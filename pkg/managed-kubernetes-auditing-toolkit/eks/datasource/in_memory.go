@@ -0,0 +1,218 @@
+package datasource
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// InMemory implements all data sources from static data. It's useful for tests, and to analyze inventories that
+// don't come from the AWS and Kubernetes APIs.
+type InMemory struct {
+	Clusters                []*types.Cluster
+	FargateProfiles         map[string][]*types.FargateProfile   // by cluster name
+	PodIdentityAssociations map[string][]*PodIdentityAssociation // by cluster name
+	Addons                  map[string][]*types.Addon            // by cluster name
+	IAMRoles                []*IAMRole
+	ServiceAccounts         []corev1.ServiceAccount
+	Pods                    []corev1.Pod
+	ConfigMaps              []corev1.ConfigMap
+	Secrets                 []corev1.Secret
+	Deployments             []appsv1.Deployment
+	StatefulSets            []appsv1.StatefulSet
+	DaemonSets              []appsv1.DaemonSet
+	ReplicaSets             []appsv1.ReplicaSet
+	Jobs                    []batchv1.Job
+	CronJobs                []batchv1.CronJob
+	Namespaces              []corev1.Namespace // if empty, the namespaces of the resources above
+}
+
+// DataSources returns data sources backed by the in-memory data
+func (m *InMemory) DataSources() *DataSources {
+	return &DataSources{Cluster: m, IAM: m, Kubernetes: m}
+}
+
+func (m *InMemory) DescribeCluster(_ context.Context, clusterName string) (*types.Cluster, error) {
+	for _, cluster := range m.Clusters {
+		if cluster.Name != nil && *cluster.Name == clusterName {
+			return cluster, nil
+		}
+	}
+	return nil, fmt.Errorf("unable to describe cluster %s: cluster not found", clusterName)
+}
+
+func (m *InMemory) ListFargateProfiles(_ context.Context, clusterName string) ([]*types.FargateProfile, error) {
+	return m.FargateProfiles[clusterName], nil
+}
+
+func (m *InMemory) ListPodIdentityAssociations(_ context.Context, clusterName string) ([]*PodIdentityAssociation, error) {
+	return m.PodIdentityAssociations[clusterName], nil
+}
+
+func (m *InMemory) DescribeAddon(_ context.Context, clusterName string, addonName string) (*types.Addon, error) {
+	for _, addon := range m.Addons[clusterName] {
+		if addon.AddonName != nil && *addon.AddonName == addonName {
+			return addon, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *InMemory) ListRoles(_ context.Context) ([]*IAMRole, error) {
+	return m.IAMRoles, nil
+}
+
+func (m *InMemory) ListServiceAccounts(_ context.Context, options ListOptions, handlePage func([]corev1.ServiceAccount) error) error {
+	return handleFilteredItems(m.ServiceAccounts, options, handlePage)
+}
+
+func (m *InMemory) ListPods(_ context.Context, options ListOptions, handlePage func([]corev1.Pod) error) error {
+	return handleFilteredItems(m.Pods, options, handlePage)
+}
+
+func (m *InMemory) ListConfigMaps(_ context.Context, options ListOptions, handlePage func([]corev1.ConfigMap) error) error {
+	return handleFilteredItems(m.ConfigMaps, options, handlePage)
+}
+
+func (m *InMemory) ListSecrets(_ context.Context, options ListOptions, handlePage func([]corev1.Secret) error) error {
+	return handleFilteredItems(m.Secrets, options, handlePage)
+}
+
+func (m *InMemory) ListDeployments(_ context.Context, options ListOptions, handlePage func([]appsv1.Deployment) error) error {
+	return handleFilteredItems(m.Deployments, options, handlePage)
+}
+
+func (m *InMemory) ListStatefulSets(_ context.Context, options ListOptions, handlePage func([]appsv1.StatefulSet) error) error {
+	return handleFilteredItems(m.StatefulSets, options, handlePage)
+}
+
+func (m *InMemory) ListDaemonSets(_ context.Context, options ListOptions, handlePage func([]appsv1.DaemonSet) error) error {
+	return handleFilteredItems(m.DaemonSets, options, handlePage)
+}
+
+func (m *InMemory) ListReplicaSets(_ context.Context, options ListOptions, handlePage func([]appsv1.ReplicaSet) error) error {
+	return handleFilteredItems(m.ReplicaSets, options, handlePage)
+}
+
+func (m *InMemory) ListJobs(_ context.Context, options ListOptions, handlePage func([]batchv1.Job) error) error {
+	return handleFilteredItems(m.Jobs, options, handlePage)
+}
+
+func (m *InMemory) ListCronJobs(_ context.Context, options ListOptions, handlePage func([]batchv1.CronJob) error) error {
+	return handleFilteredItems(m.CronJobs, options, handlePage)
+}
+
+func (m *InMemory) ListNamespaces(_ context.Context, handlePage func([]corev1.Namespace) error) error {
+	if len(m.Namespaces) > 0 {
+		return handlePage(m.Namespaces)
+	}
+	var namespaces []corev1.Namespace
+	seen := map[string]bool{}
+	addNamespace := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			namespaces = append(namespaces, corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+		}
+	}
+	for i := range m.ServiceAccounts {
+		addNamespace(m.ServiceAccounts[i].Namespace)
+	}
+	for i := range m.Pods {
+		addNamespace(m.Pods[i].Namespace)
+	}
+	for i := range m.ConfigMaps {
+		addNamespace(m.ConfigMaps[i].Namespace)
+	}
+	for i := range m.Secrets {
+		addNamespace(m.Secrets[i].Namespace)
+	}
+	for i := range m.Deployments {
+		addNamespace(m.Deployments[i].Namespace)
+	}
+	for i := range m.StatefulSets {
+		addNamespace(m.StatefulSets[i].Namespace)
+	}
+	for i := range m.DaemonSets {
+		addNamespace(m.DaemonSets[i].Namespace)
+	}
+	for i := range m.ReplicaSets {
+		addNamespace(m.ReplicaSets[i].Namespace)
+	}
+	for i := range m.Jobs {
+		addNamespace(m.Jobs[i].Namespace)
+	}
+	for i := range m.CronJobs {
+		addNamespace(m.CronJobs[i].Namespace)
+	}
+	if len(namespaces) == 0 {
+		return nil
+	}
+	return handlePage(namespaces)
+}
+
+func (m *InMemory) GetDaemonSet(_ context.Context, namespace string, name string) (*appsv1.DaemonSet, error) {
+	for i := range m.DaemonSets {
+		if m.DaemonSets[i].Namespace == namespace && m.DaemonSets[i].Name == name {
+			return &m.DaemonSets[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *InMemory) GetConfigMap(_ context.Context, namespace string, name string) (*corev1.ConfigMap, error) {
+	for i := range m.ConfigMaps {
+		if m.ConfigMaps[i].Namespace == namespace && m.ConfigMaps[i].Name == name {
+			return &m.ConfigMaps[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *InMemory) GetSecret(_ context.Context, namespace string, name string) (*corev1.Secret, error) {
+	for i := range m.Secrets {
+		if m.Secrets[i].Namespace == namespace && m.Secrets[i].Name == name {
+			return &m.Secrets[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// handleFilteredItems passes all items matching the list options as a single page
+func handleFilteredItems[T any, PT interface {
+	*T
+	metav1.Object
+}](items []T, options ListOptions, handlePage func([]T) error) error {
+	selector, err := labels.Parse(options.LabelSelector)
+	if err != nil {
+		return fmt.Errorf("invalid label selector %s: %v", options.LabelSelector, err)
+	}
+	fieldSelector, err := fields.ParseSelector(options.FieldSelector)
+	if err != nil {
+		return fmt.Errorf("invalid field selector %s: %v", options.FieldSelector, err)
+	}
+	var filtered []T
+	for i := range items {
+		object := PT(&items[i])
+		if options.Namespace != "" && object.GetNamespace() != options.Namespace {
+			continue
+		}
+		if !selector.Matches(labels.Set(object.GetLabels())) {
+			continue
+		}
+		if !fieldSelector.Matches(fields.Set{"metadata.namespace": object.GetNamespace(), "metadata.name": object.GetName()}) {
+			continue
+		}
+		filtered = append(filtered, items[i])
+	}
+	if len(filtered) == 0 {
+		return nil
+	}
+	return handlePage(filtered)
+}
@@ -0,0 +1,65 @@
+package datasource
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestInMemoryDataSource(t *testing.T) {
+	inMemory := &InMemory{
+		Clusters: []*types.Cluster{{Name: aws.String("my-cluster")}},
+		Addons:   map[string][]*types.Addon{"my-cluster": {{AddonName: aws.String("vpc-cni")}}},
+		Pods: []corev1.Pod{
+			{ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "default", Labels: map[string]string{"app": "web"}}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "pod-2", Namespace: "kube-system"}},
+		},
+		DaemonSets: []appsv1.DaemonSet{{ObjectMeta: metav1.ObjectMeta{Name: "aws-node", Namespace: "kube-system"}}},
+	}
+	ctx := context.Background()
+
+	cluster, err := inMemory.DescribeCluster(ctx, "my-cluster")
+	assert.Nil(t, err)
+	assert.Equal(t, "my-cluster", *cluster.Name)
+	_, err = inMemory.DescribeCluster(ctx, "other-cluster")
+	assert.NotNil(t, err)
+
+	addon, err := inMemory.DescribeAddon(ctx, "my-cluster", "vpc-cni")
+	assert.Nil(t, err)
+	assert.NotNil(t, addon)
+	addon, err = inMemory.DescribeAddon(ctx, "my-cluster", "eks-pod-identity-agent")
+	assert.Nil(t, err)
+	assert.Nil(t, addon)
+
+	assert.Equal(t, []string{"pod-1", "pod-2"}, listPodNames(t, inMemory, ListOptions{}))
+	assert.Equal(t, []string{"pod-2"}, listPodNames(t, inMemory, ListOptions{Namespace: "kube-system"}))
+	assert.Empty(t, listPodNames(t, inMemory, ListOptions{Namespace: "empty"}))
+	assert.Equal(t, []string{"pod-1"}, listPodNames(t, inMemory, ListOptions{LabelSelector: "app=web"}))
+	assert.Equal(t, []string{"pod-2"}, listPodNames(t, inMemory, ListOptions{LabelSelector: "app!=web"}))
+	assert.NotNil(t, inMemory.ListPods(ctx, ListOptions{LabelSelector: "=invalid"}, func([]corev1.Pod) error { return nil }))
+
+	daemonSet, err := inMemory.GetDaemonSet(ctx, "kube-system", "aws-node")
+	assert.Nil(t, err)
+	assert.NotNil(t, daemonSet)
+	daemonSet, err = inMemory.GetDaemonSet(ctx, "default", "aws-node")
+	assert.Nil(t, err)
+	assert.Nil(t, daemonSet)
+}
+
+func listPodNames(t *testing.T, dataSource KubernetesDataSource, options ListOptions) []string {
+	var names []string
+	err := dataSource.ListPods(context.Background(), options, func(pods []corev1.Pod) error {
+		for _, pod := range pods {
+			names = append(names, pod.Name)
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	return names
+}
@@ -0,0 +1,187 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/ratelimit"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Maximum number of attempts for an AWS API call, including retries of throttled calls
+const maxAWSAttempts = 10
+
+// NewLiveDataSources returns data sources calling the AWS and Kubernetes APIs. API calls are recorded in the Stats
+// of the returned data sources.
+func NewLiveDataSources(awsConfig *aws.Config, k8sClient kubernetes.Interface) *DataSources {
+	stats := NewAPICallStats()
+	instrumentedConfig := stats.instrumentAWSConfig(*awsConfig)
+	return &DataSources{
+		Cluster:    NewLiveClusterDataSource(&instrumentedConfig),
+		IAM:        NewLiveIAMDataSource(&instrumentedConfig),
+		Kubernetes: &LiveKubernetesDataSource{K8sClient: k8sClient, PageSize: DefaultPageSize, Stats: stats},
+		Stats:      stats,
+	}
+}
+
+func NewLiveClusterDataSource(awsConfig *aws.Config) *LiveClusterDataSource {
+	return &LiveClusterDataSource{
+		EKSClient:   eks.NewFromConfig(withAdaptiveRetries(*awsConfig)),
+		Concurrency: DefaultConcurrency,
+	}
+}
+
+func NewLiveIAMDataSource(awsConfig *aws.Config) *LiveIAMDataSource {
+	// IAM is a global service
+	return &LiveIAMDataSource{IAMClient: iam.NewFromConfig(withAdaptiveRetries(*awsConfig), func(options *iam.Options) {
+		options.Region = "us-east-1"
+	})}
+}
+
+// withAdaptiveRetries makes the SDK slow down its request rate when calls are throttled, and retry them. Since we
+// make concurrent calls, throttling is expected on large clusters and accounts.
+func withAdaptiveRetries(awsConfig aws.Config) aws.Config {
+	awsConfig.Retryer = func() aws.Retryer {
+		return retry.NewAdaptiveMode(func(options *retry.AdaptiveModeOptions) {
+			options.StandardOptions = append(options.StandardOptions, func(standardOptions *retry.StandardOptions) {
+				standardOptions.MaxAttempts = maxAWSAttempts
+				// The adaptive rate limiter handles throttling, don't give up because the client-wide retry quota is exhausted
+				standardOptions.RateLimiter = ratelimit.NewTokenRateLimit(math.MaxUint32)
+			})
+		})
+	}
+	return awsConfig
+}
+
+// LiveClusterDataSource retrieves cluster information from the EKS API
+type LiveClusterDataSource struct {
+	EKSClient   *eks.Client
+	Concurrency int // maximum number of concurrent Describe* calls
+}
+
+func (m *LiveClusterDataSource) DescribeCluster(ctx context.Context, clusterName string) (*types.Cluster, error) {
+	clusterInfo, err := m.EKSClient.DescribeCluster(ctx, &eks.DescribeClusterInput{Name: &clusterName})
+	if err != nil {
+		return nil, fmt.Errorf("unable to describe cluster %s: %v", clusterName, err)
+	}
+	return clusterInfo.Cluster, nil
+}
+
+func (m *LiveClusterDataSource) ListFargateProfiles(ctx context.Context, clusterName string) ([]*types.FargateProfile, error) {
+	paginator := eks.NewListFargateProfilesPaginator(m.EKSClient, &eks.ListFargateProfilesInput{
+		ClusterName: &clusterName,
+	})
+	var profileNames []string
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list Fargate profiles: %v", err)
+		}
+		profileNames = append(profileNames, page.FargateProfileNames...)
+	}
+
+	fargateProfiles := make([]*types.FargateProfile, len(profileNames))
+	err := forEachConcurrently(ctx, m.Concurrency, len(profileNames), func(ctx context.Context, i int) error {
+		profileDetails, err := m.EKSClient.DescribeFargateProfile(ctx, &eks.DescribeFargateProfileInput{
+			ClusterName:        &clusterName,
+			FargateProfileName: &profileNames[i],
+		})
+		if err != nil {
+			return fmt.Errorf("unable to describe Fargate profile %s: %v", profileNames[i], err)
+		}
+		fargateProfiles[i] = profileDetails.FargateProfile
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fargateProfiles, nil
+}
+
+func (m *LiveClusterDataSource) ListPodIdentityAssociations(ctx context.Context, clusterName string) ([]*PodIdentityAssociation, error) {
+	paginator := eks.NewListPodIdentityAssociationsPaginator(m.EKSClient, &eks.ListPodIdentityAssociationsInput{
+		ClusterName: &clusterName,
+	})
+	var summaries []types.PodIdentityAssociationSummary
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to retrieve pod identity associations: %v", err)
+		}
+		summaries = append(summaries, page.Associations...)
+	}
+
+	// The role attached to the association is only returned by DescribePodIdentityAssociation
+	associations := make([]*PodIdentityAssociation, len(summaries))
+	err := forEachConcurrently(ctx, m.Concurrency, len(summaries), func(ctx context.Context, i int) error {
+		summary := summaries[i]
+		associationDetails, err := m.EKSClient.DescribePodIdentityAssociation(ctx, &eks.DescribePodIdentityAssociationInput{
+			AssociationId: summary.AssociationId,
+			ClusterName:   &clusterName,
+		})
+		if err != nil {
+			return fmt.Errorf("unable to describe pod identity association %s: %v", *summary.AssociationId, err)
+		}
+		associations[i] = &PodIdentityAssociation{
+			ID:                 *summary.AssociationId,
+			Namespace:          *summary.Namespace,
+			ServiceAccountName: *summary.ServiceAccount,
+			RoleArn:            *associationDetails.Association.RoleArn,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return associations, nil
+}
+
+func (m *LiveClusterDataSource) DescribeAddon(ctx context.Context, clusterName string, addonName string) (*types.Addon, error) {
+	addon, err := m.EKSClient.DescribeAddon(ctx, &eks.DescribeAddonInput{
+		ClusterName: &clusterName,
+		AddonName:   &addonName,
+	})
+	var notFoundError *types.ResourceNotFoundException
+	if errors.As(err, &notFoundError) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to describe the %s add-on: %v", addonName, err)
+	}
+	return addon.Addon, nil
+}
+
+// LiveIAMDataSource retrieves IAM roles from the IAM API
+type LiveIAMDataSource struct {
+	IAMClient *iam.Client
+}
+
+func (m *LiveIAMDataSource) ListRoles(ctx context.Context) ([]*IAMRole, error) {
+	// IAM doesn't allow fetching pages concurrently, so we fetch as many roles as possible per page
+	paginator := iam.NewListRolesPaginator(m.IAMClient, &iam.ListRolesInput{MaxItems: aws.Int32(1000)})
+	allIAMRoles := []*IAMRole{}
+	for paginator.HasMorePages() {
+		roles, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list roles in the AWS account: %v", err)
+		}
+		for _, role := range roles.Roles {
+			trustPolicy, err := url.PathUnescape(*role.AssumeRolePolicyDocument)
+			if err != nil {
+				return nil, err
+			}
+			allIAMRoles = append(allIAMRoles, &IAMRole{
+				Arn:         *role.Arn,
+				TrustPolicy: trustPolicy,
+			})
+		}
+	}
+	return allIAMRoles, nil
+}
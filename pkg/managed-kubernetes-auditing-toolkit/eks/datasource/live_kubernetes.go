@@ -0,0 +1,316 @@
+package datasource
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DefaultPageSize is the default number of Kubernetes resources retrieved per list call
+const DefaultPageSize = 500
+
+// LiveKubernetesDataSource retrieves resources from the Kubernetes API server
+type LiveKubernetesDataSource struct {
+	K8sClient kubernetes.Interface
+	PageSize  int64         // DefaultPageSize if zero
+	Stats     *APICallStats // optional
+
+	skippedNamespaces atomic.Bool // set when resources of namespaces we're not allowed to list were ignored
+}
+
+// listPageFunc retrieves a single page of resources, and returns the token to retrieve the next one
+type listPageFunc[T any] func(ctx context.Context, namespace string, options metav1.ListOptions) ([]T, string, error)
+
+func (m *LiveKubernetesDataSource) ListServiceAccounts(ctx context.Context, options ListOptions, handlePage func([]corev1.ServiceAccount) error) error {
+	err := listWithNamespaceFallback(ctx, m, "service accounts", options, func(ctx context.Context, namespace string, listOptions metav1.ListOptions) ([]corev1.ServiceAccount, string, error) {
+		m.record("ListServiceAccounts")
+		serviceAccounts, err := m.K8sClient.CoreV1().ServiceAccounts(namespace).List(ctx, listOptions)
+		if err != nil {
+			return nil, "", err
+		}
+		return serviceAccounts.Items, serviceAccounts.Continue, nil
+	}, handlePage)
+	if err != nil {
+		return fmt.Errorf("unable to list K8s service accounts: %v", err)
+	}
+	return nil
+}
+
+func (m *LiveKubernetesDataSource) ListPods(ctx context.Context, options ListOptions, handlePage func([]corev1.Pod) error) error {
+	err := listWithNamespaceFallback(ctx, m, "pods", options, func(ctx context.Context, namespace string, listOptions metav1.ListOptions) ([]corev1.Pod, string, error) {
+		m.record("ListPods")
+		pods, err := m.K8sClient.CoreV1().Pods(namespace).List(ctx, listOptions)
+		if err != nil {
+			return nil, "", err
+		}
+		return pods.Items, pods.Continue, nil
+	}, handlePage)
+	if err != nil {
+		return fmt.Errorf("unable to list Pods: %v", err)
+	}
+	return nil
+}
+
+func (m *LiveKubernetesDataSource) ListConfigMaps(ctx context.Context, options ListOptions, handlePage func([]corev1.ConfigMap) error) error {
+	err := listWithNamespaceFallback(ctx, m, "ConfigMaps", options, func(ctx context.Context, namespace string, listOptions metav1.ListOptions) ([]corev1.ConfigMap, string, error) {
+		m.record("ListConfigMaps")
+		configMaps, err := m.K8sClient.CoreV1().ConfigMaps(namespace).List(ctx, listOptions)
+		if err != nil {
+			return nil, "", err
+		}
+		return configMaps.Items, configMaps.Continue, nil
+	}, handlePage)
+	if err != nil {
+		return fmt.Errorf("unable to list ConfigMaps: %v", err)
+	}
+	return nil
+}
+
+func (m *LiveKubernetesDataSource) ListSecrets(ctx context.Context, options ListOptions, handlePage func([]corev1.Secret) error) error {
+	err := listWithNamespaceFallback(ctx, m, "Secrets", options, func(ctx context.Context, namespace string, listOptions metav1.ListOptions) ([]corev1.Secret, string, error) {
+		m.record("ListSecrets")
+		secrets, err := m.K8sClient.CoreV1().Secrets(namespace).List(ctx, listOptions)
+		if err != nil {
+			return nil, "", err
+		}
+		return secrets.Items, secrets.Continue, nil
+	}, handlePage)
+	if err != nil {
+		return fmt.Errorf("unable to list Secrets: %v", err)
+	}
+	return nil
+}
+
+func (m *LiveKubernetesDataSource) ListDeployments(ctx context.Context, options ListOptions, handlePage func([]appsv1.Deployment) error) error {
+	err := listWithNamespaceFallback(ctx, m, "Deployments", options, func(ctx context.Context, namespace string, listOptions metav1.ListOptions) ([]appsv1.Deployment, string, error) {
+		m.record("ListDeployments")
+		deployments, err := m.K8sClient.AppsV1().Deployments(namespace).List(ctx, listOptions)
+		if err != nil {
+			return nil, "", err
+		}
+		return deployments.Items, deployments.Continue, nil
+	}, handlePage)
+	if err != nil {
+		return fmt.Errorf("unable to list Deployments: %v", err)
+	}
+	return nil
+}
+
+func (m *LiveKubernetesDataSource) ListStatefulSets(ctx context.Context, options ListOptions, handlePage func([]appsv1.StatefulSet) error) error {
+	err := listWithNamespaceFallback(ctx, m, "StatefulSets", options, func(ctx context.Context, namespace string, listOptions metav1.ListOptions) ([]appsv1.StatefulSet, string, error) {
+		m.record("ListStatefulSets")
+		statefulSets, err := m.K8sClient.AppsV1().StatefulSets(namespace).List(ctx, listOptions)
+		if err != nil {
+			return nil, "", err
+		}
+		return statefulSets.Items, statefulSets.Continue, nil
+	}, handlePage)
+	if err != nil {
+		return fmt.Errorf("unable to list StatefulSets: %v", err)
+	}
+	return nil
+}
+
+func (m *LiveKubernetesDataSource) ListDaemonSets(ctx context.Context, options ListOptions, handlePage func([]appsv1.DaemonSet) error) error {
+	err := listWithNamespaceFallback(ctx, m, "DaemonSets", options, func(ctx context.Context, namespace string, listOptions metav1.ListOptions) ([]appsv1.DaemonSet, string, error) {
+		m.record("ListDaemonSets")
+		daemonSets, err := m.K8sClient.AppsV1().DaemonSets(namespace).List(ctx, listOptions)
+		if err != nil {
+			return nil, "", err
+		}
+		return daemonSets.Items, daemonSets.Continue, nil
+	}, handlePage)
+	if err != nil {
+		return fmt.Errorf("unable to list DaemonSets: %v", err)
+	}
+	return nil
+}
+
+func (m *LiveKubernetesDataSource) ListReplicaSets(ctx context.Context, options ListOptions, handlePage func([]appsv1.ReplicaSet) error) error {
+	err := listWithNamespaceFallback(ctx, m, "ReplicaSets", options, func(ctx context.Context, namespace string, listOptions metav1.ListOptions) ([]appsv1.ReplicaSet, string, error) {
+		m.record("ListReplicaSets")
+		replicaSets, err := m.K8sClient.AppsV1().ReplicaSets(namespace).List(ctx, listOptions)
+		if err != nil {
+			return nil, "", err
+		}
+		return replicaSets.Items, replicaSets.Continue, nil
+	}, handlePage)
+	if err != nil {
+		return fmt.Errorf("unable to list ReplicaSets: %v", err)
+	}
+	return nil
+}
+
+func (m *LiveKubernetesDataSource) ListJobs(ctx context.Context, options ListOptions, handlePage func([]batchv1.Job) error) error {
+	err := listWithNamespaceFallback(ctx, m, "Jobs", options, func(ctx context.Context, namespace string, listOptions metav1.ListOptions) ([]batchv1.Job, string, error) {
+		m.record("ListJobs")
+		jobs, err := m.K8sClient.BatchV1().Jobs(namespace).List(ctx, listOptions)
+		if err != nil {
+			return nil, "", err
+		}
+		return jobs.Items, jobs.Continue, nil
+	}, handlePage)
+	if err != nil {
+		return fmt.Errorf("unable to list Jobs: %v", err)
+	}
+	return nil
+}
+
+func (m *LiveKubernetesDataSource) ListCronJobs(ctx context.Context, options ListOptions, handlePage func([]batchv1.CronJob) error) error {
+	err := listWithNamespaceFallback(ctx, m, "CronJobs", options, func(ctx context.Context, namespace string, listOptions metav1.ListOptions) ([]batchv1.CronJob, string, error) {
+		m.record("ListCronJobs")
+		cronJobs, err := m.K8sClient.BatchV1().CronJobs(namespace).List(ctx, listOptions)
+		if err != nil {
+			return nil, "", err
+		}
+		return cronJobs.Items, cronJobs.Continue, nil
+	}, handlePage)
+	if err != nil {
+		return fmt.Errorf("unable to list CronJobs: %v", err)
+	}
+	return nil
+}
+
+func (m *LiveKubernetesDataSource) GetDaemonSet(ctx context.Context, namespace string, name string) (*appsv1.DaemonSet, error) {
+	m.record("GetDaemonSet")
+	daemonSet, err := m.K8sClient.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to retrieve the %s DaemonSet: %v", name, err)
+	}
+	return daemonSet, nil
+}
+
+func (m *LiveKubernetesDataSource) GetConfigMap(ctx context.Context, namespace string, name string) (*corev1.ConfigMap, error) {
+	m.record("GetConfigMap")
+	configMap, err := m.K8sClient.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to retrieve the %s ConfigMap: %v", name, err)
+	}
+	return configMap, nil
+}
+
+func (m *LiveKubernetesDataSource) GetSecret(ctx context.Context, namespace string, name string) (*corev1.Secret, error) {
+	m.record("GetSecret")
+	secret, err := m.K8sClient.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to retrieve the %s Secret: %v", name, err)
+	}
+	return secret, nil
+}
+
+// SkippedNamespaces determines if resources of some namespaces were ignored because we're not allowed to list them,
+// so that the results of list calls are incomplete
+func (m *LiveKubernetesDataSource) SkippedNamespaces() bool {
+	return m.skippedNamespaces.Load()
+}
+
+func (m *LiveKubernetesDataSource) record(operation string) {
+	if m.Stats != nil {
+		m.Stats.record("k8s:" + operation)
+	}
+}
+
+func (m *LiveKubernetesDataSource) pageSize() int64 {
+	if m.PageSize <= 0 {
+		return DefaultPageSize
+	}
+	return m.PageSize
+}
+
+// listWithNamespaceFallback lists resources in all namespaces if possible. When we're not allowed to list them
+// cluster-wide, it falls back to listing them in each namespace we have access to.
+func listWithNamespaceFallback[T any](ctx context.Context, m *LiveKubernetesDataSource, resourceName string, options ListOptions, listPage listPageFunc[T], handlePage func([]T) error) error {
+	err := listAllPages(ctx, m.pageSize(), options, listPage, handlePage)
+	if options.Namespace != "" || !k8serrors.IsForbidden(err) {
+		return err
+	}
+
+	log.Printf("Not allowed to list %s in all namespaces, listing them namespace by namespace", resourceName)
+	namespaces, namespacesErr := m.listNamespaceNames(ctx)
+	if namespacesErr != nil {
+		return fmt.Errorf("%v (unable to list namespaces to fall back to listing them namespace by namespace: %v)", err, namespacesErr)
+	}
+	var forbiddenNamespaces []string
+	for _, namespace := range namespaces {
+		namespaceOptions := options
+		namespaceOptions.Namespace = namespace
+		namespaceErr := listAllPages(ctx, m.pageSize(), namespaceOptions, listPage, handlePage)
+		if k8serrors.IsForbidden(namespaceErr) {
+			forbiddenNamespaces = append(forbiddenNamespaces, namespace)
+		} else if namespaceErr != nil {
+			return namespaceErr
+		}
+	}
+	if len(forbiddenNamespaces) == len(namespaces) {
+		return err
+	}
+	if len(forbiddenNamespaces) > 0 {
+		m.skippedNamespaces.Store(true)
+		log.Printf("[WARNING] Not allowed to list %s in namespaces %s, ignoring them", resourceName, strings.Join(forbiddenNamespaces, ", "))
+	}
+	return nil
+}
+
+// listAllPages retrieves resources page by page, so that large clusters don't return huge responses
+func listAllPages[T any](ctx context.Context, pageSize int64, options ListOptions, listPage listPageFunc[T], handlePage func([]T) error) error {
+	listOptions := metav1.ListOptions{Limit: pageSize, LabelSelector: options.LabelSelector, FieldSelector: options.FieldSelector}
+	for {
+		items, continueToken, err := listPage(ctx, options.Namespace, listOptions)
+		if err != nil {
+			if k8serrors.IsResourceExpired(err) {
+				return fmt.Errorf("the list operation took too long and its continue token expired, try again: %v", err)
+			}
+			return err
+		}
+		if len(items) > 0 {
+			if err := handlePage(items); err != nil {
+				return err
+			}
+		}
+		if continueToken == "" {
+			return nil
+		}
+		listOptions.Continue = continueToken
+	}
+}
+
+func (m *LiveKubernetesDataSource) ListNamespaces(ctx context.Context, handlePage func([]corev1.Namespace) error) error {
+	err := listAllPages(ctx, m.pageSize(), ListOptions{}, func(ctx context.Context, _ string, listOptions metav1.ListOptions) ([]corev1.Namespace, string, error) {
+		m.record("ListNamespaces")
+		namespaces, err := m.K8sClient.CoreV1().Namespaces().List(ctx, listOptions)
+		if err != nil {
+			return nil, "", err
+		}
+		return namespaces.Items, namespaces.Continue, nil
+	}, handlePage)
+	if err != nil {
+		return fmt.Errorf("unable to list namespaces: %v", err)
+	}
+	return nil
+}
+
+func (m *LiveKubernetesDataSource) listNamespaceNames(ctx context.Context) ([]string, error) {
+	var namespaceNames []string
+	err := m.ListNamespaces(ctx, func(namespaces []corev1.Namespace) error {
+		for _, namespace := range namespaces {
+			namespaceNames = append(namespaceNames, namespace.Name)
+		}
+		return nil
+	})
+	return namespaceNames, err
+}
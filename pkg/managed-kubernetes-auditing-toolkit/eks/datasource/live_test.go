@@ -0,0 +1,205 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestLiveKubernetesDataSource(t *testing.T) {
+	dataSource := &LiveKubernetesDataSource{K8sClient: fake.NewSimpleClientset(
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "sa", Namespace: "default"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "other"}},
+		&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "aws-node", Namespace: "kube-system"}},
+	)}
+	ctx := context.Background()
+
+	var serviceAccounts []corev1.ServiceAccount
+	err := dataSource.ListServiceAccounts(ctx, ListOptions{}, func(page []corev1.ServiceAccount) error {
+		serviceAccounts = append(serviceAccounts, page...)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Len(t, serviceAccounts, 1)
+
+	assert.Equal(t, []string{"pod"}, listPodNames(t, dataSource, ListOptions{Namespace: "other"}))
+
+	daemonSet, err := dataSource.GetDaemonSet(ctx, "kube-system", "aws-node")
+	assert.Nil(t, err)
+	assert.Equal(t, "aws-node", daemonSet.Name)
+
+	daemonSet, err = dataSource.GetDaemonSet(ctx, "kube-system", "does-not-exist")
+	assert.Nil(t, err)
+	assert.Nil(t, daemonSet)
+}
+
+func TestListAllPages(t *testing.T) {
+	var pods []corev1.Pod
+	for i := 0; i < 25; i++ {
+		pods = append(pods, corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("pod-%d", i)}})
+	}
+	// The fake clientset doesn't support pagination, so we simulate the API server
+	var requestedLimits []int64
+	listPage := func(ctx context.Context, namespace string, options metav1.ListOptions) ([]corev1.Pod, string, error) {
+		requestedLimits = append(requestedLimits, options.Limit)
+		start := 0
+		if options.Continue != "" {
+			start, _ = strconv.Atoi(options.Continue)
+		}
+		end := start + int(options.Limit)
+		if end >= len(pods) {
+			return pods[start:], "", nil
+		}
+		return pods[start:end], strconv.Itoa(end), nil
+	}
+
+	var pageSizes []int
+	err := listAllPages(context.Background(), 10, ListOptions{}, listPage, func(page []corev1.Pod) error {
+		pageSizes = append(pageSizes, len(page))
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{10, 10, 5}, pageSizes)
+	assert.Equal(t, []int64{10, 10, 10}, requestedLimits)
+
+	// Errors from the page handler stop the listing
+	err = listAllPages(context.Background(), 10, ListOptions{}, listPage, func(page []corev1.Pod) error {
+		return errors.New("stop")
+	})
+	assert.EqualError(t, err, "stop")
+}
+
+func TestLiveKubernetesDataSourceFallsBackToNamespaces(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "allowed-1"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "allowed-2"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "forbidden"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "allowed-1"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-2", Namespace: "allowed-2"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-3", Namespace: "forbidden"}},
+	)
+	client.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		namespace := action.GetNamespace()
+		if namespace == "" || namespace == "forbidden" {
+			return true, nil, k8serrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "", errors.New("forbidden"))
+		}
+		return false, nil, nil
+	})
+	dataSource := &LiveKubernetesDataSource{K8sClient: client}
+
+	assert.False(t, dataSource.SkippedNamespaces())
+	assert.ElementsMatch(t, []string{"pod-1", "pod-2"}, listPodNames(t, dataSource, ListOptions{}))
+	assert.True(t, dataSource.SkippedNamespaces())
+
+	// No fallback when listing a specific namespace
+	err := dataSource.ListPods(context.Background(), ListOptions{Namespace: "forbidden"}, func([]corev1.Pod) error { return nil })
+	assert.NotNil(t, err)
+}
+
+func TestLiveKubernetesDataSourceFailsWhenAllNamespacesAreForbidden(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "forbidden"}})
+	client.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8serrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "", errors.New("forbidden"))
+	})
+	dataSource := &LiveKubernetesDataSource{K8sClient: client}
+	err := dataSource.ListPods(context.Background(), ListOptions{}, func([]corev1.Pod) error { return nil })
+	assert.NotNil(t, err)
+}
+
+func TestLiveClusterDataSourceDescribesAssociationsConcurrently(t *testing.T) {
+	const numAssociations = 25
+	var inFlight, maxInFlight, describeCalls int32
+	var throttledOnce sync.Once
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/clusters/my-cluster/pod-identity-associations" {
+			var associations []string
+			for i := 0; i < numAssociations; i++ {
+				associations = append(associations, fmt.Sprintf(`{"associationId": "a-%d", "namespace": "default", "serviceAccount": "sa-%d"}`, i, i))
+			}
+			fmt.Fprintf(w, `{"associations": [%s]}`, strings.Join(associations, ","))
+			return
+		}
+
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			previousMax := atomic.LoadInt32(&maxInFlight)
+			if current <= previousMax || atomic.CompareAndSwapInt32(&maxInFlight, previousMax, current) {
+				break
+			}
+		}
+		atomic.AddInt32(&describeCalls, 1)
+		time.Sleep(2 * time.Millisecond)
+
+		throttled := false
+		throttledOnce.Do(func() { throttled = true })
+		if throttled {
+			w.Header().Set("X-Amzn-ErrorType", "ThrottlingException")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"message": "Rate exceeded"}`)
+			return
+		}
+		id := path.Base(r.URL.Path)
+		fmt.Fprintf(w, `{"association": {"associationId": "%s", "roleArn": "arn:aws:iam::111111111111:role/%s"}}`, id, id)
+	}))
+	defer server.Close()
+
+	dataSources := NewLiveDataSources(&aws.Config{
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("AKIA", "secret", ""),
+		BaseEndpoint: aws.String(server.URL),
+	}, fake.NewSimpleClientset())
+	dataSources.Cluster.(*LiveClusterDataSource).Concurrency = 4
+
+	associations, err := dataSources.Cluster.ListPodIdentityAssociations(context.Background(), "my-cluster")
+	assert.Nil(t, err)
+	assert.Len(t, associations, numAssociations)
+	for i, association := range associations {
+		// Results keep the order of the list call
+		assert.Equal(t, fmt.Sprintf("a-%d", i), association.ID)
+		assert.Equal(t, fmt.Sprintf("sa-%d", i), association.ServiceAccountName)
+		assert.Equal(t, fmt.Sprintf("arn:aws:iam::111111111111:role/a-%d", i), association.RoleArn)
+	}
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxInFlight), int32(4))
+
+	// The throttled call is retried, but only counted once
+	assert.Equal(t, int32(numAssociations+1), atomic.LoadInt32(&describeCalls))
+	assert.Equal(t, numAssociations, dataSources.Stats.Calls("eks:DescribePodIdentityAssociation"))
+	assert.Equal(t, 1, dataSources.Stats.Throttled("eks:DescribePodIdentityAssociation"))
+	assert.Equal(t, 1, dataSources.Stats.Calls("eks:ListPodIdentityAssociations"))
+	assert.Equal(t, numAssociations+1, dataSources.Stats.TotalCalls())
+}
+
+func TestLiveKubernetesDataSourceRecordsAPICalls(t *testing.T) {
+	stats := NewAPICallStats()
+	dataSource := &LiveKubernetesDataSource{K8sClient: fake.NewSimpleClientset(), Stats: stats}
+	_ = listPodNames(t, dataSource, ListOptions{})
+	_ = listPodNames(t, dataSource, ListOptions{Namespace: "default"})
+	_, _ = dataSource.GetDaemonSet(context.Background(), "kube-system", "aws-node")
+	assert.Equal(t, 2, stats.Calls("k8s:ListPods"))
+	assert.Equal(t, "k8s:GetDaemonSet=1, k8s:ListPods=2", stats.String())
+}
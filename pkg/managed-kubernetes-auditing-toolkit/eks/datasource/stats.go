@@ -0,0 +1,111 @@
+package datasource
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// APICallStats counts the API calls made by live data sources. It's safe for concurrent use.
+type APICallStats struct {
+	mutex     sync.Mutex
+	calls     map[string]int
+	throttled map[string]int
+}
+
+func NewAPICallStats() *APICallStats {
+	return &APICallStats{calls: map[string]int{}, throttled: map[string]int{}}
+}
+
+func (m *APICallStats) record(operation string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.calls[operation]++
+}
+
+func (m *APICallStats) recordThrottle(operation string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.throttled[operation]++
+}
+
+// Calls returns the number of API calls made for an operation, e.g. "eks:DescribeCluster"
+func (m *APICallStats) Calls(operation string) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.calls[operation]
+}
+
+// Throttled returns the number of attempts for an operation that were throttled, and retried
+func (m *APICallStats) Throttled(operation string) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.throttled[operation]
+}
+
+// TotalCalls returns the total number of API calls, excluding retries
+func (m *APICallStats) TotalCalls() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	total := 0
+	for _, count := range m.calls {
+		total += count
+	}
+	return total
+}
+
+// String returns a human-readable summary, e.g. "eks:DescribeCluster=1, iam:ListRoles=3 (1 throttled)"
+func (m *APICallStats) String() string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	operations := make([]string, 0, len(m.calls))
+	for operation := range m.calls {
+		operations = append(operations, operation)
+	}
+	sort.Strings(operations)
+	summaries := make([]string, 0, len(operations))
+	for _, operation := range operations {
+		summary := fmt.Sprintf("%s=%d", operation, m.calls[operation])
+		if throttled := m.throttled[operation]; throttled > 0 {
+			summary += fmt.Sprintf(" (%d throttled)", throttled)
+		}
+		summaries = append(summaries, summary)
+	}
+	return strings.Join(summaries, ", ")
+}
+
+// instrumentAWSConfig returns a copy of an AWS configuration recording API calls and throttled attempts
+func (m *APICallStats) instrumentAWSConfig(awsConfig aws.Config) aws.Config {
+	throttles := retry.IsErrorThrottles(retry.DefaultThrottles)
+	awsConfig.APIOptions = append(append([]func(*middleware.Stack) error{}, awsConfig.APIOptions...), func(stack *middleware.Stack) error {
+		countCalls := middleware.InitializeMiddlewareFunc("MKATCountAPICalls", func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			m.record(awsOperationName(ctx))
+			return next.HandleInitialize(ctx, in)
+		})
+		if err := stack.Initialize.Add(countCalls, middleware.After); err != nil {
+			return err
+		}
+
+		// Placed after the retry middleware, so that it sees every attempt
+		countThrottles := middleware.FinalizeMiddlewareFunc("MKATCountThrottles", func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+			out, metadata, err := next.HandleFinalize(ctx, in)
+			if err != nil && throttles.IsErrorThrottle(err) == aws.TrueTernary {
+				m.recordThrottle(awsOperationName(ctx))
+			}
+			return out, metadata, err
+		})
+		return stack.Finalize.Insert(countThrottles, (&retry.Attempt{}).ID(), middleware.After)
+	})
+	return awsConfig
+}
+
+func awsOperationName(ctx context.Context) string {
+	return strings.ToLower(awsmiddleware.GetServiceID(ctx)) + ":" + awsmiddleware.GetOperationName(ctx)
+}
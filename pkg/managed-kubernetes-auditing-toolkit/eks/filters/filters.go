@@ -0,0 +1,205 @@
+package filters
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/datasource"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Filters restricts the Kubernetes resources and IAM roles that commands analyze. The zero value matches everything.
+type Filters struct {
+	IncludeNamespaces           []string `json:"includeNamespaces,omitempty"`           // glob patterns, e.g. "team-*"
+	ExcludeNamespaces           []string `json:"excludeNamespaces,omitempty"`           // glob patterns
+	PodLabelSelector            string   `json:"podLabelSelector,omitempty"`            // e.g. "app=web,tier!=cache"
+	ServiceAccountLabelSelector string   `json:"serviceAccountLabelSelector,omitempty"` // e.g. "team=payments"
+	IncludeRoles                []string `json:"includeRoles,omitempty"`                // regexes, matched against role ARNs and names
+	ExcludeRoles                []string `json:"excludeRoles,omitempty"`                // regexes
+}
+
+// Validate checks the syntax of the filters. Invalid patterns and selectors never match when using filters that
+// were not validated.
+func (m *Filters) Validate() error {
+	for _, pattern := range append(append([]string{}, m.IncludeNamespaces...), m.ExcludeNamespaces...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid namespace pattern %s: %v", pattern, err)
+		}
+	}
+	if _, err := labels.Parse(m.PodLabelSelector); err != nil {
+		return fmt.Errorf("invalid pod label selector %s: %v", m.PodLabelSelector, err)
+	}
+	if _, err := labels.Parse(m.ServiceAccountLabelSelector); err != nil {
+		return fmt.Errorf("invalid service account label selector %s: %v", m.ServiceAccountLabelSelector, err)
+	}
+	for _, pattern := range append(append([]string{}, m.IncludeRoles...), m.ExcludeRoles...) {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid role pattern %s: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// IsEmpty returns true if the filters match everything
+func (m *Filters) IsEmpty() bool {
+	return m == nil || (len(m.IncludeNamespaces) == 0 && len(m.ExcludeNamespaces) == 0 && m.PodLabelSelector == "" &&
+		m.ServiceAccountLabelSelector == "" && len(m.IncludeRoles) == 0 && len(m.ExcludeRoles) == 0)
+}
+
+// MatchesNamespace determines if resources of a namespace should be analyzed
+func (m *Filters) MatchesNamespace(namespace string) bool {
+	if m == nil {
+		return true
+	}
+	if len(m.IncludeNamespaces) > 0 && !matchesAnyGlob(m.IncludeNamespaces, namespace) {
+		return false
+	}
+	return !matchesAnyGlob(m.ExcludeNamespaces, namespace)
+}
+
+// MatchesRole determines if an IAM role should be analyzed, based on its ARN or name
+func (m *Filters) MatchesRole(roleArn string) bool {
+	if m == nil {
+		return true
+	}
+	candidates := []string{roleArn}
+	if parsedArn, err := arn.Parse(roleArn); err == nil {
+		candidates = append(candidates, parsedArn.Resource[strings.LastIndex(parsedArn.Resource, "/")+1:])
+	}
+	if len(m.IncludeRoles) > 0 && !matchesAnyRegex(m.IncludeRoles, candidates) {
+		return false
+	}
+	return !matchesAnyRegex(m.ExcludeRoles, candidates)
+}
+
+// MatchesPodIdentityAssociation determines if a Pod Identity association should be analyzed
+func (m *Filters) MatchesPodIdentityAssociation(namespace string, roleArn string) bool {
+	return m.MatchesNamespace(namespace) && m.MatchesRole(roleArn)
+}
+
+// MatchesPod determines if a pod should be analyzed
+func (m *Filters) MatchesPod(pod *corev1.Pod) bool {
+	if m == nil {
+		return true
+	}
+	return m.MatchesNamespace(pod.Namespace) && matchesLabels(m.PodLabelSelector, pod.Labels)
+}
+
+// MatchesPodTemplate determines if the pod template of a workload should be analyzed, i.e. if its pods would be
+func (m *Filters) MatchesPodTemplate(namespace string, template *corev1.PodTemplateSpec) bool {
+	if m == nil {
+		return true
+	}
+	return m.MatchesNamespace(namespace) && matchesLabels(m.PodLabelSelector, template.Labels)
+}
+
+// MatchesServiceAccount determines if a service account should be analyzed
+func (m *Filters) MatchesServiceAccount(serviceAccount *corev1.ServiceAccount) bool {
+	if m == nil {
+		return true
+	}
+	return m.MatchesNamespace(serviceAccount.Namespace) && matchesLabels(m.ServiceAccountLabelSelector, serviceAccount.Labels)
+}
+
+func matchesLabels(selector string, objectLabels map[string]string) bool {
+	parsedSelector, err := labels.Parse(selector)
+	return err == nil && parsedSelector.Matches(labels.Set(objectLabels))
+}
+
+// ResolveNamespaces returns the namespaces to list resources from, so that the Kubernetes API server only returns
+// resources we're interested in. It returns nil when resources should be listed in all namespaces, including when
+// namespace patterns can't be resolved because we're not allowed to list namespaces.
+func (m *Filters) ResolveNamespaces(ctx context.Context, dataSource datasource.KubernetesDataSource) ([]string, error) {
+	if m == nil || len(m.IncludeNamespaces) == 0 {
+		return nil, nil
+	}
+
+	// Without wildcards, we know the namespaces upfront
+	if !hasGlob(m.IncludeNamespaces) {
+		namespaces := []string{}
+		for _, namespace := range m.IncludeNamespaces {
+			if m.MatchesNamespace(namespace) {
+				namespaces = append(namespaces, namespace)
+			}
+		}
+		return namespaces, nil
+	}
+
+	var namespaces []string
+	err := dataSource.ListNamespaces(ctx, func(page []corev1.Namespace) error {
+		for _, namespace := range page {
+			if m.MatchesNamespace(namespace.Name) {
+				namespaces = append(namespaces, namespace.Name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		log.Printf("[WARNING] Unable to list namespaces matching %s, filtering resources of all namespaces instead: %v", strings.Join(m.IncludeNamespaces, ", "), err)
+		return nil, nil
+	}
+	if namespaces == nil {
+		// No namespace matches, but nil would mean all namespaces
+		namespaces = []string{}
+	}
+	return namespaces, nil
+}
+
+// ListOptions returns the options to list resources in a namespace returned by ResolveNamespaces, or in all
+// namespaces if empty
+func (m *Filters) ListOptions(namespace string, labelSelector string) datasource.ListOptions {
+	options := datasource.ListOptions{Namespace: namespace, LabelSelector: labelSelector}
+	if m == nil || namespace != "" {
+		return options
+	}
+	// When listing all namespaces, the API server can exclude namespaces without wildcards
+	var requirements []fields.Selector
+	for _, pattern := range m.ExcludeNamespaces {
+		if !hasGlob([]string{pattern}) {
+			requirements = append(requirements, fields.OneTermNotEqualSelector("metadata.namespace", pattern))
+		}
+	}
+	if len(requirements) > 0 {
+		options.FieldSelector = fields.AndSelectors(requirements...).String()
+	}
+	return options
+}
+
+func hasGlob(patterns []string) bool {
+	for _, pattern := range patterns {
+		if strings.ContainsAny(pattern, "*?[\\") {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAnyGlob(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matches, err := filepath.Match(pattern, value); err == nil && matches {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAnyRegex(patterns []string, candidates []string) bool {
+	for _, pattern := range patterns {
+		for _, candidate := range candidates {
+			if matches, err := regexp.MatchString(pattern, candidate); err == nil && matches {
+				return true
+			}
+		}
+	}
+	return false
+}
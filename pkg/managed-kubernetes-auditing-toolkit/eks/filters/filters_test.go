@@ -0,0 +1,137 @@
+package filters
+
+import (
+	"context"
+	"testing"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/datasource"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidate(t *testing.T) {
+	scenarios := []struct {
+		Name          string
+		Filters       Filters
+		ExpectedError string
+	}{
+		{Name: "no filters", Filters: Filters{}},
+		{Name: "valid filters", Filters: Filters{IncludeNamespaces: []string{"team-*"}, PodLabelSelector: "app in (web, api)", IncludeRoles: []string{"^app-.*$"}}},
+		{Name: "invalid namespace pattern", Filters: Filters{ExcludeNamespaces: []string{"team-["}}, ExpectedError: "invalid namespace pattern"},
+		{Name: "invalid pod label selector", Filters: Filters{PodLabelSelector: "app in web"}, ExpectedError: "invalid pod label selector"},
+		{Name: "invalid service account label selector", Filters: Filters{ServiceAccountLabelSelector: "=="}, ExpectedError: "invalid service account label selector"},
+		{Name: "invalid role pattern", Filters: Filters{ExcludeRoles: []string{"app-("}}, ExpectedError: "invalid role pattern"},
+	}
+	for _, scenario := range scenarios {
+		t.Run(scenario.Name, func(t *testing.T) {
+			err := scenario.Filters.Validate()
+			if scenario.ExpectedError == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.ErrorContains(t, err, scenario.ExpectedError)
+			}
+		})
+	}
+}
+
+func TestMatchesNamespace(t *testing.T) {
+	scenarios := []struct {
+		Name      string
+		Filters   *Filters
+		Namespace string
+		Expected  bool
+	}{
+		{Name: "nil filters", Filters: nil, Namespace: "default", Expected: true},
+		{Name: "no filters", Filters: &Filters{}, Namespace: "default", Expected: true},
+		{Name: "included namespace", Filters: &Filters{IncludeNamespaces: []string{"default"}}, Namespace: "default", Expected: true},
+		{Name: "not included namespace", Filters: &Filters{IncludeNamespaces: []string{"default"}}, Namespace: "other", Expected: false},
+		{Name: "included pattern", Filters: &Filters{IncludeNamespaces: []string{"team-*"}}, Namespace: "team-a", Expected: true},
+		{Name: "excluded pattern", Filters: &Filters{ExcludeNamespaces: []string{"kube-*"}}, Namespace: "kube-system", Expected: false},
+		{Name: "exclusion wins", Filters: &Filters{IncludeNamespaces: []string{"team-*"}, ExcludeNamespaces: []string{"*-staging"}}, Namespace: "team-a-staging", Expected: false},
+	}
+	for _, scenario := range scenarios {
+		t.Run(scenario.Name, func(t *testing.T) {
+			assert.Equal(t, scenario.Expected, scenario.Filters.MatchesNamespace(scenario.Namespace))
+		})
+	}
+}
+
+func TestMatchesRole(t *testing.T) {
+	const roleArn = "arn:aws:iam::123456789012:role/service-role/app-role"
+	scenarios := []struct {
+		Name     string
+		Filters  *Filters
+		Expected bool
+	}{
+		{Name: "nil filters", Filters: nil, Expected: true},
+		{Name: "included role name", Filters: &Filters{IncludeRoles: []string{"^app-"}}, Expected: true},
+		{Name: "included role ARN", Filters: &Filters{IncludeRoles: []string{"^arn:aws:iam::123456789012:"}}, Expected: true},
+		{Name: "not included role", Filters: &Filters{IncludeRoles: []string{"^admin-"}}, Expected: false},
+		{Name: "excluded role", Filters: &Filters{ExcludeRoles: []string{"app-role$"}}, Expected: false},
+		{Name: "invalid pattern never matches", Filters: &Filters{IncludeRoles: []string{"app-("}}, Expected: false},
+	}
+	for _, scenario := range scenarios {
+		t.Run(scenario.Name, func(t *testing.T) {
+			assert.Equal(t, scenario.Expected, scenario.Filters.MatchesRole(roleArn))
+		})
+	}
+}
+
+func TestMatchesPodAndServiceAccount(t *testing.T) {
+	filters := &Filters{PodLabelSelector: "app=web", ServiceAccountLabelSelector: "team"}
+	assert.True(t, filters.MatchesPod(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web"}}}))
+	assert.False(t, filters.MatchesPod(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "api"}}}))
+	assert.True(t, filters.MatchesServiceAccount(&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"team": "a"}}}))
+	assert.False(t, filters.MatchesServiceAccount(&corev1.ServiceAccount{}))
+}
+
+func TestResolveNamespaces(t *testing.T) {
+	dataSource := &datasource.InMemory{Namespaces: []corev1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "team-b-staging"}},
+	}}
+	scenarios := []struct {
+		Name     string
+		Filters  *Filters
+		Expected []string
+	}{
+		{Name: "nil filters", Filters: nil, Expected: nil},
+		{Name: "only exclusions", Filters: &Filters{ExcludeNamespaces: []string{"default"}}, Expected: nil},
+		{Name: "literal namespaces", Filters: &Filters{IncludeNamespaces: []string{"team-a", "unknown"}}, Expected: []string{"team-a", "unknown"}},
+		{Name: "literal excluded namespace", Filters: &Filters{IncludeNamespaces: []string{"team-a"}, ExcludeNamespaces: []string{"team-*"}}, Expected: []string{}},
+		{Name: "patterns", Filters: &Filters{IncludeNamespaces: []string{"team-*"}, ExcludeNamespaces: []string{"*-staging"}}, Expected: []string{"team-a", "team-b"}},
+		{Name: "no match", Filters: &Filters{IncludeNamespaces: []string{"prod-*"}}, Expected: []string{}},
+	}
+	for _, scenario := range scenarios {
+		t.Run(scenario.Name, func(t *testing.T) {
+			namespaces, err := scenario.Filters.ResolveNamespaces(context.Background(), dataSource)
+			assert.Nil(t, err)
+			assert.Equal(t, scenario.Expected, namespaces)
+		})
+	}
+}
+
+func TestListPushesDownFilters(t *testing.T) {
+	filters := &Filters{ExcludeNamespaces: []string{"kube-system", "*-staging"}}
+	assert.Equal(t, datasource.ListOptions{LabelSelector: "app=web", FieldSelector: "metadata.namespace!=kube-system"}, filters.ListOptions("", "app=web"))
+	assert.Equal(t, datasource.ListOptions{Namespace: "default"}, filters.ListOptions("default", ""))
+
+	dataSource := &datasource.InMemory{Pods: []corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Labels: map[string]string{"app": "web"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "kube-system", Labels: map[string]string{"app": "web"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "app-staging", Labels: map[string]string{"app": "web"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default", Labels: map[string]string{"app": "api"}}},
+	}}
+	var pods []string
+	err := List(context.Background(), filters, nil, "app=web", dataSource.ListPods, func(page []corev1.Pod) error {
+		for _, pod := range page {
+			pods = append(pods, pod.Namespace+"/"+pod.Name)
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"default/web"}, pods)
+}
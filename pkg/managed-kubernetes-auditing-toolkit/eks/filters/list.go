@@ -0,0 +1,41 @@
+package filters
+
+import (
+	"context"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/datasource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ListFunc is the signature of the list methods of datasource.KubernetesDataSource
+type ListFunc[T any] func(ctx context.Context, options datasource.ListOptions, handlePage func([]T) error) error
+
+// List retrieves resources from the namespaces returned by ResolveNamespaces (nil for all namespaces), page by page.
+// Namespaces and label selectors are pushed down to the data source, and namespace patterns that the Kubernetes API
+// can't evaluate are applied to each page.
+func List[T any, PT interface {
+	*T
+	metav1.Object
+}](ctx context.Context, filters *Filters, namespaces []string, labelSelector string, list ListFunc[T], handlePage func([]T) error) error {
+	if namespaces == nil {
+		namespaces = []string{""}
+	}
+	for _, namespace := range namespaces {
+		err := list(ctx, filters.ListOptions(namespace, labelSelector), func(page []T) error {
+			var matching []T
+			for i := range page {
+				if filters.MatchesNamespace(PT(&page[i]).GetNamespace()) {
+					matching = append(matching, page[i])
+				}
+			}
+			if len(matching) == 0 {
+				return nil
+			}
+			return handlePage(matching)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package findings
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+)
+
+type Severity string
+
+const (
+	SeverityInformational Severity = "informational"
+	SeverityLow           Severity = "low"
+	SeverityMedium        Severity = "medium"
+	SeverityHigh          Severity = "high"
+	SeverityCritical      Severity = "critical"
+)
+
+type ResourceType string
+
+const (
+	ResourceTypeEKSCluster               ResourceType = "AwsEksCluster"
+	ResourceTypeIAMRole                  ResourceType = "AwsIamRole"
+	ResourceTypeKubernetesNamespace      ResourceType = "KubernetesNamespace"
+	ResourceTypeKubernetesPod            ResourceType = "KubernetesPod"
+	ResourceTypeKubernetesServiceAccount ResourceType = "KubernetesServiceAccount"
+	ResourceTypeKubernetesConfigMap      ResourceType = "KubernetesConfigMap"
+	ResourceTypeKubernetesSecret         ResourceType = "KubernetesSecret"
+	ResourceTypeKubernetesDeployment     ResourceType = "KubernetesDeployment"
+	ResourceTypeKubernetesStatefulSet    ResourceType = "KubernetesStatefulSet"
+	ResourceTypeKubernetesDaemonSet      ResourceType = "KubernetesDaemonSet"
+	ResourceTypeKubernetesReplicaSet     ResourceType = "KubernetesReplicaSet"
+	ResourceTypeKubernetesJob            ResourceType = "KubernetesJob"
+	ResourceTypeKubernetesCronJob        ResourceType = "KubernetesCronJob"
+)
+
+// Path segment of each Kubernetes resource type in resource URIs, as in the Kubernetes API
+var kubernetesResourcePaths = map[ResourceType]string{
+	ResourceTypeKubernetesPod:            "pods",
+	ResourceTypeKubernetesServiceAccount: "serviceaccounts",
+	ResourceTypeKubernetesConfigMap:      "configmaps",
+	ResourceTypeKubernetesSecret:         "secrets",
+	ResourceTypeKubernetesDeployment:     "deployments",
+	ResourceTypeKubernetesStatefulSet:    "statefulsets",
+	ResourceTypeKubernetesDaemonSet:      "daemonsets",
+	ResourceTypeKubernetesReplicaSet:     "replicasets",
+	ResourceTypeKubernetesJob:            "jobs",
+	ResourceTypeKubernetesCronJob:        "cronjobs",
+}
+
+// Resource identifies the cloud or Kubernetes resource a finding is about
+type Resource struct {
+	Type      ResourceType
+	ID        string // the ARN of an AWS resource, or the URI of a Kubernetes resource
+	Name      string
+	Namespace string // Kubernetes resources only
+}
+
+// KubernetesResource identifies a Kubernetes resource with a URI such as
+// k8s://my-cluster/namespaces/default/pods/my-pod, or k8s://my-cluster/namespaces/default for a namespace
+func KubernetesResource(clusterName string, resourceType ResourceType, namespace string, name string) Resource {
+	id := "k8s://" + url.PathEscape(clusterName) + "/namespaces/" + url.PathEscape(namespace)
+	if resourceType == ResourceTypeKubernetesNamespace {
+		return Resource{Type: resourceType, ID: id, Name: namespace, Namespace: namespace}
+	}
+	id += "/" + kubernetesResourcePaths[resourceType] + "/" + url.PathEscape(name)
+	return Resource{Type: resourceType, ID: id, Name: name, Namespace: namespace}
+}
+
+// IAMRoleResource identifies an IAM role by its ARN
+func IAMRoleResource(roleArn string) Resource {
+	name := roleArn
+	if parsedArn, err := arn.Parse(roleArn); err == nil {
+		name = parsedArn.Resource[strings.LastIndex(parsedArn.Resource, "/")+1:]
+	}
+	return Resource{Type: ResourceTypeIAMRole, ID: roleArn, Name: name}
+}
+
+// Finding is a single security issue identified by MKAT, along with how to fix it
+type Finding struct {
+	RuleID      string
+	Title       string
+	Description string
+	Severity    Severity
+	Remediation string
+	Resource    Resource
+
+	// Distinguishes findings of the same rule on the same resource, e.g. the environment variable holding a secret.
+	// Together with the rule and the resource, it identifies the finding across runs.
+	Discriminator string
+	// Secret the finding is about, if any, e.g. a hardcoded credential. It's not part of the fingerprint, so that the
+	// fingerprint can't be used to guess it, and it's never written to outputs. Baselines use a keyed hash of it to
+	// tell a new secret apart from the one that was accepted at the same place.
+	Secret string `json:"-"`
+}
+
+// Fingerprint identifies a finding across runs, so that consumers can deduplicate it. It doesn't depend on details
+// that can change between runs, such as the description.
+func (m *Finding) Fingerprint() string {
+	hash := sha256.Sum256([]byte(strings.Join([]string{m.RuleID, string(m.Resource.Type), m.Resource.ID, m.Discriminator}, "\x00")))
+	return hex.EncodeToString(hash[:])
+}
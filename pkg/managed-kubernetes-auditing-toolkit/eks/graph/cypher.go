@@ -0,0 +1,70 @@
+package graph
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Cypher returns Cypher statements that load the graph into Neo4j. Nodes and edges are created with MERGE, so that
+// statements can be run several times and graphs of several clusters can be loaded into the same database.
+func (m *Graph) Cypher() string {
+	sb := new(strings.Builder)
+	sb.WriteString("// Generated by mkat for EKS cluster " + m.ClusterName + "\n")
+	for _, nodeType := range m.nodeTypes() {
+		sb.WriteString(fmt.Sprintf("CREATE CONSTRAINT IF NOT EXISTS FOR (n:%s) REQUIRE n.id IS UNIQUE;\n", nodeType))
+	}
+	for _, node := range m.Nodes {
+		sb.WriteString(fmt.Sprintf("MERGE (n:%s {id: %s})", node.Type, cypherValue(node.ID)))
+		writeCypherProperties(sb, "n", node.Properties)
+		sb.WriteString(";\n")
+	}
+	for _, edge := range m.Edges {
+		sb.WriteString(fmt.Sprintf("MATCH (a:%s {id: %s}), (b:%s {id: %s}) ",
+			m.nodesByID[edge.Source].Type, cypherValue(edge.Source),
+			m.nodesByID[edge.Target].Type, cypherValue(edge.Target),
+		))
+		// The mechanism distinguishes edges between the same nodes, e.g. a role assumable through both IRSA and Pod Identity
+		if mechanism, found := edge.Properties["mechanism"]; found {
+			sb.WriteString(fmt.Sprintf("MERGE (a)-[r:%s {mechanism: %s}]->(b)", edge.Type, cypherValue(mechanism)))
+		} else {
+			sb.WriteString(fmt.Sprintf("MERGE (a)-[r:%s]->(b)", edge.Type))
+		}
+		writeCypherProperties(sb, "r", edge.Properties)
+		sb.WriteString(";\n")
+	}
+	return sb.String()
+}
+
+func writeCypherProperties(sb *strings.Builder, variable string, properties map[string]interface{}) {
+	var assignments []string
+	for _, key := range sortedKeys(properties) {
+		assignments = append(assignments, fmt.Sprintf("%s.%s = %s", variable, key, cypherValue(properties[key])))
+	}
+	if len(assignments) > 0 {
+		sb.WriteString(" SET " + strings.Join(assignments, ", "))
+	}
+}
+
+var cypherStringEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`, "\r", `\r`)
+
+func cypherValue(value interface{}) string {
+	switch typedValue := value.(type) {
+	case bool:
+		return fmt.Sprint(typedValue)
+	default:
+		return "'" + cypherStringEscaper.Replace(fmt.Sprint(typedValue)) + "'"
+	}
+}
+
+// nodeTypes returns the types of the nodes of the graph, in order
+func (m *Graph) nodeTypes() []NodeType {
+	var nodeTypes []NodeType
+	seen := map[NodeType]bool{}
+	for _, node := range m.Nodes {
+		if !seen[node.Type] {
+			seen[node.Type] = true
+			nodeTypes = append(nodeTypes, node.Type)
+		}
+	}
+	return nodeTypes
+}
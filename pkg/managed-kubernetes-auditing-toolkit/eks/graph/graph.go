@@ -0,0 +1,223 @@
+package graph
+
+import (
+	"sort"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/report"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/role_relationships"
+)
+
+type NodeType string
+
+const (
+	NodeTypeCluster        NodeType = "EKSCluster"
+	NodeTypeNamespace      NodeType = "Namespace"
+	NodeTypeServiceAccount NodeType = "ServiceAccount"
+	NodeTypePod            NodeType = "Pod"
+	NodeTypeIAMRole        NodeType = "IAMRole"
+)
+
+type EdgeType string
+
+const (
+	EdgeTypeHasNamespace       EdgeType = "HAS_NAMESPACE"        // cluster -> namespace
+	EdgeTypeContains           EdgeType = "CONTAINS"             // namespace -> service account or pod
+	EdgeTypeUsesServiceAccount EdgeType = "USES_SERVICE_ACCOUNT" // pod -> service account
+	EdgeTypeCanAssume          EdgeType = "CAN_ASSUME"           // service account or pod -> IAM role
+)
+
+// Node is a cluster, Kubernetes or IAM resource. Property values are strings or booleans.
+type Node struct {
+	ID         string                 `json:"id"`
+	Type       NodeType               `json:"type"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// Edge is a directed relationship between two nodes. Property values are strings or booleans.
+type Edge struct {
+	Source     string                 `json:"source"`
+	Target     string                 `json:"target"`
+	Type       EdgeType               `json:"type"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// Graph models which pods and service accounts of a cluster can assume which IAM roles. Nodes are identified by the
+// ARN of AWS resources and the URI of Kubernetes resources, so that graphs of several clusters can be merged: IAM
+// roles assumable from several clusters end up being the same node.
+type Graph struct {
+	ClusterName string  `json:"cluster"`
+	Nodes       []*Node `json:"nodes"`
+	Edges       []*Edge `json:"edges"`
+
+	nodesByID map[string]*Node
+	edgeKeys  map[string]bool
+}
+
+// Build builds the graph of an analyzed cluster. It only contains the service accounts that can assume IAM roles,
+// and the pods using them.
+func Build(cluster *role_relationships.EKSCluster) *Graph {
+	graph := &Graph{ClusterName: cluster.Name, Nodes: []*Node{}, Edges: []*Edge{}, nodesByID: map[string]*Node{}, edgeKeys: map[string]bool{}}
+	privilegedRoles := map[string]bool{}
+	for _, role := range cluster.IAMRoles {
+		privilegedRoles[role.Arn] = role.IsPrivileged
+	}
+
+	clusterNode := graph.addClusterNode(cluster)
+	for namespace, pods := range cluster.PodsByNamespace {
+		for _, pod := range pods {
+			if pod.ServiceAccount == nil || len(pod.ServiceAccount.AssumableRoles) == 0 {
+				continue
+			}
+			namespaceNode := graph.addNode(findings.KubernetesResource(cluster.Name, findings.ResourceTypeKubernetesNamespace, namespace, "").ID, NodeTypeNamespace, map[string]interface{}{
+				"name":    namespace,
+				"cluster": cluster.Name,
+			})
+			graph.addEdge(clusterNode, namespaceNode, EdgeTypeHasNamespace, map[string]interface{}{"cluster": cluster.Name})
+
+			serviceAccountNode := graph.addNode(findings.KubernetesResource(cluster.Name, findings.ResourceTypeKubernetesServiceAccount, namespace, pod.ServiceAccount.Name).ID, NodeTypeServiceAccount, map[string]interface{}{
+				"name":      pod.ServiceAccount.Name,
+				"namespace": namespace,
+				"cluster":   cluster.Name,
+			})
+			graph.addEdge(namespaceNode, serviceAccountNode, EdgeTypeContains, map[string]interface{}{"cluster": cluster.Name, "namespace": namespace})
+
+			podProperties := map[string]interface{}{
+				"name":      pod.Name,
+				"namespace": namespace,
+				"cluster":   cluster.Name,
+				"node":      pod.NodeName,
+			}
+			if pod.FargateProfile != nil {
+				podProperties["fargateProfile"] = pod.FargateProfile.Name
+			}
+			if pod.Workload != nil {
+				podProperties["workloadKind"] = pod.Workload.Kind
+				podProperties["workloadName"] = pod.Workload.Name
+			}
+			podNode := graph.addNode(findings.KubernetesResource(cluster.Name, findings.ResourceTypeKubernetesPod, namespace, pod.Name).ID, NodeTypePod, podProperties)
+			graph.addEdge(namespaceNode, podNode, EdgeTypeContains, map[string]interface{}{"cluster": cluster.Name, "namespace": namespace})
+			graph.addEdge(podNode, serviceAccountNode, EdgeTypeUsesServiceAccount, map[string]interface{}{"cluster": cluster.Name, "namespace": namespace})
+
+			for _, role := range pod.ServiceAccount.AssumableRoles {
+				roleResource := findings.IAMRoleResource(role.IAMRole.Arn)
+				roleNode := graph.addNode(roleResource.ID, NodeTypeIAMRole, map[string]interface{}{
+					"name":       roleResource.Name,
+					"arn":        role.IAMRole.Arn,
+					"privileged": privilegedRoles[role.IAMRole.Arn],
+				})
+				edgeProperties := map[string]interface{}{
+					"cluster":   cluster.Name,
+					"namespace": namespace,
+					"mechanism": string(mechanism(role)),
+				}
+				if role.PodIdentityAssociationID != "" {
+					edgeProperties["podIdentityAssociationId"] = role.PodIdentityAssociationID
+				}
+				graph.addEdge(serviceAccountNode, roleNode, EdgeTypeCanAssume, edgeProperties)
+
+				podEdgeProperties := copyProperties(edgeProperties)
+				ineffectiveReason := cluster.PodIdentityIneffectiveReason(pod, role)
+				podEdgeProperties["effective"] = ineffectiveReason == ""
+				if ineffectiveReason != "" {
+					podEdgeProperties["ineffectiveReason"] = ineffectiveReason
+				}
+				graph.addEdge(podNode, roleNode, EdgeTypeCanAssume, podEdgeProperties)
+			}
+		}
+	}
+
+	graph.sort()
+	return graph
+}
+
+func (m *Graph) addClusterNode(cluster *role_relationships.EKSCluster) *Node {
+	properties := map[string]interface{}{
+		"name":              cluster.Name,
+		"accountId":         cluster.AccountID,
+		"kubernetesVersion": cluster.KubernetesVersion,
+	}
+	id := "k8s://" + cluster.Name
+	if cluster.ClusterInfo != nil && cluster.ClusterInfo.Arn != nil {
+		id = *cluster.ClusterInfo.Arn
+		properties["arn"] = id
+	}
+	return m.addNode(id, NodeTypeCluster, properties)
+}
+
+// addNode adds a node unless a node with the same ID already exists, and returns the node with this ID
+func (m *Graph) addNode(id string, nodeType NodeType, properties map[string]interface{}) *Node {
+	if node, found := m.nodesByID[id]; found {
+		return node
+	}
+	node := &Node{ID: id, Type: nodeType, Properties: properties}
+	m.nodesByID[id] = node
+	m.Nodes = append(m.Nodes, node)
+	return node
+}
+
+// addEdge adds an edge unless an edge of the same type (and mechanism) already links the two nodes
+func (m *Graph) addEdge(source *Node, target *Node, edgeType EdgeType, properties map[string]interface{}) {
+	key := edgeKey(source.ID, target.ID, edgeType, properties)
+	if m.edgeKeys[key] {
+		return
+	}
+	m.edgeKeys[key] = true
+	m.Edges = append(m.Edges, &Edge{Source: source.ID, Target: target.ID, Type: edgeType, Properties: properties})
+}
+
+// Node returns the node with an ID, or nil
+func (m *Graph) Node(id string) *Node {
+	return m.nodesByID[id]
+}
+
+func (m *Graph) sort() {
+	sort.SliceStable(m.Nodes, func(i, j int) bool {
+		if m.Nodes[i].Type != m.Nodes[j].Type {
+			return nodeTypeOrder[m.Nodes[i].Type] < nodeTypeOrder[m.Nodes[j].Type]
+		}
+		return m.Nodes[i].ID < m.Nodes[j].ID
+	})
+	sort.SliceStable(m.Edges, func(i, j int) bool {
+		first, second := m.Edges[i], m.Edges[j]
+		return edgeKey(first.Source, first.Target, first.Type, first.Properties) < edgeKey(second.Source, second.Target, second.Type, second.Properties)
+	})
+}
+
+var nodeTypeOrder = map[NodeType]int{
+	NodeTypeCluster:        0,
+	NodeTypeNamespace:      1,
+	NodeTypeServiceAccount: 2,
+	NodeTypePod:            3,
+	NodeTypeIAMRole:        4,
+}
+
+func edgeKey(source string, target string, edgeType EdgeType, properties map[string]interface{}) string {
+	mechanism, _ := properties["mechanism"].(string)
+	return source + "\x00" + target + "\x00" + string(edgeType) + "\x00" + mechanism
+}
+
+func mechanism(role *role_relationships.AssumableIAMRole) report.Mechanism {
+	if role.Reason == role_relationships.AssumeIAMRoleReasonPodIdentity {
+		return report.MechanismPodIdentity
+	}
+	return report.MechanismIRSA
+}
+
+func copyProperties(properties map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(properties))
+	for key, value := range properties {
+		result[key] = value
+	}
+	return result
+}
+
+// sortedKeys returns the property names of a node or edge, in a stable order
+func sortedKeys(properties map[string]interface{}) []string {
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,130 @@
+package graph
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/role_relationships"
+	"github.com/stretchr/testify/assert"
+)
+
+func testAnalyzedCluster() *role_relationships.EKSCluster {
+	irsaRole := &role_relationships.AssumableIAMRole{
+		IAMRole: &role_relationships.IAMRole{Arn: "arn:aws:iam::123456789012:role/irsa-role"},
+		Reason:  role_relationships.AssumeIAMRoleReasonIRSA,
+	}
+	podIdentityRole := &role_relationships.AssumableIAMRole{
+		IAMRole:                  &role_relationships.IAMRole{Arn: "arn:aws:iam::123456789012:role/pod-identity-role"},
+		Reason:                   role_relationships.AssumeIAMRoleReasonPodIdentity,
+		PodIdentityAssociationID: "a-123",
+	}
+	serviceAccount := &role_relationships.K8sServiceAccount{Name: "sa", Namespace: "app", AssumableRoles: []*role_relationships.AssumableIAMRole{podIdentityRole, irsaRole}}
+	return &role_relationships.EKSCluster{
+		Name:              "my-cluster",
+		AccountID:         "123456789012",
+		KubernetesVersion: "1.29",
+		ClusterInfo:       &types.Cluster{Arn: aws.String("arn:aws:eks:us-east-1:123456789012:cluster/my-cluster")},
+		IAMRoles:          []*role_relationships.IAMRole{{Arn: "arn:aws:iam::123456789012:role/irsa-role", IsPrivileged: true}},
+		PodsByNamespace: map[string][]*role_relationships.K8sPod{
+			"app": {
+				{Name: "pod-b", Namespace: "app", NodeName: "node-1", ServiceAccount: serviceAccount},
+				{Name: "pod-a", Namespace: "app", NodeName: "node-2", ServiceAccount: serviceAccount},
+				{Name: "pod-without-sa", Namespace: "app"},
+			},
+			"empty": {
+				{Name: "pod-without-roles", Namespace: "empty", ServiceAccount: &role_relationships.K8sServiceAccount{Name: "default", Namespace: "empty"}},
+			},
+		},
+		PodIdentityAgent: &role_relationships.PodIdentityAgentStatus{DaemonSetFound: true, NodesWithHealthyAgent: map[string]bool{"node-1": true}},
+	}
+}
+
+func TestBuild(t *testing.T) {
+	graph := Build(testAnalyzedCluster())
+
+	var nodeIDs []string
+	for _, node := range graph.Nodes {
+		nodeIDs = append(nodeIDs, node.ID)
+	}
+	assert.Equal(t, []string{
+		"arn:aws:eks:us-east-1:123456789012:cluster/my-cluster",
+		"k8s://my-cluster/namespaces/app",
+		"k8s://my-cluster/namespaces/app/serviceaccounts/sa",
+		"k8s://my-cluster/namespaces/app/pods/pod-a",
+		"k8s://my-cluster/namespaces/app/pods/pod-b",
+		"arn:aws:iam::123456789012:role/irsa-role",
+		"arn:aws:iam::123456789012:role/pod-identity-role",
+	}, nodeIDs)
+	assert.Equal(t, true, graph.Node("arn:aws:iam::123456789012:role/irsa-role").Properties["privileged"])
+	assert.Equal(t, false, graph.Node("arn:aws:iam::123456789012:role/pod-identity-role").Properties["privileged"])
+	assert.Equal(t, "app", graph.Node("k8s://my-cluster/namespaces/app/pods/pod-a").Properties["namespace"])
+
+	// 1 cluster -> namespace, 3 namespace -> SA or pod, 2 pod -> SA, 2 SA -> role, 4 pod -> role
+	assert.Len(t, graph.Edges, 12)
+	var podEdges []*Edge
+	for _, edge := range graph.Edges {
+		if edge.Type == EdgeTypeCanAssume && edge.Source == "k8s://my-cluster/namespaces/app/pods/pod-a" {
+			podEdges = append(podEdges, edge)
+		}
+	}
+	assert.Len(t, podEdges, 2)
+	assert.Equal(t, "irsa", podEdges[0].Properties["mechanism"])
+	assert.Equal(t, true, podEdges[0].Properties["effective"])
+	assert.Equal(t, "pod-identity", podEdges[1].Properties["mechanism"])
+	assert.Equal(t, false, podEdges[1].Properties["effective"])
+	assert.Equal(t, "a-123", podEdges[1].Properties["podIdentityAssociationId"])
+	assert.Equal(t, "my-cluster", podEdges[1].Properties["cluster"])
+}
+
+func TestCypher(t *testing.T) {
+	cluster := testAnalyzedCluster()
+	cluster.PodsByNamespace["app"][0].Name = "pod-'quoted'"
+	cypher := Build(cluster).Cypher()
+
+	assert.Contains(t, cypher, "CREATE CONSTRAINT IF NOT EXISTS FOR (n:IAMRole) REQUIRE n.id IS UNIQUE;\n")
+	assert.Contains(t, cypher, "MERGE (n:IAMRole {id: 'arn:aws:iam::123456789012:role/irsa-role'}) SET n.arn = 'arn:aws:iam::123456789012:role/irsa-role', n.name = 'irsa-role', n.privileged = true;\n")
+	assert.Contains(t, cypher, `n.name = 'pod-\'quoted\''`)
+	assert.Contains(t, cypher, "MATCH (a:ServiceAccount {id: 'k8s://my-cluster/namespaces/app/serviceaccounts/sa'}), (b:IAMRole {id: 'arn:aws:iam::123456789012:role/pod-identity-role'}) "+
+		"MERGE (a)-[r:CAN_ASSUME {mechanism: 'pod-identity'}]->(b) SET r.cluster = 'my-cluster', r.mechanism = 'pod-identity', r.namespace = 'app', r.podIdentityAssociationId = 'a-123';\n")
+	assert.Contains(t, cypher, "MERGE (a)-[r:HAS_NAMESPACE]->(b) SET r.cluster = 'my-cluster';\n")
+	for _, line := range strings.Split(strings.TrimSpace(cypher), "\n")[1:] {
+		assert.True(t, strings.HasSuffix(line, ";"), line)
+	}
+}
+
+func TestGraphML(t *testing.T) {
+	graphML, err := Build(testAnalyzedCluster()).GraphML()
+	assert.Nil(t, err)
+
+	var document graphMLDocument
+	assert.Nil(t, xml.Unmarshal([]byte(graphML), &document))
+	assert.Equal(t, "directed", document.Graph.EdgeDefault)
+	assert.Len(t, document.Graph.Nodes, 7)
+	assert.Len(t, document.Graph.Edges, 12)
+	assert.Contains(t, graphML, `<key id="node_privileged" for="node" attr.name="privileged" attr.type="boolean"></key>`)
+	assert.Contains(t, graphML, `<key id="edge_mechanism" for="edge" attr.name="mechanism" attr.type="string"></key>`)
+}
+
+func TestJSON(t *testing.T) {
+	output, err := Build(testAnalyzedCluster()).JSON()
+	assert.Nil(t, err)
+
+	var parsed struct {
+		Cluster string  `json:"cluster"`
+		Nodes   []*Node `json:"nodes"`
+		Edges   []*Edge `json:"edges"`
+	}
+	assert.Nil(t, json.Unmarshal([]byte(output), &parsed))
+	assert.Equal(t, "my-cluster", parsed.Cluster)
+	assert.Len(t, parsed.Nodes, 7)
+	assert.Equal(t, NodeTypeCluster, parsed.Nodes[0].Type)
+	assert.Len(t, parsed.Edges, 12)
+
+	output, err = Build(&role_relationships.EKSCluster{Name: "empty"}).JSON()
+	assert.Nil(t, err)
+	assert.Contains(t, output, `"edges": []`)
+}
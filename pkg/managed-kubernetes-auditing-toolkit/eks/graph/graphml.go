@@ -0,0 +1,85 @@
+package graph
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+type graphMLDocument struct {
+	XMLName xml.Name      `xml:"graphml"`
+	Xmlns   string        `xml:"xmlns,attr"`
+	Keys    []*graphMLKey `xml:"key"`
+	Graph   graphMLGraph  `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string         `xml:"id,attr"`
+	EdgeDefault string         `xml:"edgedefault,attr"`
+	Nodes       []*graphMLNode `xml:"node"`
+	Edges       []*graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string         `xml:"id,attr"`
+	Data []*graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string         `xml:"source,attr"`
+	Target string         `xml:"target,attr"`
+	Data   []*graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// GraphML returns the graph in the GraphML format, which most graph tools can import. The type of nodes and edges
+// is stored in the "type" attribute.
+func (m *Graph) GraphML() (string, error) {
+	document := graphMLDocument{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Graph: graphMLGraph{ID: m.ClusterName, EdgeDefault: "directed"},
+	}
+	keys := map[string]*graphMLKey{}
+	dataKey := func(target string, name string, value interface{}) string {
+		id := target + "_" + name
+		if _, found := keys[id]; !found {
+			attrType := "string"
+			if _, isBool := value.(bool); isBool {
+				attrType = "boolean"
+			}
+			keys[id] = &graphMLKey{ID: id, For: target, AttrName: name, AttrType: attrType}
+			document.Keys = append(document.Keys, keys[id])
+		}
+		return id
+	}
+	toData := func(target string, entityType string, properties map[string]interface{}) []*graphMLData {
+		data := []*graphMLData{{Key: dataKey(target, "type", entityType), Value: entityType}}
+		for _, key := range sortedKeys(properties) {
+			data = append(data, &graphMLData{Key: dataKey(target, key, properties[key]), Value: fmt.Sprint(properties[key])})
+		}
+		return data
+	}
+
+	for _, node := range m.Nodes {
+		document.Graph.Nodes = append(document.Graph.Nodes, &graphMLNode{ID: node.ID, Data: toData("node", string(node.Type), node.Properties)})
+	}
+	for _, edge := range m.Edges {
+		document.Graph.Edges = append(document.Graph.Edges, &graphMLEdge{Source: edge.Source, Target: edge.Target, Data: toData("edge", string(edge.Type), edge.Properties)})
+	}
+
+	output, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("unable to serialize graph to GraphML: %v", err)
+	}
+	return xml.Header + string(output) + "\n", nil
+}
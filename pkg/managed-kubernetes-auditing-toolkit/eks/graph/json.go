@@ -0,0 +1,15 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// JSON returns the graph as a JSON document with a list of nodes and a list of edges
+func (m *Graph) JSON() (string, error) {
+	output, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("unable to serialize graph to JSON: %v", err)
+	}
+	return string(output) + "\n", nil
+}
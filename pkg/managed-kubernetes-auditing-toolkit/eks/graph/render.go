@@ -0,0 +1,273 @@
+package graph
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/report"
+)
+
+// RenderOptions controls how graphs are drawn in the DOT and Mermaid formats
+type RenderOptions struct {
+	CollapseWorkloads bool // draw a single node per workload (e.g. Deployment) instead of one per pod
+	FullRoleArns      bool // label roles with their ARN instead of their name
+}
+
+// Label of the edges of each mechanism a pod can use to retrieve credentials. Besides IRSA and Pod Identity, pods
+// retrieving the credentials of their node from the IMDS are labeled "IMDS".
+var mechanismLabels = map[string]string{
+	string(report.MechanismIRSA):        "IRSA",
+	string(report.MechanismPodIdentity): "Pod Identity",
+	"imds":                              "IMDS",
+}
+
+// view is the graph as drawn: namespace -> service account -> pod or workload -> IAM role
+type view struct {
+	Nodes []*viewNode
+	Edges []*viewEdge
+}
+
+type viewNode struct {
+	ID         string
+	Type       NodeType
+	Label      string
+	Privileged bool // IAM roles only
+	Fargate    bool // pods only
+	Workload   bool // collapsed pods
+}
+
+type viewEdge struct {
+	Source string
+	Target string
+	Label  string
+	Dashed bool // the relationship has no effect, e.g. no healthy Pod Identity Agent on the node of the pod
+}
+
+func (m *Graph) buildView(options RenderOptions) *view {
+	result := &view{}
+	nodesByID := map[string]*viewNode{}
+	edgesByKey := map[string]*viewEdge{}
+	// ID of the node drawn for each pod, i.e. the pod itself or its workload
+	drawnPodIDs := map[string]string{}
+	podsPerWorkload := map[string]int{}
+
+	addNode := func(node *viewNode) {
+		if _, found := nodesByID[node.ID]; !found {
+			nodesByID[node.ID] = node
+			result.Nodes = append(result.Nodes, node)
+		}
+	}
+	addEdge := func(source string, target string, label string, dashed bool) {
+		key := source + "\x00" + target + "\x00" + label
+		if edge, found := edgesByKey[key]; found {
+			// When collapsing pods, the relationship is effective if it is for any of the pods of the workload
+			edge.Dashed = edge.Dashed && dashed
+			return
+		}
+		edgesByKey[key] = &viewEdge{Source: source, Target: target, Label: label, Dashed: dashed}
+		result.Edges = append(result.Edges, edgesByKey[key])
+	}
+
+	for _, node := range m.Nodes {
+		name, _ := node.Properties["name"].(string)
+		switch node.Type {
+		case NodeTypeNamespace:
+			addNode(&viewNode{ID: node.ID, Type: node.Type, Label: "Namespace " + name})
+		case NodeTypeServiceAccount:
+			addNode(&viewNode{ID: node.ID, Type: node.Type, Label: "Service account " + name})
+		case NodeTypePod:
+			workloadKind, _ := node.Properties["workloadKind"].(string)
+			workloadName, _ := node.Properties["workloadName"].(string)
+			_, fargate := node.Properties["fargateProfile"]
+			if options.CollapseWorkloads && workloadKind != "" {
+				namespace, _ := node.Properties["namespace"].(string)
+				// e.g. k8s://my-cluster/namespaces/app/deployments/web
+				workloadID := fmt.Sprintf("k8s://%s/namespaces/%s/%ss/%s", m.ClusterName, namespace, strings.ToLower(workloadKind), workloadName)
+				drawnPodIDs[node.ID] = workloadID
+				podsPerWorkload[workloadID]++
+				addNode(&viewNode{ID: workloadID, Type: node.Type, Label: workloadKind + " " + workloadName, Fargate: fargate, Workload: true})
+				continue
+			}
+			drawnPodIDs[node.ID] = node.ID
+			label := "Pod " + name
+			if fargate {
+				label += " (Fargate)"
+			}
+			addNode(&viewNode{ID: node.ID, Type: node.Type, Label: label, Fargate: fargate})
+		case NodeTypeIAMRole:
+			label := "IAM role " + name
+			if options.FullRoleArns {
+				label = "IAM role " + node.ID
+			}
+			privileged, _ := node.Properties["privileged"].(bool)
+			addNode(&viewNode{ID: node.ID, Type: node.Type, Label: label, Privileged: privileged})
+		}
+	}
+	for _, node := range result.Nodes {
+		if node.Workload {
+			node.Label += fmt.Sprintf(" (%d pods)", podsPerWorkload[node.ID])
+		}
+	}
+
+	for _, edge := range m.Edges {
+		switch {
+		case edge.Type == EdgeTypeContains && m.nodesByID[edge.Target].Type == NodeTypeServiceAccount:
+			addEdge(edge.Source, edge.Target, "", false)
+		case edge.Type == EdgeTypeUsesServiceAccount:
+			addEdge(edge.Target, drawnPodIDs[edge.Source], "", false)
+		case edge.Type == EdgeTypeCanAssume && m.nodesByID[edge.Source].Type == NodeTypePod:
+			mechanism, _ := edge.Properties["mechanism"].(string)
+			label, found := mechanismLabels[mechanism]
+			if !found {
+				label = mechanism
+			}
+			effective, _ := edge.Properties["effective"].(bool)
+			addEdge(drawnPodIDs[edge.Source], edge.Target, label, !effective)
+		}
+	}
+	return result
+}
+
+// Fill color of each type of node
+const (
+	namespaceColor      = "white"
+	serviceAccountColor = "#E6E6FA"
+	podColor            = "lightgrey"
+	fargatePodColor     = "#FFE4B5"
+	roleColor           = "#BFEFFF"
+	privilegedRoleColor = "#FF9999"
+)
+
+func (m *viewNode) color() string {
+	switch m.Type {
+	case NodeTypeNamespace:
+		return namespaceColor
+	case NodeTypeServiceAccount:
+		return serviceAccountColor
+	case NodeTypePod:
+		if m.Fargate {
+			return fargatePodColor
+		}
+		return podColor
+	default:
+		if m.Privileged {
+			return privilegedRoleColor
+		}
+		return roleColor
+	}
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func dotQuote(value string) string {
+	return `"` + dotEscaper.Replace(value) + `"`
+}
+
+// DOT returns the graph in the Graphviz DOT format. Privileged roles are drawn in red, and relationships that have
+// no effect with dashed lines.
+func (m *Graph) DOT(options RenderOptions) string {
+	graphView := m.buildView(options)
+	sb := new(strings.Builder)
+	sb.WriteString("digraph G {\n")
+	sb.WriteString("\trankdir=LR;\n\tsplines=polyline;\n\tranksep=1.2;\n\tnodesep=0.8;\n\toutputorder=edgesfirst;\n\toverlap=false;\n\tnewrank=true;\n")
+	sb.WriteString("\tnode [fontname=Helvetica, fontsize=12, shape=box, style=filled];\n")
+	sb.WriteString("\tedge [fontname=Helvetica, fontsize=10, color=black];\n")
+	for _, node := range graphView.Nodes {
+		attributes := []string{"label=" + dotQuote(node.Label), "fillcolor=" + dotQuote(node.color())}
+		switch {
+		case node.Type == NodeTypeNamespace:
+			attributes = append(attributes, "shape=folder")
+		case node.Type == NodeTypeServiceAccount:
+			attributes = append(attributes, `style="filled,rounded"`)
+		case node.Workload:
+			attributes = append(attributes, "shape=box3d")
+		case node.Privileged:
+			attributes = append(attributes, "penwidth=2", "color=red")
+		}
+		sb.WriteString(fmt.Sprintf("\t%s [%s];\n", dotQuote(node.ID), strings.Join(attributes, ", ")))
+	}
+	for _, edge := range graphView.Edges {
+		var attributes []string
+		if edge.Label != "" {
+			attributes = append(attributes, "label="+dotQuote(edge.Label))
+		}
+		if edge.Dashed {
+			attributes = append(attributes, "style=dashed")
+		}
+		sb.WriteString(fmt.Sprintf("\t%s -> %s", dotQuote(edge.Source), dotQuote(edge.Target)))
+		if len(attributes) > 0 {
+			sb.WriteString(" [" + strings.Join(attributes, ", ") + "]")
+		}
+		sb.WriteString(";\n")
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// Mermaid returns the graph as a Mermaid flowchart, which GitHub, GitLab and most wikis render in Markdown
+func (m *Graph) Mermaid(options RenderOptions) string {
+	graphView := m.buildView(options)
+	sb := new(strings.Builder)
+	sb.WriteString("flowchart LR\n")
+
+	// Mermaid IDs can't contain most characters found in ARNs and URIs
+	mermaidIDs := map[string]string{}
+	for i, node := range graphView.Nodes {
+		mermaidIDs[node.ID] = fmt.Sprintf("n%d", i)
+		label := mermaidQuote(node.Label)
+		var shape string
+		switch node.Type {
+		case NodeTypeNamespace:
+			shape = "[/" + label + "/]"
+		case NodeTypeServiceAccount:
+			shape = "(" + label + ")"
+		default:
+			shape = "[" + label + "]"
+		}
+		sb.WriteString(fmt.Sprintf("    %s%s:::%s\n", mermaidIDs[node.ID], shape, node.mermaidClass()))
+	}
+	for _, edge := range graphView.Edges {
+		arrow := "-->"
+		if edge.Dashed {
+			arrow = "-.->"
+		}
+		if edge.Label != "" {
+			arrow += "|" + mermaidQuote(edge.Label) + "|"
+		}
+		sb.WriteString(fmt.Sprintf("    %s %s %s\n", mermaidIDs[edge.Source], arrow, mermaidIDs[edge.Target]))
+	}
+	for _, class := range []struct{ Name, Style string }{
+		{"namespace", "fill:" + namespaceColor + ",stroke:#999"},
+		{"serviceAccount", "fill:" + serviceAccountColor},
+		{"pod", "fill:" + podColor},
+		{"fargatePod", "fill:" + fargatePodColor},
+		{"role", "fill:" + roleColor},
+		{"privilegedRole", "fill:" + privilegedRoleColor + ",stroke:red,stroke-width:2px"},
+	} {
+		sb.WriteString(fmt.Sprintf("    classDef %s %s\n", class.Name, class.Style))
+	}
+	return sb.String()
+}
+
+func (m *viewNode) mermaidClass() string {
+	switch m.Type {
+	case NodeTypeNamespace:
+		return "namespace"
+	case NodeTypeServiceAccount:
+		return "serviceAccount"
+	case NodeTypePod:
+		if m.Fargate {
+			return "fargatePod"
+		}
+		return "pod"
+	default:
+		if m.Privileged {
+			return "privilegedRole"
+		}
+		return "role"
+	}
+}
+
+func mermaidQuote(value string) string {
+	return `"` + strings.ReplaceAll(value, `"`, "#quot;") + `"`
+}
@@ -0,0 +1,93 @@
+package graph
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/role_relationships"
+	"github.com/stretchr/testify/assert"
+)
+
+func testClusterWithWorkloads() *role_relationships.EKSCluster {
+	cluster := testAnalyzedCluster()
+	for _, pod := range cluster.PodsByNamespace["app"] {
+		pod.Workload = &role_relationships.K8sWorkload{Kind: "Deployment", Name: "web"}
+	}
+	return cluster
+}
+
+func TestDOT(t *testing.T) {
+	type Scenario struct {
+		Name             string
+		Options          RenderOptions
+		ExpectedLines    []string
+		NotExpectedLines []string
+	}
+	scenarios := []Scenario{
+		{
+			Name:    "one node per pod",
+			Options: RenderOptions{},
+			ExpectedLines: []string{
+				`"k8s://my-cluster/namespaces/app" [label="Namespace app", fillcolor="white", shape=folder];`,
+				`"k8s://my-cluster/namespaces/app/serviceaccounts/sa" [label="Service account sa", fillcolor="#E6E6FA", style="filled,rounded"];`,
+				`"arn:aws:iam::123456789012:role/irsa-role" [label="IAM role irsa-role", fillcolor="#FF9999", penwidth=2, color=red];`,
+				`"arn:aws:iam::123456789012:role/pod-identity-role" [label="IAM role pod-identity-role", fillcolor="#BFEFFF"];`,
+				`"k8s://my-cluster/namespaces/app" -> "k8s://my-cluster/namespaces/app/serviceaccounts/sa";`,
+				`"k8s://my-cluster/namespaces/app/serviceaccounts/sa" -> "k8s://my-cluster/namespaces/app/pods/pod-a";`,
+				`"k8s://my-cluster/namespaces/app/pods/pod-a" -> "arn:aws:iam::123456789012:role/irsa-role" [label="IRSA"];`,
+				`"k8s://my-cluster/namespaces/app/pods/pod-a" -> "arn:aws:iam::123456789012:role/pod-identity-role" [label="Pod Identity", style=dashed];`,
+				`"k8s://my-cluster/namespaces/app/pods/pod-b" -> "arn:aws:iam::123456789012:role/pod-identity-role" [label="Pod Identity"];`,
+			},
+			// Namespaces without relationships and the cluster aren't drawn
+			NotExpectedLines: []string{"Namespace empty", "arn:aws:eks"},
+		},
+		{
+			Name:    "full role ARNs",
+			Options: RenderOptions{FullRoleArns: true},
+			ExpectedLines: []string{
+				`"arn:aws:iam::123456789012:role/irsa-role" [label="IAM role arn:aws:iam::123456789012:role/irsa-role", fillcolor="#FF9999", penwidth=2, color=red];`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.Name, func(t *testing.T) {
+			dot := Build(testAnalyzedCluster()).DOT(scenario.Options)
+			assert.True(t, strings.HasPrefix(dot, "digraph G {\n"))
+			for _, line := range scenario.ExpectedLines {
+				assert.Contains(t, dot, "\t"+line+"\n")
+			}
+			for _, line := range scenario.NotExpectedLines {
+				assert.NotContains(t, dot, line)
+			}
+		})
+	}
+}
+
+func TestDOTCollapsesWorkloads(t *testing.T) {
+	dot := Build(testClusterWithWorkloads()).DOT(RenderOptions{CollapseWorkloads: true})
+
+	assert.Contains(t, dot, `"k8s://my-cluster/namespaces/app/deployments/web" [label="Deployment web (2 pods)", fillcolor="lightgrey", shape=box3d];`)
+	assert.NotContains(t, dot, "pod-a")
+	// pod-b can effectively assume the role through Pod Identity, so the workload can as well
+	assert.Contains(t, dot, `"k8s://my-cluster/namespaces/app/deployments/web" -> "arn:aws:iam::123456789012:role/pod-identity-role" [label="Pod Identity"];`)
+	assert.Equal(t, 1, strings.Count(dot, `-> "arn:aws:iam::123456789012:role/irsa-role"`))
+}
+
+func TestMermaid(t *testing.T) {
+	mermaid := Build(testClusterWithWorkloads()).Mermaid(RenderOptions{CollapseWorkloads: true})
+
+	assert.Equal(t, strings.Join([]string{
+		"flowchart LR",
+		`    n0[/"Namespace app"/]:::namespace`,
+		`    n1("Service account sa"):::serviceAccount`,
+		`    n2["Deployment web (2 pods)"]:::pod`,
+		`    n3["IAM role irsa-role"]:::privilegedRole`,
+		`    n4["IAM role pod-identity-role"]:::role`,
+		`    n0 --> n1`,
+		`    n2 -->|"IRSA"| n3`,
+		`    n2 -->|"Pod Identity"| n4`,
+		`    n1 --> n2`,
+	}, "\n")+"\n", mermaid[:strings.Index(mermaid, "    classDef")])
+	assert.Contains(t, mermaid, "    classDef privilegedRole fill:#FF9999,stroke:red,stroke-width:2px\n")
+}
@@ -0,0 +1,32 @@
+package imds
+
+import (
+	"fmt"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+)
+
+const (
+	RuleIDImdsV1Accessible = "eks-imdsv1-accessible-from-pods"
+	RuleIDImdsV2Accessible = "eks-imdsv2-accessible-from-pods"
+)
+
+// AccessFinding describes that pods of a namespace can access a version of the IMDS ("IMDSv1" or "IMDSv2"), and
+// therefore retrieve the credentials of the node role
+func AccessFinding(clusterName string, namespace string, imdsVersion string, description string) *findings.Finding {
+	finding := &findings.Finding{
+		RuleID:      RuleIDImdsV1Accessible,
+		Title:       "Pods can access IMDSv1",
+		Description: fmt.Sprintf("Pods in namespace %s can access IMDSv1: %s", namespace, description),
+		Severity:    findings.SeverityHigh,
+		Remediation: "Require IMDSv2 on your worker nodes and set the hop limit of the IMDS to 1, or block pod access to 169.254.169.254 with a NetworkPolicy",
+		Resource:    findings.KubernetesResource(clusterName, findings.ResourceTypeKubernetesNamespace, namespace, ""),
+	}
+	if imdsVersion == "IMDSv2" {
+		finding.RuleID = RuleIDImdsV2Accessible
+		finding.Title = "Pods can access IMDSv2"
+		finding.Description = fmt.Sprintf("Pods in namespace %s can access IMDSv2: %s", namespace, description)
+		finding.Remediation = "Set the hop limit of the IMDS of your worker nodes to 1, or block pod access to 169.254.169.254 with a NetworkPolicy"
+	}
+	return finding
+}
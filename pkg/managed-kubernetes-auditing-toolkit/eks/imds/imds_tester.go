@@ -22,13 +22,18 @@ import (
 type ImdsTester struct {
 	K8sClient *kubernetes.Clientset
 	Namespace string
+	PodLabels map[string]string // optional, e.g. so that the NetworkPolicies of an application apply to the tester pods
 }
 
 type ImdsTestResult struct {
 	IsImdsAccessible  bool
 	ResultDescription string
+	FargateProfile    string // set when the tester pod was scheduled on Fargate instead of an EC2 node
 }
 
+// Label set by EKS on pods scheduled on Fargate
+const FargateProfileLabel = "eks.amazonaws.com/fargate-profile"
+
 const ImdsTesterV1PodName = "mkat-imds-tester"
 const ImdsTesterV2PodName = "mkat-imds-v2-tester"
 
@@ -38,7 +43,7 @@ func (m *ImdsTester) TestImdsV1Accessible() (*ImdsTestResult, error) {
 		"-c",
 		"(curl --silent --show-error --connect-timeout 2 169.254.169.254/latest/meta-data/iam/security-credentials/ || true)",
 	}
-	podLogs, err := m.runCommandInPodAndGetLogs(ImdsTesterV1PodName, commandToRun)
+	podLogs, fargateProfile, err := m.runCommandInPodAndGetLogs(ImdsTesterV1PodName, commandToRun)
 	if err != nil {
 		return nil, fmt.Errorf("unable to retrieve logs from IMDS tester pod: %v", err)
 	}
@@ -48,6 +53,7 @@ func (m *ImdsTester) TestImdsV1Accessible() (*ImdsTestResult, error) {
 		return &ImdsTestResult{
 			IsImdsAccessible:  false,
 			ResultDescription: "unable to establish a network connection to the IMDS",
+			FargateProfile:    fargateProfile,
 		}, nil
 	}
 
@@ -56,6 +62,7 @@ func (m *ImdsTester) TestImdsV1Accessible() (*ImdsTestResult, error) {
 		return &ImdsTestResult{
 			IsImdsAccessible:  false,
 			ResultDescription: "able to establish a network connection to the IMDS, but no credentials were returned",
+			FargateProfile:    fargateProfile,
 		}, nil
 	}
 
@@ -63,6 +70,7 @@ func (m *ImdsTester) TestImdsV1Accessible() (*ImdsTestResult, error) {
 	return &ImdsTestResult{
 		IsImdsAccessible:  true,
 		ResultDescription: fmt.Sprintf("any pod can retrieve credentials for the AWS role %s", podLogs),
+		FargateProfile:    fargateProfile,
 	}, nil
 }
 
@@ -74,7 +82,7 @@ func (m *ImdsTester) TestImdsV2Accessible() (*ImdsTestResult, error) {
 		`TOKEN=$(curl --show-error --max-time 2 --silent -X PUT "http://169.254.169.254/latest/api/token" -H "X-aws-ec2-metadata-token-ttl-seconds: 21600")
 		(curl --silent --show-error --max-time 2 -H "X-aws-ec2-metadata-token: $TOKEN" 169.254.169.254/latest/meta-data/iam/security-credentials/ || true)`,
 	}
-	podLogs, err := m.runCommandInPodAndGetLogs(ImdsTesterV2PodName, commandToRun)
+	podLogs, fargateProfile, err := m.runCommandInPodAndGetLogs(ImdsTesterV2PodName, commandToRun)
 	if err != nil {
 		return nil, fmt.Errorf("unable to retrieve logs from IMDS tester pod: %v", err)
 	}
@@ -84,6 +92,7 @@ func (m *ImdsTester) TestImdsV2Accessible() (*ImdsTestResult, error) {
 		return &ImdsTestResult{
 			IsImdsAccessible:  false,
 			ResultDescription: "unable to establish a network connection to the IMDS",
+			FargateProfile:    fargateProfile,
 		}, nil
 	}
 
@@ -91,13 +100,16 @@ func (m *ImdsTester) TestImdsV2Accessible() (*ImdsTestResult, error) {
 	return &ImdsTestResult{
 		IsImdsAccessible:  true,
 		ResultDescription: fmt.Sprintf("any pod can retrieve credentials for the AWS role %s", podLogs),
+		FargateProfile:    fargateProfile,
 	}, nil
 }
 
-func (m *ImdsTester) runCommandInPodAndGetLogs(podName string, command []string) (string, error) {
+// runCommandInPodAndGetLogs runs a command in a new pod, and returns its output along with the Fargate profile the
+// pod was scheduled on, if any
+func (m *ImdsTester) runCommandInPodAndGetLogs(podName string, command []string) (string, string, error) {
 	podsClient := m.K8sClient.CoreV1().Pods(m.Namespace)
 	podDefinition := &v1.Pod{
-		ObjectMeta: metav1.ObjectMeta{Name: podName, Namespace: m.Namespace},
+		ObjectMeta: metav1.ObjectMeta{Name: podName, Namespace: m.Namespace, Labels: m.PodLabels},
 		Spec: v1.PodSpec{
 			Containers: []v1.Container{{
 				Name:    podName,
@@ -119,7 +131,7 @@ func (m *ImdsTester) runCommandInPodAndGetLogs(podName string, command []string)
 	}
 	_, err := podsClient.Create(context.Background(), podDefinition, metav1.CreateOptions{})
 	if err != nil {
-		return "", fmt.Errorf("unable to create IMDS tester pod: %v", err)
+		return "", "", fmt.Errorf("unable to create IMDS tester pod: %v", err)
 	}
 	m.handleCtrlC()
 	defer removePod(podsClient, podName)
@@ -129,16 +141,22 @@ func (m *ImdsTester) runCommandInPodAndGetLogs(podName string, command []string)
 	})
 
 	if err != nil {
-		return "", fmt.Errorf("unable to wait for IMDS tester pod to complete: %v", err)
+		return "", "", fmt.Errorf("unable to wait for IMDS tester pod to complete: %v", err)
 	}
 
 	// Retrieve command output
 	podLogs, err := getPodLogs(podsClient, podName)
 	if err != nil {
-		return "", fmt.Errorf("unable to retrieve logs from IMDS tester pod: %v", err)
+		return "", "", fmt.Errorf("unable to retrieve logs from IMDS tester pod: %v", err)
+	}
+
+	// Pods scheduled on Fargate can never reach the IMDS of a node, so the result wouldn't reflect EC2 nodes
+	fargateProfile := ""
+	if pod, err := podsClient.Get(context.Background(), podName, metav1.GetOptions{}); err == nil {
+		fargateProfile = pod.Labels[FargateProfileLabel]
 	}
 
-	return podLogs, nil
+	return podLogs, fargateProfile, nil
 }
 
 func (m *ImdsTester) handleCtrlC() {
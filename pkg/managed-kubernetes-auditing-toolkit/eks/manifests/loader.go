@@ -0,0 +1,178 @@
+package manifests
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Name of the release when rendering Helm charts, as used by helm template when none is given
+const helmReleaseName = "release-name"
+
+var (
+	manifestExtensions = []string{".yaml", ".yml", ".json"}
+	kustomizationFiles = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+)
+
+// Loader reads Kubernetes objects from local files. Directories containing a Chart.yaml are rendered with
+// helm template, and directories containing a kustomization.yaml are built with kustomize build or kubectl kustomize,
+// so the helm, kustomize or kubectl binary is needed to scan them.
+type Loader struct {
+	HelmValuesFiles []string // values files passed to every chart, like helm template --values
+	HelmSetValues   []string // values passed to every chart, like helm template --set, e.g. image.tag=latest
+
+	// Skipped lists the charts and overlays that Load couldn't render, and therefore didn't scan
+	Skipped []*SkippedDirectory
+
+	// Runs a command and returns its standard output, overridden in tests
+	runCommand func(name string, args ...string) ([]byte, error)
+	lookPath   func(file string) (string, error)
+}
+
+// SkippedDirectory is a Helm chart or a Kustomize overlay that couldn't be rendered
+type SkippedDirectory struct {
+	Path   string
+	Type   string // Helm chart or Kustomize overlay
+	Reason string
+}
+
+// Load returns the sources of objects found at a path, which is either a manifest file or a directory that's scanned
+// recursively. Hidden directories, such as .git, are skipped. Charts and overlays that can't be rendered, e.g. because
+// helm isn't installed, are skipped and added to Skipped, so that they don't prevent scanning the others.
+func (m *Loader) Load(path string) ([]*Source, error) {
+	if m.runCommand == nil {
+		m.runCommand = runCommand
+	}
+	if m.lookPath == nil {
+		m.lookPath = exec.LookPath
+	}
+	var sources []*Source
+	plainSources := map[string]*Source{} // manifests, by directory
+	err := filepath.WalkDir(path, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if filePath != path && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			source, sourceType := m.renderDirectory(filePath)
+			if sourceType != "" {
+				if source != nil {
+					sources = append(sources, source)
+				}
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isManifestFile(filePath) {
+			return nil
+		}
+		directory := filepath.Dir(filePath)
+		source, found := plainSources[directory]
+		if !found {
+			source = newSource(directory, SourceTypeManifests)
+			plainSources[directory] = source
+			sources = append(sources, source)
+		}
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return fmt.Errorf("unable to read %s: %v", filePath, err)
+		}
+		// Files that aren't valid YAML, such as templates of other tools, shouldn't prevent scanning the others
+		if err := source.addDocuments(filePath, string(content)); err != nil {
+			log.Printf("[WARN] Skipping the rest of %s: %v", filePath, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(sources, func(i, j int) bool {
+		return sources[i].Path < sources[j].Path
+	})
+	return sources, nil
+}
+
+// renderDirectory renders a directory if it's a Helm chart or a Kustomize overlay, and returns its type. The source is
+// nil if the directory is neither, or if it couldn't be rendered.
+func (m *Loader) renderDirectory(directory string) (*Source, string) {
+	if fileExists(filepath.Join(directory, "Chart.yaml")) {
+		log.Printf("Rendering Helm chart %s...", directory)
+		args := []string{"template", helmReleaseName, directory, "--namespace", DefaultNamespace}
+		for _, valuesFile := range m.HelmValuesFiles {
+			args = append(args, "--values", valuesFile)
+		}
+		for _, value := range m.HelmSetValues {
+			args = append(args, "--set", value)
+		}
+		return m.renderOrSkip(directory, SourceTypeHelmChart, "helm", args...), SourceTypeHelmChart
+	}
+	for _, kustomizationFile := range kustomizationFiles {
+		if !fileExists(filepath.Join(directory, kustomizationFile)) {
+			continue
+		}
+		log.Printf("Building Kustomize overlay %s...", directory)
+		if _, err := m.lookPath("kustomize"); err != nil {
+			return m.renderOrSkip(directory, SourceTypeKustomization, "kubectl", "kustomize", directory), SourceTypeKustomization
+		}
+		return m.renderOrSkip(directory, SourceTypeKustomization, "kustomize", "build", directory), SourceTypeKustomization
+	}
+	return nil, ""
+}
+
+// renderOrSkip renders a directory, or records it as skipped if it can't be rendered
+func (m *Loader) renderOrSkip(directory string, sourceType string, command string, args ...string) *Source {
+	source, err := m.render(directory, sourceType, command, args...)
+	if err != nil {
+		log.Printf("[WARN] Skipping %s %s: %v", sourceType, directory, err)
+		m.Skipped = append(m.Skipped, &SkippedDirectory{Path: directory, Type: sourceType, Reason: err.Error()})
+		return nil
+	}
+	return source
+}
+
+func (m *Loader) render(directory string, sourceType string, command string, args ...string) (*Source, error) {
+	if _, err := m.lookPath(command); err != nil {
+		return nil, fmt.Errorf("%s is not installed", command)
+	}
+	output, err := m.runCommand(command, args...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to run %s: %v", command, err)
+	}
+	source := newSource(directory, sourceType)
+	if err := source.addDocuments(directory, string(output)); err != nil {
+		return nil, fmt.Errorf("unable to parse the output of %s %s: %v", command, strings.Join(args, " "), err)
+	}
+	return source, nil
+}
+
+// runCommand runs a command, including its error output in errors
+func runCommand(name string, args ...string) ([]byte, error) {
+	output, err := exec.Command(name, args...).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return output, err
+}
+
+func isManifestFile(path string) bool {
+	for _, extension := range manifestExtensions {
+		if strings.EqualFold(filepath.Ext(path), extension) {
+			return true
+		}
+	}
+	return false
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,157 @@
+package manifests
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/datasource"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Namespace of objects that don't specify one, as when applying them with kubectl
+const DefaultNamespace = "default"
+
+const (
+	SourceTypeManifests     = "manifests"
+	SourceTypeHelmChart     = "Helm chart"
+	SourceTypeKustomization = "Kustomize overlay"
+)
+
+var (
+	documentSeparator = regexp.MustCompile(`(?m)^---.*$`)
+	// Helm precedes each rendered document with the template it comes from
+	helmSourceComment = regexp.MustCompile(`(?m)^# Source: (.+)$`)
+)
+
+// Source is a set of objects that are deployed together: the manifests of a directory, a Helm chart or a Kustomize
+// overlay. References between objects, such as a pod taking environment variables from a Secret, are resolved within
+// a source.
+type Source struct {
+	Path    string // directory of the chart or the overlay, or of the manifests
+	Type    string // manifests, Helm chart or Kustomize overlay
+	Objects datasource.InMemory
+
+	files map[string]string // file each object is defined in, by kind, namespace and name
+}
+
+// typeMeta holds the fields identifying the type of an object, to parse it into the matching Kubernetes type
+type typeMeta struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Items      []json.RawMessage `json:"items"` // objects of a List
+}
+
+func newSource(path string, sourceType string) *Source {
+	return &Source{Path: path, Type: sourceType, files: map[string]string{}}
+}
+
+// File returns the file an object is defined in, or the path of the source if unknown
+func (m *Source) File(kind string, namespace string, name string) string {
+	if file, found := m.files[objectKey(kind, namespace, name)]; found {
+		return file
+	}
+	return m.Path
+}
+
+// addDocuments adds the objects of a multi-document YAML or JSON stream. Documents that aren't Kubernetes objects,
+// or of kinds that can't hold secrets, are ignored.
+func (m *Source) addDocuments(file string, stream string) error {
+	for i, document := range documentSeparator.Split(stream, -1) {
+		documentFile := file
+		if match := helmSourceComment.FindStringSubmatch(document); match != nil && m.Type == SourceTypeHelmChart {
+			// Starts with the name of the chart, which may differ from its directory, e.g. my-chart/templates/secret.yaml
+			if _, templatePath, found := strings.Cut(match[1], "/"); found {
+				documentFile = filepath.Join(m.Path, filepath.FromSlash(templatePath))
+			}
+		}
+		rawObject, err := yaml.YAMLToJSON([]byte(document))
+		if err != nil {
+			return fmt.Errorf("unable to parse document %d of %s: %v", i+1, file, err)
+		}
+		if string(rawObject) == "null" {
+			continue
+		}
+		if err := m.addObject(documentFile, rawObject); err != nil {
+			return fmt.Errorf("unable to parse document %d of %s: %v", i+1, file, err)
+		}
+	}
+	return nil
+}
+
+func (m *Source) addObject(file string, rawObject []byte) error {
+	var meta typeMeta
+	if err := json.Unmarshal(rawObject, &meta); err != nil || meta.Kind == "" {
+		// Not a Kubernetes object, e.g. a configuration file
+		return nil
+	}
+	if strings.HasSuffix(meta.Kind, "List") && meta.Items != nil {
+		for _, item := range meta.Items {
+			if err := m.addObject(file, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	switch meta.APIVersion + " " + meta.Kind {
+	case "v1 ConfigMap":
+		return addObject(m, file, meta.Kind, rawObject, &m.Objects.ConfigMaps)
+	case "v1 Secret":
+		return addObject(m, file, meta.Kind, rawObject, &m.Objects.Secrets)
+	case "v1 Pod":
+		return addObject(m, file, meta.Kind, rawObject, &m.Objects.Pods)
+	case "apps/v1 Deployment":
+		return addObject(m, file, meta.Kind, rawObject, &m.Objects.Deployments)
+	case "apps/v1 StatefulSet":
+		return addObject(m, file, meta.Kind, rawObject, &m.Objects.StatefulSets)
+	case "apps/v1 DaemonSet":
+		return addObject(m, file, meta.Kind, rawObject, &m.Objects.DaemonSets)
+	case "apps/v1 ReplicaSet":
+		return addObject(m, file, meta.Kind, rawObject, &m.Objects.ReplicaSets)
+	case "batch/v1 Job":
+		return addObject(m, file, meta.Kind, rawObject, &m.Objects.Jobs)
+	case "batch/v1 CronJob":
+		return addObject(m, file, meta.Kind, rawObject, &m.Objects.CronJobs)
+	}
+	return nil
+}
+
+// kubernetesObject is a Kubernetes type that the scanners of the secrets package support
+type kubernetesObject interface {
+	corev1.ConfigMap | corev1.Secret | corev1.Pod | appsv1.Deployment | appsv1.StatefulSet | appsv1.DaemonSet |
+		appsv1.ReplicaSet | batchv1.Job | batchv1.CronJob
+}
+
+func addObject[T kubernetesObject](source *Source, file string, kind string, rawObject []byte, objects *[]T) error {
+	var object T
+	if err := json.Unmarshal(rawObject, &object); err != nil {
+		return err
+	}
+	// The API server merges stringData into data, which is what the scanners read
+	if secret, ok := any(&object).(*corev1.Secret); ok && len(secret.StringData) > 0 {
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		for key, value := range secret.StringData {
+			secret.Data[key] = []byte(value)
+		}
+		secret.StringData = nil
+	}
+	meta := any(&object).(metav1.Object)
+	if meta.GetNamespace() == "" {
+		meta.SetNamespace(DefaultNamespace)
+	}
+	source.files[objectKey(kind, meta.GetNamespace(), meta.GetName())] = file
+	*objects = append(*objects, object)
+	return nil
+}
+
+func objectKey(kind string, namespace string, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
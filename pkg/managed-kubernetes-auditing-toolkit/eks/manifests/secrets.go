@@ -0,0 +1,25 @@
+package manifests
+
+import (
+	"fmt"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/secrets"
+)
+
+// FindSecrets scans the objects of each source with the same scanners as for a cluster, and sets the file each secret
+// was found in
+func FindSecrets(sources []*Source) ([]*secrets.SecretInfo, error) {
+	var found []*secrets.SecretInfo
+	for _, source := range sources {
+		detector := secrets.SecretsDetector{DataSource: &source.Objects}
+		sourceSecrets, err := detector.FindSecrets()
+		if err != nil {
+			return nil, fmt.Errorf("unable to scan %s %s: %v", source.Type, source.Path, err)
+		}
+		for _, secret := range sourceSecrets {
+			secret.File = source.File(secret.Type, secret.Namespace, secret.ResourceName)
+		}
+		found = append(found, sourceSecrets...)
+	}
+	return found, nil
+}
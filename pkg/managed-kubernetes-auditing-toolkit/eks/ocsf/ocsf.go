@@ -0,0 +1,235 @@
+package ocsf
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+)
+
+const (
+	SchemaVersion = "1.1.0"
+
+	productName = "mkat"
+	vendorName  = "Datadog"
+
+	categoryUIDFindings           = 2
+	classUIDDetectionFinding      = 2004
+	activityIDCreate              = 1
+	statusIDNew                   = 1
+	analyticTypeIDRule            = 1
+	accountTypeIDAWSAccount       = 10
+	typeUIDDetectionFindingCreate = classUIDDetectionFinding*100 + activityIDCreate
+)
+
+// DetectionFinding is an OCSF Detection Finding event. Only the attributes MKAT uses are modeled.
+// c.f. https://schema.ocsf.io/1.1.0/classes/detection_finding
+type DetectionFinding struct {
+	ActivityID   int    `json:"activity_id"`
+	ActivityName string `json:"activity_name"`
+	CategoryUID  int    `json:"category_uid"`
+	CategoryName string `json:"category_name"`
+	ClassUID     int    `json:"class_uid"`
+	ClassName    string `json:"class_name"`
+	TypeUID      int    `json:"type_uid"`
+	TypeName     string `json:"type_name"`
+	SeverityID   int    `json:"severity_id"`
+	Severity     string `json:"severity"`
+	StatusID     int    `json:"status_id"`
+	Status       string `json:"status"`
+	Time         int64  `json:"time"` // milliseconds since the epoch
+	Message      string `json:"message"`
+
+	Metadata    Metadata     `json:"metadata"`
+	FindingInfo FindingInfo  `json:"finding_info"`
+	Cloud       Cloud        `json:"cloud"`
+	Resources   []*Resource  `json:"resources"`
+	Remediation *Remediation `json:"remediation,omitempty"`
+}
+
+type Metadata struct {
+	Version string  `json:"version"`
+	Product Product `json:"product"`
+}
+
+type Product struct {
+	Name       string `json:"name"`
+	VendorName string `json:"vendor_name"`
+	Version    string `json:"version,omitempty"`
+}
+
+type FindingInfo struct {
+	UID         string   `json:"uid"`
+	Title       string   `json:"title"`
+	Description string   `json:"desc"`
+	Types       []string `json:"types"`
+	CreatedTime int64    `json:"created_time"`
+	Analytic    Analytic `json:"analytic"`
+}
+
+// Analytic is the rule that produced a finding
+type Analytic struct {
+	UID    string `json:"uid"`
+	TypeID int    `json:"type_id"`
+	Type   string `json:"type"`
+}
+
+type Cloud struct {
+	Provider string  `json:"provider"`
+	Region   string  `json:"region"`
+	Account  Account `json:"account"`
+}
+
+type Account struct {
+	UID    string `json:"uid"`
+	TypeID int    `json:"type_id"`
+	Type   string `json:"type"`
+}
+
+type Resource struct {
+	UID            string            `json:"uid"`
+	Name           string            `json:"name,omitempty"`
+	Type           string            `json:"type"`
+	Namespace      string            `json:"namespace,omitempty"`
+	Region         string            `json:"region,omitempty"`
+	CloudPartition string            `json:"cloud_partition,omitempty"`
+	Data           map[string]string `json:"data,omitempty"`
+}
+
+type Remediation struct {
+	Description string `json:"desc"`
+}
+
+var severityIDs = map[findings.Severity]int{
+	findings.SeverityInformational: 1,
+	findings.SeverityLow:           2,
+	findings.SeverityMedium:        3,
+	findings.SeverityHigh:          4,
+	findings.SeverityCritical:      5,
+}
+
+var severityNames = map[findings.Severity]string{
+	findings.SeverityInformational: "Informational",
+	findings.SeverityLow:           "Low",
+	findings.SeverityMedium:        "Medium",
+	findings.SeverityHigh:          "High",
+	findings.SeverityCritical:      "Critical",
+}
+
+// OCSF resource types, following the CloudFormation naming for AWS resources
+var resourceTypes = map[findings.ResourceType]string{
+	findings.ResourceTypeEKSCluster:               "AWS::EKS::Cluster",
+	findings.ResourceTypeIAMRole:                  "AWS::IAM::Role",
+	findings.ResourceTypeKubernetesNamespace:      "Kubernetes::Namespace",
+	findings.ResourceTypeKubernetesPod:            "Kubernetes::Pod",
+	findings.ResourceTypeKubernetesServiceAccount: "Kubernetes::ServiceAccount",
+	findings.ResourceTypeKubernetesConfigMap:      "Kubernetes::ConfigMap",
+	findings.ResourceTypeKubernetesSecret:         "Kubernetes::Secret",
+	findings.ResourceTypeKubernetesDeployment:     "Kubernetes::Deployment",
+	findings.ResourceTypeKubernetesStatefulSet:    "Kubernetes::StatefulSet",
+	findings.ResourceTypeKubernetesDaemonSet:      "Kubernetes::DaemonSet",
+	findings.ResourceTypeKubernetesReplicaSet:     "Kubernetes::ReplicaSet",
+	findings.ResourceTypeKubernetesJob:            "Kubernetes::Job",
+	findings.ResourceTypeKubernetesCronJob:        "Kubernetes::CronJob",
+}
+
+// ConvertFindings converts findings about a cluster to OCSF Detection Findings. The account and region of findings
+// are the ones of the cluster, as identified by its ARN.
+func ConvertFindings(mkatFindings []*findings.Finding, clusterArn string, toolVersion string, now time.Time) ([]*DetectionFinding, error) {
+	parsedClusterArn, err := arn.Parse(clusterArn)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse cluster ARN %s: %v", clusterArn, err)
+	}
+	timestamp := now.UnixMilli()
+
+	result := make([]*DetectionFinding, 0, len(mkatFindings))
+	for _, mkatFinding := range mkatFindings {
+		finding := &DetectionFinding{
+			ActivityID:   activityIDCreate,
+			ActivityName: "Create",
+			CategoryUID:  categoryUIDFindings,
+			CategoryName: "Findings",
+			ClassUID:     classUIDDetectionFinding,
+			ClassName:    "Detection Finding",
+			TypeUID:      typeUIDDetectionFindingCreate,
+			TypeName:     "Detection Finding: Create",
+			SeverityID:   severityIDs[mkatFinding.Severity],
+			Severity:     severityNames[mkatFinding.Severity],
+			StatusID:     statusIDNew,
+			Status:       "New",
+			Time:         timestamp,
+			Message:      mkatFinding.Title,
+			Metadata: Metadata{
+				Version: SchemaVersion,
+				Product: Product{Name: productName, VendorName: vendorName, Version: toolVersion},
+			},
+			FindingInfo: FindingInfo{
+				UID:         mkatFinding.Fingerprint(),
+				Title:       mkatFinding.Title,
+				Description: mkatFinding.Description,
+				Types:       []string{mkatFinding.RuleID},
+				CreatedTime: timestamp,
+				Analytic:    Analytic{UID: mkatFinding.RuleID, TypeID: analyticTypeIDRule, Type: "Rule"},
+			},
+			Cloud: Cloud{
+				Provider: "AWS",
+				Region:   parsedClusterArn.Region,
+				Account:  Account{UID: parsedClusterArn.AccountID, TypeID: accountTypeIDAWSAccount, Type: "AWS Account"},
+			},
+			Resources: convertResources(mkatFinding.Resource, parsedClusterArn),
+		}
+		if mkatFinding.Remediation != "" {
+			finding.Remediation = &Remediation{Description: mkatFinding.Remediation}
+		}
+		result = append(result, finding)
+	}
+	return result, nil
+}
+
+// convertResources returns the resource of a finding, followed by the cluster when it's a Kubernetes resource
+func convertResources(resource findings.Resource, clusterArn arn.ARN) []*Resource {
+	clusterResource := &Resource{
+		UID:            clusterArn.String(),
+		Name:           clusterName(clusterArn),
+		Type:           resourceTypes[findings.ResourceTypeEKSCluster],
+		Region:         clusterArn.Region,
+		CloudPartition: clusterArn.Partition,
+	}
+	switch resource.Type {
+	case findings.ResourceTypeEKSCluster:
+		return []*Resource{clusterResource}
+	case findings.ResourceTypeIAMRole:
+		return []*Resource{{UID: resource.ID, Name: resource.Name, Type: resourceTypes[resource.Type], CloudPartition: clusterArn.Partition}}
+	default:
+		workload := &Resource{
+			UID:       resource.ID,
+			Name:      resource.Name,
+			Type:      resourceTypes[resource.Type],
+			Namespace: resource.Namespace,
+			Data:      map[string]string{"cluster": clusterName(clusterArn)},
+		}
+		return []*Resource{workload, clusterResource}
+	}
+}
+
+// clusterName returns the name of a cluster from its ARN, e.g. my-cluster for arn:aws:eks:us-east-1:012345678901:cluster/my-cluster
+func clusterName(clusterArn arn.ARN) string {
+	if len(clusterArn.Resource) > len("cluster/") {
+		return clusterArn.Resource[len("cluster/"):]
+	}
+	return clusterArn.Resource
+}
+
+// Marshal serializes Detection Findings to a JSON array
+func Marshal(detectionFindings []*DetectionFinding) ([]byte, error) {
+	if detectionFindings == nil {
+		detectionFindings = []*DetectionFinding{}
+	}
+	output, err := json.MarshalIndent(detectionFindings, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialize OCSF findings: %v", err)
+	}
+	return append(output, '\n'), nil
+}
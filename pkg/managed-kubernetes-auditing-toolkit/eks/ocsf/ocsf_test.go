@@ -0,0 +1,94 @@
+package ocsf
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+	"github.com/stretchr/testify/assert"
+)
+
+const testClusterArn = "arn:aws:eks:eu-west-1:123456789012:cluster/my-cluster"
+
+func TestConvertFindings(t *testing.T) {
+	type Scenario struct {
+		Name               string
+		Finding            *findings.Finding
+		ExpectedSeverityID int
+		ExpectedResources  []*Resource
+	}
+	clusterResource := &Resource{UID: testClusterArn, Name: "my-cluster", Type: "AWS::EKS::Cluster", Region: "eu-west-1", CloudPartition: "aws"}
+	scenarios := []Scenario{
+		{
+			Name: "cluster finding",
+			Finding: &findings.Finding{
+				RuleID:   "eks-private-endpoint-disabled",
+				Severity: findings.SeverityMedium,
+				Resource: findings.Resource{Type: findings.ResourceTypeEKSCluster, ID: testClusterArn, Name: "my-cluster"},
+			},
+			ExpectedSeverityID: 3,
+			ExpectedResources:  []*Resource{clusterResource},
+		},
+		{
+			Name: "IAM role finding",
+			Finding: &findings.Finding{
+				RuleID:   "eks-irsa-role-trusts-any-service-account",
+				Severity: findings.SeverityHigh,
+				Resource: findings.IAMRoleResource("arn:aws:iam::123456789012:role/my-role"),
+			},
+			ExpectedSeverityID: 4,
+			ExpectedResources: []*Resource{
+				{UID: "arn:aws:iam::123456789012:role/my-role", Name: "my-role", Type: "AWS::IAM::Role", CloudPartition: "aws"},
+			},
+		},
+		{
+			Name: "Kubernetes workload finding",
+			Finding: &findings.Finding{
+				RuleID:   "k8s-hardcoded-aws-credentials",
+				Severity: findings.SeverityCritical,
+				Resource: findings.KubernetesResource("my-cluster", findings.ResourceTypeKubernetesPod, "app", "web"),
+			},
+			ExpectedSeverityID: 5,
+			ExpectedResources: []*Resource{
+				{UID: "k8s://my-cluster/namespaces/app/pods/web", Name: "web", Type: "Kubernetes::Pod", Namespace: "app", Data: map[string]string{"cluster": "my-cluster"}},
+				clusterResource,
+			},
+		},
+	}
+
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	for _, scenario := range scenarios {
+		t.Run(scenario.Name, func(t *testing.T) {
+			result, err := ConvertFindings([]*findings.Finding{scenario.Finding}, testClusterArn, "1.2.3", now)
+			assert.Nil(t, err)
+			assert.Len(t, result, 1)
+			finding := result[0]
+			assert.Equal(t, 2004, finding.ClassUID)
+			assert.Equal(t, 200401, finding.TypeUID)
+			assert.Equal(t, int64(1704103200000), finding.Time)
+			assert.Equal(t, scenario.ExpectedSeverityID, finding.SeverityID)
+			assert.Equal(t, "123456789012", finding.Cloud.Account.UID)
+			assert.Equal(t, "eu-west-1", finding.Cloud.Region)
+			assert.Equal(t, scenario.Finding.Fingerprint(), finding.FindingInfo.UID)
+			assert.Equal(t, scenario.Finding.RuleID, finding.FindingInfo.Analytic.UID)
+			assert.Equal(t, "1.2.3", finding.Metadata.Product.Version)
+			assert.Equal(t, scenario.ExpectedResources, finding.Resources)
+		})
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	output, err := Marshal(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "[]\n", string(output))
+
+	detectionFindings, err := ConvertFindings([]*findings.Finding{{RuleID: "rule", Severity: findings.SeverityLow, Remediation: "fix it"}}, testClusterArn, "", time.Now())
+	assert.Nil(t, err)
+	output, err = Marshal(detectionFindings)
+	assert.Nil(t, err)
+	var parsed []map[string]interface{}
+	assert.Nil(t, json.Unmarshal(output, &parsed))
+	assert.Equal(t, "fix it", parsed[0]["remediation"].(map[string]interface{})["desc"])
+	assert.Equal(t, "Low", parsed[0]["severity"])
+}
@@ -0,0 +1,50 @@
+package report
+
+import (
+	"sort"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+)
+
+// ClusterFinding is an insecure setting of the EKS cluster
+type ClusterFinding struct {
+	RuleID      string            `json:"ruleId"`
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	Severity    findings.Severity `json:"severity"`
+	Remediation string            `json:"remediation"`
+	ResourceID  string            `json:"resourceId"` // ARN of the cluster
+}
+
+// Most severe findings come first
+var severityRanks = map[findings.Severity]int{
+	findings.SeverityCritical:      0,
+	findings.SeverityHigh:          1,
+	findings.SeverityMedium:        2,
+	findings.SeverityLow:           3,
+	findings.SeverityInformational: 4,
+}
+
+// NewClusterConfigReport builds the report of the audit-cluster-config command
+func NewClusterConfigReport(clusterFindings []*findings.Finding, info Cluster) *Report {
+	report := newReport(KindClusterConfig, info, nil)
+	report.ClusterFindings = []*ClusterFinding{}
+	for _, finding := range clusterFindings {
+		report.ClusterFindings = append(report.ClusterFindings, &ClusterFinding{
+			RuleID:      finding.RuleID,
+			Title:       finding.Title,
+			Description: finding.Description,
+			Severity:    finding.Severity,
+			Remediation: finding.Remediation,
+			ResourceID:  finding.Resource.ID,
+		})
+	}
+	sort.SliceStable(report.ClusterFindings, func(i, j int) bool {
+		first, second := report.ClusterFindings[i], report.ClusterFindings[j]
+		if severityRanks[first.Severity] != severityRanks[second.Severity] {
+			return severityRanks[first.Severity] < severityRanks[second.Severity]
+		}
+		return first.RuleID < second.RuleID
+	})
+	return report
+}
@@ -0,0 +1,33 @@
+package report
+
+import (
+	"sort"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/filters"
+)
+
+// IMDSAccessResult is the result of testing if pods of a namespace can access a version of the IMDS
+type IMDSAccessResult struct {
+	Namespace   string `json:"namespace"`
+	IMDSVersion string `json:"imdsVersion"` // IMDSv1 or IMDSv2
+
+	// Nil when the test could not determine it, in which case Error is set
+	Accessible     *bool  `json:"accessible"`
+	Description    string `json:"description,omitempty"`
+	FargateProfile string `json:"fargateProfile,omitempty"` // set when the tester pod ran on Fargate
+	Error          string `json:"error,omitempty"`
+}
+
+// NewIMDSAccessReport builds the report of the test-imds-access command
+func NewIMDSAccessReport(results []*IMDSAccessResult, info Cluster, filter *filters.Filters) *Report {
+	report := newReport(KindIMDSAccess, info, filter)
+	report.IMDSAccess = append([]*IMDSAccessResult{}, results...)
+	sort.SliceStable(report.IMDSAccess, func(i, j int) bool {
+		first, second := report.IMDSAccess[i], report.IMDSAccess[j]
+		if first.Namespace != second.Namespace {
+			return first.Namespace < second.Namespace
+		}
+		return first.IMDSVersion < second.IMDSVersion
+	})
+	return report
+}
@@ -0,0 +1,140 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/filters"
+	"sigs.k8s.io/yaml"
+)
+
+// SchemaVersion is the version of the report schema. It's incremented on every breaking change, such as removing or
+// renaming a field. New fields can be added without changing it.
+const SchemaVersion = 1
+
+// Kind identifies which command produced a report
+type Kind string
+
+const (
+	KindRoleRelationships Kind = "RoleRelationships"
+	KindSecrets           Kind = "Secrets"
+	KindIMDSAccess        Kind = "IMDSAccess"
+	KindClusterConfig     Kind = "ClusterConfig"
+)
+
+// Format is a serialization format of reports
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+)
+
+// Report is the machine-readable result of an MKAT command. Only the field matching its kind is set, and items are
+// sorted so that the same input always produces the same output.
+type Report struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	Kind          Kind      `json:"kind"`
+	GeneratedAt   time.Time `json:"generatedAt"`
+	Cluster       Cluster   `json:"cluster"`
+
+	// Filters the analysis was restricted to, nil if it covers the whole cluster
+	Filters *filters.Filters `json:"filters,omitempty"`
+
+	RoleRelationships []*RoleRelationship `json:"roleRelationships,omitempty"`
+	Secrets           []*Secret           `json:"secrets,omitempty"`
+	IMDSAccess        []*IMDSAccessResult `json:"imdsAccess,omitempty"`
+	ClusterFindings   []*ClusterFinding   `json:"clusterFindings,omitempty"`
+}
+
+// Cluster describes the analyzed cluster
+type Cluster struct {
+	Name              string `json:"name"`
+	AccountID         string `json:"accountId,omitempty"`
+	KubernetesVersion string `json:"kubernetesVersion,omitempty"`
+
+	// When the analyzed data was retrieved. For offline analyses, this is when the snapshot was collected.
+	CollectedAt time.Time `json:"collectedAt"`
+	// Path of the snapshot file, empty when the live cluster was analyzed
+	Snapshot string `json:"snapshot,omitempty"`
+}
+
+func newReport(kind Kind, cluster Cluster, filter *filters.Filters) *Report {
+	return &Report{
+		SchemaVersion: SchemaVersion,
+		Kind:          kind,
+		GeneratedAt:   time.Now().UTC(),
+		Cluster:       cluster,
+		Filters:       filter,
+	}
+}
+
+// MarshalJSON always includes the results of the report kind, even when there are none, so that consumers can
+// iterate over them without checking if they exist
+func (m Report) MarshalJSON() ([]byte, error) {
+	type rawReport Report // without this method
+	switch m.Kind {
+	case KindRoleRelationships:
+		return json.Marshal(struct {
+			rawReport
+			RoleRelationships []*RoleRelationship `json:"roleRelationships"`
+		}{rawReport(m), nonNil(m.RoleRelationships)})
+	case KindSecrets:
+		return json.Marshal(struct {
+			rawReport
+			Secrets []*Secret `json:"secrets"`
+		}{rawReport(m), nonNil(m.Secrets)})
+	case KindIMDSAccess:
+		return json.Marshal(struct {
+			rawReport
+			IMDSAccess []*IMDSAccessResult `json:"imdsAccess"`
+		}{rawReport(m), nonNil(m.IMDSAccess)})
+	case KindClusterConfig:
+		return json.Marshal(struct {
+			rawReport
+			ClusterFindings []*ClusterFinding `json:"clusterFindings"`
+		}{rawReport(m), nonNil(m.ClusterFindings)})
+	default:
+		return json.Marshal(rawReport(m))
+	}
+}
+
+func nonNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
+
+// Marshal serializes the report. YAML reports use the same field names as JSON reports.
+func (m *Report) Marshal(format Format) ([]byte, error) {
+	switch format {
+	case FormatJSON:
+		output, err := json.MarshalIndent(m, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("unable to serialize report to JSON: %v", err)
+		}
+		return append(output, '\n'), nil
+	case FormatYAML:
+		output, err := yaml.Marshal(m)
+		if err != nil {
+			return nil, fmt.Errorf("unable to serialize report to YAML: %v", err)
+		}
+		return output, nil
+	default:
+		return nil, fmt.Errorf("unsupported report format %s", format)
+	}
+}
+
+// Unmarshal parses a report serialized in JSON or YAML
+func Unmarshal(rawReport []byte) (*Report, error) {
+	var report Report
+	if err := yaml.Unmarshal(rawReport, &report); err != nil {
+		return nil, fmt.Errorf("unable to parse report: %v", err)
+	}
+	if report.SchemaVersion < 1 || report.SchemaVersion > SchemaVersion {
+		return nil, fmt.Errorf("unsupported report schema version %d (this version of MKAT supports version %d)", report.SchemaVersion, SchemaVersion)
+	}
+	return &report, nil
+}
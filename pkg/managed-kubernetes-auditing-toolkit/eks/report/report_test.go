@@ -0,0 +1,167 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/filters"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/role_relationships"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/secrets"
+	"github.com/stretchr/testify/assert"
+)
+
+var testCluster = Cluster{Name: "my-cluster", CollectedAt: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)}
+
+func testAnalyzedCluster() *role_relationships.EKSCluster {
+	irsaRole := &role_relationships.AssumableIAMRole{
+		IAMRole: &role_relationships.IAMRole{Arn: "arn:aws:iam::123456789012:role/irsa-role"},
+		Reason:  role_relationships.AssumeIAMRoleReasonIRSA,
+	}
+	podIdentityRole := &role_relationships.AssumableIAMRole{
+		IAMRole:                  &role_relationships.IAMRole{Arn: "arn:aws:iam::123456789012:role/path/pod-identity-role"},
+		Reason:                   role_relationships.AssumeIAMRoleReasonPodIdentity,
+		PodIdentityAssociationID: "a-123",
+	}
+	serviceAccount := &role_relationships.K8sServiceAccount{Name: "sa", Namespace: "app", AssumableRoles: []*role_relationships.AssumableIAMRole{podIdentityRole, irsaRole}}
+	return &role_relationships.EKSCluster{
+		Name:              "my-cluster",
+		AccountID:         "123456789012",
+		KubernetesVersion: "1.29",
+		IssuerURL:         "oidc.eks.us-east-1.amazonaws.com/id/ABC",
+		Filters:           &filters.Filters{IncludeNamespaces: []string{"app"}},
+		PodsByNamespace: map[string][]*role_relationships.K8sPod{
+			"app": {
+				{Name: "pod-b", Namespace: "app", NodeName: "node-1", ServiceAccount: serviceAccount, HasProjectedServiceAccountToken: true},
+				{Name: "pod-a", Namespace: "app", NodeName: "node-2", ServiceAccount: serviceAccount},
+				{Name: "pod-without-sa", Namespace: "app"},
+			},
+		},
+		PodIdentityAgent: &role_relationships.PodIdentityAgentStatus{DaemonSetFound: true, NodesWithHealthyAgent: map[string]bool{"node-1": true}},
+	}
+}
+
+func TestRoleRelationshipsReport(t *testing.T) {
+	report := NewRoleRelationshipsReport(testAnalyzedCluster(), testCluster)
+
+	assert.Equal(t, SchemaVersion, report.SchemaVersion)
+	assert.Equal(t, KindRoleRelationships, report.Kind)
+	assert.Equal(t, "123456789012", report.Cluster.AccountID)
+	assert.Equal(t, []string{"app"}, report.Filters.IncludeNamespaces)
+	assert.Len(t, report.RoleRelationships, 4)
+
+	// Relationships are sorted by namespace, pod, service account and role
+	first := report.RoleRelationships[0]
+	assert.Equal(t, "pod-a", first.Pod)
+	assert.Equal(t, "arn:aws:iam::123456789012:role/irsa-role", first.Role.Arn)
+	assert.Equal(t, "irsa-role", first.Role.Name)
+	assert.Equal(t, MechanismIRSA, first.Mechanism)
+	assert.Equal(t, Evaluation{Effective: true, OIDCIssuer: "oidc.eks.us-east-1.amazonaws.com/id/ABC", Node: "node-2"}, first.Evaluation)
+
+	second := report.RoleRelationships[1]
+	assert.Equal(t, "pod-identity-role", second.Role.Name)
+	assert.Equal(t, MechanismPodIdentity, second.Mechanism)
+	assert.False(t, second.Evaluation.Effective)
+	assert.Contains(t, second.Evaluation.IneffectiveReason, "node-2")
+	assert.Equal(t, "a-123", second.Evaluation.PodIdentityAssociationID)
+
+	assert.Equal(t, "pod-b", report.RoleRelationships[2].Pod)
+	assert.True(t, report.RoleRelationships[2].Evaluation.ProjectedServiceAccountToken)
+	assert.True(t, report.RoleRelationships[3].Evaluation.Effective)
+}
+
+func TestEmptyReportsHaveEmptyLists(t *testing.T) {
+	report := NewRoleRelationshipsReport(&role_relationships.EKSCluster{}, testCluster)
+	output, err := report.Marshal(FormatJSON)
+	assert.Nil(t, err)
+	assert.Contains(t, string(output), `"roleRelationships": []`)
+	assert.NotContains(t, string(output), `"secrets"`)
+
+	output, err = NewSecretsReport(nil, testCluster, nil, false).Marshal(FormatYAML)
+	assert.Nil(t, err)
+	assert.Contains(t, string(output), "secrets: []")
+}
+
+func TestSecretsReportIsSorted(t *testing.T) {
+	report := NewSecretsReport([]*secrets.SecretInfo{
+		{Namespace: "b", Type: "ConfigMap", Name: "config", Value: "AKIA2"},
+		{Namespace: "a", Type: "Pod", Name: "pod", Value: "AKIA1"},
+		{Namespace: "a", Type: "ConfigMap", Name: "config", Value: "AKIA3"},
+	}, testCluster, nil, true)
+
+	var resources []string
+	for _, secret := range report.Secrets {
+		resources = append(resources, secret.Namespace+"/"+secret.ResourceType+"/"+secret.Resource)
+	}
+	assert.Equal(t, []string{"a/ConfigMap/config", "a/Pod/pod", "b/ConfigMap/config"}, resources)
+	assert.Nil(t, report.Filters)
+}
+
+func TestSecretsReportMasksValues(t *testing.T) {
+	secretInfos := []*secrets.SecretInfo{{Namespace: "default", Type: "ConfigMap", Name: "config (key creds)", ResourceName: "config", Location: "key creds", Value: "HP8lBRs8X50F/0nCAXqEPQ95+jlG/0pLdlNui2XF"}}
+
+	masked := NewSecretsReport(secretInfos, testCluster, nil, false).Secrets[0]
+	assert.Equal(t, secrets.MaskValue("HP8lBRs8X50F/0nCAXqEPQ95+jlG/0pLdlNui2XF"), masked.Value)
+	assert.Equal(t, secretInfos[0].Finding(testCluster.Name).Fingerprint(), masked.Fingerprint)
+
+	shown := NewSecretsReport(secretInfos, testCluster, nil, true).Secrets[0]
+	assert.Equal(t, "HP8lBRs8X50F/0nCAXqEPQ95+jlG/0pLdlNui2XF", shown.Value)
+	assert.Equal(t, masked.Fingerprint, shown.Fingerprint, "fingerprints must not depend on masking")
+}
+
+func TestClusterConfigReportIsSortedBySeverity(t *testing.T) {
+	report := NewClusterConfigReport([]*findings.Finding{
+		{RuleID: "b", Severity: findings.SeverityLow},
+		{RuleID: "c", Severity: findings.SeverityHigh},
+		{RuleID: "a", Severity: findings.SeverityLow, Resource: findings.Resource{ID: "arn:aws:eks:us-east-1:123456789012:cluster/my-cluster"}},
+	}, testCluster)
+
+	var ruleIDs []string
+	for _, finding := range report.ClusterFindings {
+		ruleIDs = append(ruleIDs, finding.RuleID)
+	}
+	assert.Equal(t, []string{"c", "a", "b"}, ruleIDs)
+	assert.Equal(t, "arn:aws:eks:us-east-1:123456789012:cluster/my-cluster", report.ClusterFindings[1].ResourceID)
+}
+
+func TestIMDSAccessReportIsSorted(t *testing.T) {
+	accessible := true
+	report := NewIMDSAccessReport([]*IMDSAccessResult{
+		{Namespace: "b", IMDSVersion: "IMDSv1", Accessible: &accessible},
+		{Namespace: "a", IMDSVersion: "IMDSv2", Error: "timeout"},
+		{Namespace: "a", IMDSVersion: "IMDSv1", Accessible: &accessible},
+	}, testCluster, nil)
+
+	assert.Equal(t, "a", report.IMDSAccess[0].Namespace)
+	assert.Equal(t, "IMDSv1", report.IMDSAccess[0].IMDSVersion)
+	assert.Equal(t, "timeout", report.IMDSAccess[1].Error)
+	assert.Nil(t, report.IMDSAccess[1].Accessible)
+	assert.Equal(t, "b", report.IMDSAccess[2].Namespace)
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	report := NewRoleRelationshipsReport(testAnalyzedCluster(), testCluster)
+	for _, format := range []Format{FormatJSON, FormatYAML} {
+		t.Run(string(format), func(t *testing.T) {
+			output, err := report.Marshal(format)
+			assert.Nil(t, err)
+			parsedReport, err := Unmarshal(output)
+			assert.Nil(t, err)
+			assert.Equal(t, report.RoleRelationships, parsedReport.RoleRelationships)
+			assert.Equal(t, report.Cluster, parsedReport.Cluster)
+			assert.Equal(t, report.GeneratedAt, parsedReport.GeneratedAt)
+		})
+	}
+}
+
+func TestMarshalAndUnmarshalErrors(t *testing.T) {
+	report := NewSecretsReport(nil, testCluster, nil, false)
+	_, err := report.Marshal("xml")
+	assert.ErrorContains(t, err, "unsupported report format")
+
+	_, err = Unmarshal([]byte(`{"schemaVersion": 2, "kind": "Secrets"}`))
+	assert.ErrorContains(t, err, "unsupported report schema version 2")
+
+	_, err = Unmarshal([]byte(`not: [valid`))
+	assert.ErrorContains(t, err, "unable to parse report")
+}
@@ -0,0 +1,108 @@
+package report
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/role_relationships"
+)
+
+// Mechanism is how a pod can assume an IAM role
+type Mechanism string
+
+const (
+	MechanismIRSA        Mechanism = "irsa"
+	MechanismPodIdentity Mechanism = "pod-identity"
+)
+
+// RoleRelationship records that a pod can assume an IAM role
+type RoleRelationship struct {
+	Namespace      string     `json:"namespace"`
+	Pod            string     `json:"pod"`
+	ServiceAccount string     `json:"serviceAccount"`
+	Role           Role       `json:"role"`
+	Mechanism      Mechanism  `json:"mechanism"`
+	Evaluation     Evaluation `json:"evaluation"`
+}
+
+type Role struct {
+	Arn  string `json:"arn"`
+	Name string `json:"name"`
+}
+
+// Evaluation describes why the relationship exists, and whether it has an effect
+type Evaluation struct {
+	// False when the role is assumable in theory, but not in practice (e.g. no healthy Pod Identity Agent on the node)
+	Effective         bool   `json:"effective"`
+	IneffectiveReason string `json:"ineffectiveReason,omitempty"`
+
+	// IRSA only: the OIDC issuer of the cluster, trusted by the role
+	OIDCIssuer string `json:"oidcIssuer,omitempty"`
+	// IRSA only: whether the pod mounts a service account token for the sts.amazonaws.com audience
+	ProjectedServiceAccountToken bool `json:"projectedServiceAccountToken,omitempty"`
+	// Pod Identity only: the association granting access to the role
+	PodIdentityAssociationID string `json:"podIdentityAssociationId,omitempty"`
+
+	Node           string `json:"node,omitempty"`
+	FargateProfile string `json:"fargateProfile,omitempty"` // set when the pod runs on Fargate
+}
+
+// NewRoleRelationshipsReport builds the report of the find-role-relationships command from an analyzed cluster
+func NewRoleRelationshipsReport(cluster *role_relationships.EKSCluster, info Cluster) *Report {
+	report := newReport(KindRoleRelationships, info, cluster.Filters)
+	report.Cluster.AccountID = cluster.AccountID
+	report.Cluster.KubernetesVersion = cluster.KubernetesVersion
+	report.RoleRelationships = []*RoleRelationship{}
+	for namespace, pods := range cluster.PodsByNamespace {
+		for _, pod := range pods {
+			if pod.ServiceAccount == nil {
+				continue
+			}
+			for _, role := range pod.ServiceAccount.AssumableRoles {
+				report.RoleRelationships = append(report.RoleRelationships, newRoleRelationship(cluster, namespace, pod, role))
+			}
+		}
+	}
+	sort.SliceStable(report.RoleRelationships, func(i, j int) bool {
+		return report.RoleRelationships[i].sortKey() < report.RoleRelationships[j].sortKey()
+	})
+	return report
+}
+
+func newRoleRelationship(cluster *role_relationships.EKSCluster, namespace string, pod *role_relationships.K8sPod, role *role_relationships.AssumableIAMRole) *RoleRelationship {
+	relationship := &RoleRelationship{
+		Namespace:      namespace,
+		Pod:            pod.Name,
+		ServiceAccount: pod.ServiceAccount.Name,
+		Role:           Role{Arn: role.IAMRole.Arn, Name: roleName(role.IAMRole.Arn)},
+		Evaluation:     Evaluation{Node: pod.NodeName},
+	}
+	if pod.FargateProfile != nil {
+		relationship.Evaluation.FargateProfile = pod.FargateProfile.Name
+	}
+	switch role.Reason {
+	case role_relationships.AssumeIAMRoleReasonIRSA:
+		relationship.Mechanism = MechanismIRSA
+		relationship.Evaluation.OIDCIssuer = cluster.IssuerURL
+		relationship.Evaluation.ProjectedServiceAccountToken = pod.HasProjectedServiceAccountToken
+	case role_relationships.AssumeIAMRoleReasonPodIdentity:
+		relationship.Mechanism = MechanismPodIdentity
+		relationship.Evaluation.PodIdentityAssociationID = role.PodIdentityAssociationID
+	}
+	relationship.Evaluation.IneffectiveReason = cluster.PodIdentityIneffectiveReason(pod, role)
+	relationship.Evaluation.Effective = relationship.Evaluation.IneffectiveReason == ""
+	return relationship
+}
+
+func (m *RoleRelationship) sortKey() string {
+	return strings.Join([]string{m.Namespace, m.Pod, m.ServiceAccount, m.Role.Arn, string(m.Mechanism)}, "\x00")
+}
+
+func roleName(roleArn string) string {
+	parsedArn, err := arn.Parse(roleArn)
+	if err != nil {
+		return roleArn
+	}
+	return parsedArn.Resource[strings.LastIndex(parsedArn.Resource, "/")+1:]
+}
@@ -0,0 +1,68 @@
+package report
+
+import (
+	"sort"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/filters"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/secrets"
+)
+
+// Secret is a hardcoded secret found in the cluster
+type Secret struct {
+	Namespace    string `json:"namespace"`
+	ResourceType string `json:"resourceType"`        // ConfigMap, Secret, Pod, or a workload such as Deployment
+	Resource     string `json:"resource"`            // name of the resource, and where the secret is in it
+	Value        string `json:"value"`               // masked, unless the report was built with showValues
+	Fingerprint  string `json:"fingerprint"`         // identifies the secret across runs, e.g. in baselines
+	Detector     string `json:"detector,omitempty"`  // type of credentials, e.g. aws-credentials
+	TokenType    string `json:"tokenType,omitempty"` // part of the credentials, e.g. AWS access key ID
+	KeyType      string `json:"keyType,omitempty"`   // e.g. temporary access key
+	// DecodingChain lists how the secret was decoded from the value it was found in, e.g. helm release, values.yaml
+	DecodingChain []string `json:"decodingChain,omitempty"`
+	// Source is the ConfigMap or Secret that a pod or workload takes the secret from, e.g. Secret aws-credentials
+	Source string `json:"source,omitempty"`
+	// Validation is only set for AWS credentials, with --validate
+	Validation *secrets.AwsKeyValidation `json:"validation,omitempty"`
+	// File is the manifest the resource is defined in, only set by scan-manifests
+	File string `json:"file,omitempty"`
+}
+
+// NewSecretsReport builds the report of the find-secrets command. Values are masked, unless showValues is set.
+func NewSecretsReport(secretInfos []*secrets.SecretInfo, info Cluster, filter *filters.Filters, showValues bool) *Report {
+	report := newReport(KindSecrets, info, filter)
+	report.Secrets = []*Secret{}
+	for _, secretInfo := range secretInfos {
+		value := secretInfo.Value
+		if !showValues {
+			value = secrets.MaskValue(value)
+		}
+		report.Secrets = append(report.Secrets, &Secret{
+			Namespace:     secretInfo.Namespace,
+			ResourceType:  secretInfo.Type,
+			Resource:      secretInfo.Name,
+			Value:         value,
+			Fingerprint:   secretInfo.Fingerprint(info.Name),
+			Detector:      secretInfo.Detector,
+			TokenType:     secretInfo.TokenType,
+			KeyType:       secretInfo.KeyType,
+			DecodingChain: secretInfo.DecodingChain,
+			Source:        secretInfo.Source,
+			Validation:    secretInfo.Validation,
+			File:          secretInfo.File,
+		})
+	}
+	sort.SliceStable(report.Secrets, func(i, j int) bool {
+		first, second := report.Secrets[i], report.Secrets[j]
+		if first.Namespace != second.Namespace {
+			return first.Namespace < second.Namespace
+		}
+		if first.ResourceType != second.ResourceType {
+			return first.ResourceType < second.ResourceType
+		}
+		if first.Resource != second.Resource {
+			return first.Resource < second.Resource
+		}
+		return first.Value < second.Value
+	})
+	return report
+}
@@ -0,0 +1,92 @@
+package role_relationships
+
+import (
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/imds"
+)
+
+// FargateProfile determines which pods run on Fargate. Such pods cannot reach the IMDS of a node, and their
+// kubelet uses the pod execution role of the profile (e.g. to pull images)
+type FargateProfile struct {
+	Name                string
+	PodExecutionRoleArn string
+	Selectors           []*FargateProfileSelector
+}
+
+type FargateProfileSelector struct {
+	Namespace string
+	Labels    map[string]string
+}
+
+func buildFargateProfiles(profiles []*types.FargateProfile) []*FargateProfile {
+	var fargateProfiles []*FargateProfile
+	for _, profile := range profiles {
+		if profile == nil || profile.FargateProfileName == nil {
+			continue
+		}
+		fargateProfile := &FargateProfile{Name: *profile.FargateProfileName}
+		if profile.PodExecutionRoleArn != nil {
+			fargateProfile.PodExecutionRoleArn = *profile.PodExecutionRoleArn
+		}
+		for _, selector := range profile.Selectors {
+			if selector.Namespace == nil {
+				continue
+			}
+			fargateProfile.Selectors = append(fargateProfile.Selectors, &FargateProfileSelector{
+				Namespace: *selector.Namespace,
+				Labels:    selector.Labels,
+			})
+		}
+		fargateProfiles = append(fargateProfiles, fargateProfile)
+	}
+	return fargateProfiles
+}
+
+// MatchFargateProfile returns the Fargate profile a pod runs on, or nil if it doesn't run on Fargate.
+// Pods that are already scheduled carry a label with the name of their profile, and run on an EC2 node if they don't.
+// For pods that aren't scheduled yet, we evaluate the profile selectors in the same way as the Fargate scheduler.
+func MatchFargateProfile(namespace string, podLabels map[string]string, nodeName string, profiles []*FargateProfile) *FargateProfile {
+	if profileName, ok := podLabels[imds.FargateProfileLabel]; ok {
+		for _, profile := range profiles {
+			if profile.Name == profileName {
+				return profile
+			}
+		}
+		// The pod runs on Fargate, but we don't have the details of its profile
+		return &FargateProfile{Name: profileName}
+	}
+	// Profiles only apply when pods are scheduled, e.g. not to pods that were running before the profile was created
+	if nodeName != "" {
+		return nil
+	}
+
+	for _, profile := range profiles {
+		for _, selector := range profile.Selectors {
+			if selector.matches(namespace, podLabels) {
+				return profile
+			}
+		}
+	}
+	return nil
+}
+
+// Fargate selectors support "*" and "?" wildcards in namespaces and label values
+func (m *FargateProfileSelector) matches(namespace string, podLabels map[string]string) bool {
+	if !wildcardMatches(m.Namespace, namespace) {
+		return false
+	}
+	for key, expectedValue := range m.Labels {
+		value, ok := podLabels[key]
+		if !ok || !wildcardMatches(expectedValue, value) {
+			return false
+		}
+	}
+	return true
+}
+
+func wildcardMatches(pattern string, value string) bool {
+	matches, err := filepath.Match(pattern, value)
+	return matches && err == nil
+}
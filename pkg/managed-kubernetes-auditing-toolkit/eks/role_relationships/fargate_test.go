@@ -0,0 +1,57 @@
+package role_relationships
+
+import (
+	"testing"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/imds"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchesPodsToFargateProfiles(t *testing.T) {
+	profiles := []*FargateProfile{
+		{
+			Name:                "kube-system",
+			PodExecutionRoleArn: "arn:aws:iam::012345678901:role/fargate-kube-system",
+			Selectors:           []*FargateProfileSelector{{Namespace: "kube-system", Labels: map[string]string{"k8s-app": "kube-dns"}}},
+		},
+		{
+			Name:                "batch",
+			PodExecutionRoleArn: "arn:aws:iam::012345678901:role/fargate-batch",
+			Selectors: []*FargateProfileSelector{
+				{Namespace: "batch-*"},
+				{Namespace: "jobs", Labels: map[string]string{"compute": "fargate-?"}},
+			},
+		},
+	}
+
+	scenarios := []struct {
+		Name            string
+		Namespace       string
+		Labels          map[string]string
+		NodeName        string
+		ExpectedProfile string
+	}{
+		{"pod in a namespace without a profile", "default", map[string]string{}, "", ""},
+		{"pod matching namespace and labels", "kube-system", map[string]string{"k8s-app": "kube-dns", "foo": "bar"}, "", "kube-system"},
+		{"pod matching namespace but not labels", "kube-system", map[string]string{"k8s-app": "aws-node"}, "", ""},
+		{"pod matching a namespace wildcard", "batch-nightly", nil, "", "batch"},
+		{"pod matching a label value wildcard", "jobs", map[string]string{"compute": "fargate-1"}, "", "batch"},
+		{"pod not matching a label value wildcard", "jobs", map[string]string{"compute": "fargate-10"}, "", ""},
+		{"pod with the Fargate profile label", "default", map[string]string{imds.FargateProfileLabel: "batch"}, "fargate-ip-10-0-0-1.ec2.internal", "batch"},
+		{"pod with the label of an unknown Fargate profile", "default", map[string]string{imds.FargateProfileLabel: "other"}, "", "other"},
+		{"pod matching a profile but scheduled on an EC2 node", "batch-nightly", nil, "ip-10-0-0-2.ec2.internal", ""},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.Name, func(t *testing.T) {
+			profile := MatchFargateProfile(scenario.Namespace, scenario.Labels, scenario.NodeName, profiles)
+			if scenario.ExpectedProfile == "" {
+				assert.Nil(t, profile)
+				return
+			}
+			if assert.NotNil(t, profile) {
+				assert.Equal(t, scenario.ExpectedProfile, profile.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package role_relationships
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/snapshot"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	PodIdentityAgentAddonName     = snapshot.PodIdentityAgentAddonName
+	PodIdentityAgentDaemonSetName = snapshot.PodIdentityAgentDaemonSetName
+)
+
+// PodIdentityAgentStatus describes whether the EKS Pod Identity Agent is installed, and on which nodes it's healthy.
+// Pod Identity associations have no effect for pods running on a node without a healthy agent.
+type PodIdentityAgentStatus struct {
+	AddonInstalled        bool
+	AddonStatus           string // e.g. ACTIVE, DEGRADED
+	DaemonSetFound        bool
+	DesiredAgents         int
+	ReadyAgents           int
+	NodesWithHealthyAgent map[string]bool
+}
+
+// IneffectiveReason returns why a Pod Identity association does not apply to a pod, or an empty string if it does
+func (m *PodIdentityAgentStatus) IneffectiveReason(pod *K8sPod) string {
+	if pod.FargateProfile != nil {
+		return "pod runs on Fargate, which does not support Pod Identity"
+	}
+	if !m.DaemonSetFound {
+		if !m.AddonInstalled {
+			return "the " + PodIdentityAgentAddonName + " add-on is not installed"
+		}
+		return fmt.Sprintf("the %s add-on is installed (status %s) but its DaemonSet does not exist", PodIdentityAgentAddonName, m.AddonStatus)
+	}
+	if pod.NodeName == "" {
+		return "pod is not scheduled on a node"
+	}
+	if !m.NodesWithHealthyAgent[pod.NodeName] {
+		return fmt.Sprintf("no healthy %s pod runs on node %s (%d/%d agents ready)", PodIdentityAgentDaemonSetName, pod.NodeName, m.ReadyAgents, m.DesiredAgents)
+	}
+	return ""
+}
+
+// PodIdentityIneffectiveReason returns why a role can't actually be assumed by a pod through Pod Identity, or an
+// empty string if it can (or if the role is assumable through another mechanism)
+func (m *EKSCluster) PodIdentityIneffectiveReason(pod *K8sPod, role *AssumableIAMRole) string {
+	if role.Reason != AssumeIAMRoleReasonPodIdentity || m.PodIdentityAgent == nil {
+		return ""
+	}
+	return m.PodIdentityAgent.IneffectiveReason(pod)
+}
+
+// buildPodIdentityAgentStatus determines the status of the agent from the snapshot, or returns nil if unknown
+func buildPodIdentityAgentStatus(clusterSnapshot *snapshot.Snapshot) *PodIdentityAgentStatus {
+	podIdentityAgent := clusterSnapshot.PodIdentityAgent
+	if podIdentityAgent == nil {
+		return nil
+	}
+
+	status := PodIdentityAgentStatus{NodesWithHealthyAgent: map[string]bool{}}
+	if podIdentityAgent.Addon != nil {
+		status.AddonInstalled = true
+		status.AddonStatus = string(podIdentityAgent.Addon.Status)
+	}
+
+	daemonSet := podIdentityAgent.DaemonSet
+	if daemonSet == nil {
+		return &status
+	}
+	status.DaemonSetFound = true
+	status.DesiredAgents = int(daemonSet.Status.DesiredNumberScheduled)
+	status.ReadyAgents = int(daemonSet.Status.NumberReady)
+
+	selector, err := v1.LabelSelectorAsSelector(daemonSet.Spec.Selector)
+	if err != nil {
+		log.Printf("[WARNING] Invalid selector for DaemonSet %s, assuming the Pod Identity Agent is healthy: %v", PodIdentityAgentDaemonSetName, err)
+		return nil
+	}
+	agentPods := podIdentityAgent.Pods
+	if agentPods == nil {
+		agentPods = clusterSnapshot.Pods
+	}
+	for i := range agentPods {
+		pod := &agentPods[i]
+		if pod.Namespace == daemonSet.Namespace && selector.Matches(labels.Set(pod.Labels)) && isPodReady(pod) {
+			status.NodesWithHealthyAgent[pod.Spec.NodeName] = true
+		}
+	}
+	return &status
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodRunning {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
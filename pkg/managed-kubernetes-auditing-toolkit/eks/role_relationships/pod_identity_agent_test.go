@@ -0,0 +1,105 @@
+package role_relationships
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeterminesIfPodIdentityIsEffective(t *testing.T) {
+	healthyAgent := &PodIdentityAgentStatus{
+		AddonInstalled:        true,
+		AddonStatus:           "ACTIVE",
+		DaemonSetFound:        true,
+		DesiredAgents:         2,
+		ReadyAgents:           1,
+		NodesWithHealthyAgent: map[string]bool{"node-1": true},
+	}
+
+	scenarios := []struct {
+		Name              string
+		Agent             *PodIdentityAgentStatus
+		Pod               *K8sPod
+		Mechanism         AssumeIAMRoleReason
+		ExpectIneffective bool
+		ExpectedReason    string
+	}{
+		{
+			Name:      "pod on a node with a healthy agent",
+			Agent:     healthyAgent,
+			Pod:       &K8sPod{Name: "pod", NodeName: "node-1"},
+			Mechanism: AssumeIAMRoleReasonPodIdentity,
+		},
+		{
+			Name:              "pod on a node without a healthy agent",
+			Agent:             healthyAgent,
+			Pod:               &K8sPod{Name: "pod", NodeName: "node-2"},
+			Mechanism:         AssumeIAMRoleReasonPodIdentity,
+			ExpectIneffective: true,
+			ExpectedReason:    "no healthy eks-pod-identity-agent pod runs on node node-2 (1/2 agents ready)",
+		},
+		{
+			Name:              "pod not scheduled yet",
+			Agent:             healthyAgent,
+			Pod:               &K8sPod{Name: "pod"},
+			Mechanism:         AssumeIAMRoleReasonPodIdentity,
+			ExpectIneffective: true,
+			ExpectedReason:    "pod is not scheduled on a node",
+		},
+		{
+			Name:              "pod on Fargate",
+			Agent:             healthyAgent,
+			Pod:               &K8sPod{Name: "pod", FargateProfile: &FargateProfile{Name: "default"}},
+			Mechanism:         AssumeIAMRoleReasonPodIdentity,
+			ExpectIneffective: true,
+			ExpectedReason:    "pod runs on Fargate, which does not support Pod Identity",
+		},
+		{
+			Name:              "add-on not installed",
+			Agent:             &PodIdentityAgentStatus{},
+			Pod:               &K8sPod{Name: "pod", NodeName: "node-1"},
+			Mechanism:         AssumeIAMRoleReasonPodIdentity,
+			ExpectIneffective: true,
+			ExpectedReason:    "the eks-pod-identity-agent add-on is not installed",
+		},
+		{
+			Name:              "add-on installed but DaemonSet missing",
+			Agent:             &PodIdentityAgentStatus{AddonInstalled: true, AddonStatus: "DEGRADED"},
+			Pod:               &K8sPod{Name: "pod", NodeName: "node-1"},
+			Mechanism:         AssumeIAMRoleReasonPodIdentity,
+			ExpectIneffective: true,
+			ExpectedReason:    "the eks-pod-identity-agent add-on is installed (status DEGRADED) but its DaemonSet does not exist",
+		},
+		{
+			Name:      "self-managed agent without the add-on",
+			Agent:     &PodIdentityAgentStatus{DaemonSetFound: true, NodesWithHealthyAgent: map[string]bool{"node-1": true}},
+			Pod:       &K8sPod{Name: "pod", NodeName: "node-1"},
+			Mechanism: AssumeIAMRoleReasonPodIdentity,
+		},
+		{
+			Name:      "unknown agent status",
+			Agent:     nil,
+			Pod:       &K8sPod{Name: "pod", NodeName: "node-2"},
+			Mechanism: AssumeIAMRoleReasonPodIdentity,
+		},
+		{
+			Name:      "IRSA does not depend on the agent",
+			Agent:     &PodIdentityAgentStatus{},
+			Pod:       &K8sPod{Name: "pod", NodeName: "node-1"},
+			Mechanism: AssumeIAMRoleReasonIRSA,
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.Name, func(t *testing.T) {
+			cluster := EKSCluster{PodIdentityAgent: scenario.Agent}
+			role := &AssumableIAMRole{IAMRole: &IAMRole{Arn: "arn:aws:iam::012345678901:role/foo"}, Reason: scenario.Mechanism}
+			reason := cluster.PodIdentityIneffectiveReason(scenario.Pod, role)
+			if !scenario.ExpectIneffective {
+				assert.Empty(t, reason)
+				return
+			}
+			assert.Equal(t, scenario.ExpectedReason, reason)
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package role_relationships
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/internal/aws/iam_evaluation"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+)
+
+const (
+	RuleIDIRSARoleTrustsAnyServiceAccount            = "eks-irsa-role-trusts-any-service-account"
+	RuleIDIRSARoleTrustsAnyServiceAccountInNamespace = "eks-irsa-role-trusts-any-service-account-in-namespace"
+	RuleIDIRSARoleTrustPolicyWithoutAudience         = "eks-irsa-role-trust-policy-without-audience"
+	RuleIDDefaultServiceAccountCanAssumeRole         = "eks-default-service-account-can-assume-role"
+	RuleIDServiceAccountCanAssumeRole                = "eks-service-account-can-assume-role"
+)
+
+// Names that no real service account or namespace uses. If a trust policy lets them assume a role, it relies on
+// wildcards or lacks conditions.
+const (
+	nonexistentNamespace      = "mkat-nonexistent-namespace"
+	nonexistentServiceAccount = "mkat-nonexistent-service-account"
+	unexpectedAudience        = "mkat-unexpected-audience"
+)
+
+// FindRisks returns findings about IRSA trust policies that are too permissive, and about service accounts that can
+// assume IAM roles. It must be called after the role relationships are analyzed.
+func (m *EKSCluster) FindRisks() []*findings.Finding {
+	riskFindings := m.findTrustPolicyRisks()
+	riskFindings = append(riskFindings, m.findRelationshipRisks()...)
+	return riskFindings
+}
+
+func (m *EKSCluster) findTrustPolicyRisks() []*findings.Finding {
+	if m.IssuerURL == "" {
+		return nil // no IRSA without an OIDC provider
+	}
+	var riskFindings []*findings.Finding
+	for _, role := range m.IAMRoles {
+		trustPolicy, err := iam_evaluation.ParseRoleTrustPolicy(role.TrustPolicy)
+		if err != nil {
+			continue // already reported when analyzing relationships
+		}
+		riskFindings = append(riskFindings, m.checkTrustPolicy(role, trustPolicy)...)
+	}
+	return riskFindings
+}
+
+func (m *EKSCluster) checkTrustPolicy(role *IAMRole, trustPolicy *iam_evaluation.Policy) []*findings.Finding {
+	var riskFindings []*findings.Finding
+	resource := findings.IAMRoleResource(role.Arn)
+
+	// A service account the role trusts, used to check the audience condition
+	trustedNamespace, trustedServiceAccount := "", ""
+	if m.allowsWebIdentity(trustPolicy, nonexistentNamespace, nonexistentServiceAccount, stsAudience) {
+		trustedNamespace, trustedServiceAccount = nonexistentNamespace, nonexistentServiceAccount
+		riskFindings = append(riskFindings, &findings.Finding{
+			RuleID:      RuleIDIRSARoleTrustsAnyServiceAccount,
+			Title:       "IAM role can be assumed by any service account of the cluster",
+			Description: fmt.Sprintf("The trust policy of %s lets any service account in any namespace of cluster %s assume the role through IRSA", resource.Name, m.Name),
+			Severity:    findings.SeverityHigh,
+			Remediation: "Add a StringEquals condition on the sub claim of the OIDC provider, restricting the role to specific service accounts",
+			Resource:    resource,
+		})
+	} else {
+		for _, namespace := range m.namespaceNames() {
+			if !m.allowsWebIdentity(trustPolicy, namespace, nonexistentServiceAccount, stsAudience) {
+				continue
+			}
+			if trustedNamespace == "" {
+				trustedNamespace, trustedServiceAccount = namespace, nonexistentServiceAccount
+			}
+			riskFindings = append(riskFindings, &findings.Finding{
+				RuleID:        RuleIDIRSARoleTrustsAnyServiceAccountInNamespace,
+				Title:         "IAM role can be assumed by any service account of a namespace",
+				Description:   fmt.Sprintf("The trust policy of %s lets any service account in namespace %s assume the role through IRSA", resource.Name, namespace),
+				Severity:      findings.SeverityMedium,
+				Remediation:   "Replace the wildcard in the sub claim condition of the trust policy with the names of the service accounts that need the role",
+				Resource:      resource,
+				Discriminator: namespace,
+			})
+		}
+	}
+	if trustedNamespace == "" {
+		trustedNamespace, trustedServiceAccount = m.firstServiceAccountAssuming(role.Arn)
+	}
+
+	if trustedNamespace != "" && m.allowsWebIdentity(trustPolicy, trustedNamespace, trustedServiceAccount, unexpectedAudience) {
+		riskFindings = append(riskFindings, &findings.Finding{
+			RuleID:      RuleIDIRSARoleTrustPolicyWithoutAudience,
+			Title:       "IRSA trust policy does not check the token audience",
+			Description: fmt.Sprintf("The trust policy of %s accepts service account tokens issued for any audience, not only %s", resource.Name, stsAudience),
+			Severity:    findings.SeverityLow,
+			Remediation: fmt.Sprintf("Add a StringEquals condition requiring the aud claim of the OIDC provider to be %s", stsAudience),
+			Resource:    resource,
+		})
+	}
+	return riskFindings
+}
+
+// findRelationshipRisks reports each service account that can effectively assume a role from at least one pod
+func (m *EKSCluster) findRelationshipRisks() []*findings.Finding {
+	type relationship struct {
+		serviceAccount *K8sServiceAccount
+		role           *AssumableIAMRole
+	}
+	podsByRelationship := map[relationship][]string{}
+	var relationships []relationship
+	for _, pods := range m.PodsByNamespace {
+		for _, pod := range pods {
+			if pod.ServiceAccount == nil {
+				continue
+			}
+			for _, role := range pod.ServiceAccount.AssumableRoles {
+				if m.PodIdentityIneffectiveReason(pod, role) != "" {
+					continue
+				}
+				key := relationship{serviceAccount: pod.ServiceAccount, role: role}
+				if _, found := podsByRelationship[key]; !found {
+					relationships = append(relationships, key)
+				}
+				podsByRelationship[key] = append(podsByRelationship[key], pod.Name)
+			}
+		}
+	}
+
+	var riskFindings []*findings.Finding
+	for _, key := range relationships {
+		pods := podsByRelationship[key]
+		sort.Strings(pods)
+		serviceAccount := key.serviceAccount
+		resource := findings.KubernetesResource(m.Name, findings.ResourceTypeKubernetesServiceAccount, serviceAccount.Namespace, serviceAccount.Name)
+		finding := &findings.Finding{
+			RuleID:        RuleIDServiceAccountCanAssumeRole,
+			Title:         "Service account can assume an IAM role",
+			Description:   fmt.Sprintf("Pods using service account %s/%s can assume %s through %s (pods: %s)", serviceAccount.Namespace, serviceAccount.Name, key.role.IAMRole.Arn, key.role.Reason, strings.Join(pods, ", ")),
+			Severity:      findings.SeverityInformational,
+			Remediation:   "Make sure the role only has the permissions these pods need, and that only trusted workloads can use this service account",
+			Resource:      resource,
+			Discriminator: key.role.IAMRole.Arn + "|" + string(key.role.Reason),
+		}
+		if serviceAccount.Name == "default" {
+			// Every pod of the namespace that doesn't specify a service account gets the role
+			finding.RuleID = RuleIDDefaultServiceAccountCanAssumeRole
+			finding.Title = "Default service account can assume an IAM role"
+			finding.Severity = findings.SeverityMedium
+			finding.Remediation = "Create a dedicated service account for the pods that need the role, and grant it the role instead of the default service account of the namespace"
+		}
+		riskFindings = append(riskFindings, finding)
+	}
+	sort.SliceStable(riskFindings, func(i, j int) bool {
+		first, second := riskFindings[i], riskFindings[j]
+		if first.Resource.ID != second.Resource.ID {
+			return first.Resource.ID < second.Resource.ID
+		}
+		return first.Discriminator < second.Discriminator
+	})
+	return riskFindings
+}
+
+// namespaceNames returns the namespaces with service accounts, in alphabetical order
+func (m *EKSCluster) namespaceNames() []string {
+	namespaces := make([]string, 0, len(m.ServiceAccountsByNamespace))
+	for namespace := range m.ServiceAccountsByNamespace {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// firstServiceAccountAssuming returns a service account that can assume a role through IRSA, if any
+func (m *EKSCluster) firstServiceAccountAssuming(roleArn string) (string, string) {
+	for _, namespace := range m.namespaceNames() {
+		for _, serviceAccount := range m.ServiceAccountsByNamespace[namespace] {
+			for _, role := range serviceAccount.AssumableRoles {
+				if role.IAMRole.Arn == roleArn && role.Reason == AssumeIAMRoleReasonIRSA {
+					return namespace, serviceAccount.Name
+				}
+			}
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,103 @@
+package role_relationships
+
+import (
+	"testing"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/snapshot"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func irsaTrustPolicy(conditions string) string {
+	return `{
+  "Version": "2012-10-17",
+  "Statement": [{
+    "Effect": "Allow",
+    "Principal": {"Federated": "arn:aws:iam::111111111111:oidc-provider/` + testIssuer + `"},
+    "Action": "sts:AssumeRoleWithWebIdentity",
+    "Condition": {` + conditions + `}
+  }]
+}`
+}
+
+func TestFindTrustPolicyRisks(t *testing.T) {
+	const audienceCondition = `"StringEquals": {"` + testIssuer + `:aud": "sts.amazonaws.com", "` + testIssuer + `:sub": "system:serviceaccount:default:irsa-sa"}`
+	scenarios := []struct {
+		Name            string
+		TrustPolicy     string
+		ExpectedRuleIDs []string
+	}{
+		{Name: "specific service account and audience", TrustPolicy: irsaTrustPolicy(audienceCondition)},
+		{
+			Name:            "specific service account without audience",
+			TrustPolicy:     irsaTrustPolicy(`"StringEquals": {"` + testIssuer + `:sub": "system:serviceaccount:default:irsa-sa"}`),
+			ExpectedRuleIDs: []string{RuleIDIRSARoleTrustPolicyWithoutAudience},
+		},
+		{
+			Name:            "any service account of a namespace",
+			TrustPolicy:     irsaTrustPolicy(`"StringLike": {"` + testIssuer + `:sub": "system:serviceaccount:default:*"}, "StringEquals": {"` + testIssuer + `:aud": "sts.amazonaws.com"}`),
+			ExpectedRuleIDs: []string{RuleIDIRSARoleTrustsAnyServiceAccountInNamespace},
+		},
+		{
+			Name:            "any service account",
+			TrustPolicy:     irsaTrustPolicy(`"StringLike": {"` + testIssuer + `:sub": "system:serviceaccount:*"}, "StringEquals": {"` + testIssuer + `:aud": "sts.amazonaws.com"}`),
+			ExpectedRuleIDs: []string{RuleIDIRSARoleTrustsAnyServiceAccount},
+		},
+		{
+			Name:            "no condition",
+			TrustPolicy:     irsaTrustPolicy(""),
+			ExpectedRuleIDs: []string{RuleIDIRSARoleTrustsAnyServiceAccount, RuleIDIRSARoleTrustPolicyWithoutAudience},
+		},
+		{Name: "unrelated role", TrustPolicy: `{"Version": "2012-10-17", "Statement": []}`},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.Name, func(t *testing.T) {
+			clusterSnapshot := testSnapshot()
+			clusterSnapshot.IAMRoles = []*snapshot.IAMRole{{Arn: "arn:aws:iam::111111111111:role/irsa-role", TrustPolicy: scenario.TrustPolicy}}
+			cluster := EKSCluster{}
+			assert.Nil(t, cluster.AnalyzeRoleRelationshipsFromSnapshot(clusterSnapshot))
+
+			var ruleIDs []string
+			for _, finding := range cluster.findTrustPolicyRisks() {
+				ruleIDs = append(ruleIDs, finding.RuleID)
+				assert.Equal(t, findings.ResourceTypeIAMRole, finding.Resource.Type)
+				assert.Equal(t, "irsa-role", finding.Resource.Name)
+			}
+			assert.Equal(t, scenario.ExpectedRuleIDs, ruleIDs)
+		})
+	}
+}
+
+func TestFindRelationshipRisks(t *testing.T) {
+	clusterSnapshot := testSnapshot()
+	clusterSnapshot.IAMRoles[0].TrustPolicy = irsaTrustPolicy(`"StringLike": {"` + testIssuer + `:sub": "system:serviceaccount:default:*"}`)
+	clusterSnapshot.ServiceAccounts = append(clusterSnapshot.ServiceAccounts, corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "default"}})
+	clusterSnapshot.Pods = append(clusterSnapshot.Pods,
+		corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "irsa-pod-2", Namespace: "default"}, Spec: corev1.PodSpec{ServiceAccountName: "irsa-sa"}},
+		corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "default-pod", Namespace: "default"}, Spec: corev1.PodSpec{ServiceAccountName: "default"}},
+	)
+	cluster := EKSCluster{}
+	assert.Nil(t, cluster.AnalyzeRoleRelationshipsFromSnapshot(clusterSnapshot))
+
+	relationshipFindings := cluster.findRelationshipRisks()
+	var summaries []string
+	for _, finding := range relationshipFindings {
+		summaries = append(summaries, finding.RuleID+" "+finding.Resource.ID+" "+string(finding.Severity))
+	}
+	// The trust policy of irsa-role lets all service accounts of the namespace assume it
+	assert.Equal(t, []string{
+		RuleIDDefaultServiceAccountCanAssumeRole + " k8s://my-cluster/namespaces/default/serviceaccounts/default medium",
+		RuleIDServiceAccountCanAssumeRole + " k8s://my-cluster/namespaces/default/serviceaccounts/irsa-sa informational",
+		RuleIDServiceAccountCanAssumeRole + " k8s://my-cluster/namespaces/default/serviceaccounts/other-sa informational",
+		RuleIDServiceAccountCanAssumeRole + " k8s://my-cluster/namespaces/default/serviceaccounts/pod-identity-sa informational",
+		RuleIDServiceAccountCanAssumeRole + " k8s://my-cluster/namespaces/default/serviceaccounts/pod-identity-sa informational",
+	}, summaries)
+	assert.Contains(t, relationshipFindings[1].Description, "irsa-pod, irsa-pod-2")
+
+	// Fingerprints only depend on the rule, the resource and the discriminator
+	cluster.PodsByNamespace["default"] = cluster.PodsByNamespace["default"][1:]
+	assert.Equal(t, relationshipFindings[1].Fingerprint(), cluster.findRelationshipRisks()[1].Fingerprint())
+}
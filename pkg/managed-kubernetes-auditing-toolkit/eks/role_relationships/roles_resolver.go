@@ -3,17 +3,14 @@ package role_relationships
 import (
 	"context"
 	"fmt"
-	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/arn"
-	"github.com/aws/aws-sdk-go-v2/service/eks"
-	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
 	"github.com/datadog/managed-kubernetes-auditing-toolkit/internal/aws/iam_evaluation"
-	"github.com/hashicorp/go-version"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/datasource"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/filters"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/snapshot"
 	corev1 "k8s.io/api/core/v1"
-	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
 	"log"
-	"net/url"
 	"strconv"
 	"strings"
 )
@@ -26,12 +23,16 @@ const (
 )
 
 // https://docs.aws.amazon.com/eks/latest/userguide/pod-identities.html#pod-id-cluster-versions
-const PodIdentityMinSupportedK8sVersion = "1.24"
+const PodIdentityMinSupportedK8sVersion = snapshot.PodIdentityMinSupportedK8sVersion
+
+// Audience of the service account tokens EKS injects for IRSA
+const stsAudience = "sts.amazonaws.com"
 
 // AssumableIAMRole records that an IAM role can be assumed through a specific mechanism
 type AssumableIAMRole struct {
-	IAMRole *IAMRole
-	Reason  AssumeIAMRoleReason
+	IAMRole                  *IAMRole
+	Reason                   AssumeIAMRoleReason
+	PodIdentityAssociationID string // set when the role is assumable through Pod Identity
 }
 
 type K8sServiceAccount struct {
@@ -44,8 +45,12 @@ type K8sServiceAccount struct {
 type K8sPod struct {
 	Name                            string
 	Namespace                       string
+	Labels                          map[string]string
+	NodeName                        string
 	ServiceAccount                  *K8sServiceAccount
+	Workload                        *K8sWorkload // nil if the pod isn't managed by a controller
 	HasProjectedServiceAccountToken bool
+	FargateProfile                  *FargateProfile // nil if the pod doesn't run on Fargate
 }
 
 type IAMRole struct {
@@ -62,45 +67,62 @@ type PodIdentityAssociation struct {
 }
 
 type EKSCluster struct {
-	AwsClient *aws.Config
-	K8sClient *kubernetes.Clientset
+	DataSources *datasource.DataSources
+	Filters     *filters.Filters // optional, restricts the Kubernetes resources and IAM roles to analyze
 
 	Name                       string
 	KubernetesVersion          string // e.g. "1.24"
 	AccountID                  string
 	IssuerURL                  string
+	ClusterInfo                *types.Cluster // raw DescribeCluster response
 	ServiceAccountsByNamespace map[string][]*K8sServiceAccount
 	PodsByNamespace            map[string][]*K8sPod
 	IAMRoles                   []*IAMRole
+	FargateProfiles            []*FargateProfile
+	PodIdentityAssociations    []*PodIdentityAssociation
+	PodIdentityAgent           *PodIdentityAgentStatus // nil if the status of the agent could not be determined
 }
 
-func (m *EKSCluster) AnalyzeRoleRelationships() error {
-	// Start by retrieving the cluster information
-	if err := m.retrieveClusterInformation(); err != nil {
-		return fmt.Errorf("unable to retrieve EKS cluster information: %v", err)
-	}
-
-	// Retrieve all service accounts
-	serviceAccountsByNamespace, err := m.retrieveServiceAccountsByNamespace()
+// AnalyzeRoleRelationships retrieves the current state of the cluster and AWS account from the data sources, and
+// analyzes it
+func (m *EKSCluster) AnalyzeRoleRelationships(ctx context.Context) error {
+	collector := snapshot.Collector{DataSources: m.DataSources, ClusterName: m.Name, Filters: m.Filters}
+	clusterSnapshot, err := collector.Collect(ctx)
 	if err != nil {
-		return fmt.Errorf("unable to retrieve service accounts: %v", err)
+		return err
 	}
-	m.ServiceAccountsByNamespace = serviceAccountsByNamespace
+	return m.AnalyzeRoleRelationshipsFromSnapshot(clusterSnapshot)
+}
 
-	// Then, find all pods and link them with the service accounts
-	podsByNamespace, err := m.getPodsByNamespace()
-	if err != nil {
-		return fmt.Errorf("unable to retrieve pods: %v", err)
+// AnalyzeRoleRelationshipsFromSnapshot analyzes a snapshot of the cluster and AWS account, without any API call
+func (m *EKSCluster) AnalyzeRoleRelationshipsFromSnapshot(clusterSnapshot *snapshot.Snapshot) error {
+	m.Name = clusterSnapshot.ClusterName
+	m.applyClusterInformation(clusterSnapshot.Cluster)
+
+	// Fargate profiles tell us which pods run on Fargate
+	m.FargateProfiles = buildFargateProfiles(clusterSnapshot.FargateProfiles)
+
+	// Link pods with their service accounts
+	m.ServiceAccountsByNamespace = buildServiceAccountsByNamespace(clusterSnapshot.ServiceAccounts)
+	m.PodsByNamespace = m.buildPodsByNamespace(clusterSnapshot.Pods)
+
+	m.IAMRoles = []*IAMRole{}
+	for _, role := range clusterSnapshot.IAMRoles {
+		m.IAMRoles = append(m.IAMRoles, &IAMRole{Arn: role.Arn, TrustPolicy: role.TrustPolicy})
 	}
-	m.PodsByNamespace = podsByNamespace
 
-	// Then, retrieve all IAM roles in the account
-	iamRoles, err := m.retrieveIAMRoles()
-	log.Printf("Found %d IAM roles in the AWS account", len(iamRoles))
-	if err != nil {
-		return fmt.Errorf("unable to list IAM roles: %v", err)
+	m.PodIdentityAssociations = []*PodIdentityAssociation{}
+	for _, association := range clusterSnapshot.PodIdentityAssociations {
+		m.PodIdentityAssociations = append(m.PodIdentityAssociations, &PodIdentityAssociation{
+			ID:                 association.ID,
+			Namespace:          association.Namespace,
+			ServiceAccountName: association.ServiceAccountName,
+			RoleArn:            association.RoleArn,
+		})
 	}
-	m.IAMRoles = iamRoles
+
+	// Associations only have an effect if the Pod Identity Agent runs on the node of the pod
+	m.PodIdentityAgent = buildPodIdentityAgentStatus(clusterSnapshot)
 
 	// Finally, launch the analysis for both IRSA and Pod Identity
 	if err := m.AnalyzeRoleRelationshipsForIRSA(); err != nil {
@@ -116,54 +138,17 @@ func (m *EKSCluster) AnalyzeRoleRelationships() error {
 
 func (m *EKSCluster) AnalyzeRoleRelationshipsForPodIdentity() error {
 	log.Println("Analyzing Pod Identity configuration of your cluster")
-	eksClient := eks.NewFromConfig(*m.AwsClient)
 
-	if !m.supportsPodIdentity() {
-		log.Println("Your cluster runs a Kubernetes version that does not support Pod Identity - skipping")
-		log.Println("Your K8s version is " + m.KubernetesVersion + ", and Pod Identity is supported starting from " + PodIdentityMinSupportedK8sVersion)
-		return nil
-	}
-
-	// Step 1: Retrieve all pod associations in the cluster, and keep a map by podAssociationNamespace
-	paginator := eks.NewListPodIdentityAssociationsPaginator(eksClient, &eks.ListPodIdentityAssociationsInput{
-		ClusterName: &m.Name,
-	})
+	// Step 1: Group pod associations by namespace
 	namespaceToPodIdentityAssociations := map[string][]*PodIdentityAssociation{}
-	for paginator.HasMorePages() {
-		podIdentityAssociations, err := paginator.NextPage(context.Background())
-		if err != nil {
-			return fmt.Errorf("unable to retrieve pod identity associations: %v", err)
-		}
-		for _, podIdentityAssociation := range podIdentityAssociations.Associations {
-			namespace := *podIdentityAssociation.Namespace
-			if _, ok := namespaceToPodIdentityAssociations[namespace]; !ok {
-				namespaceToPodIdentityAssociations[namespace] = []*PodIdentityAssociation{}
-			}
-
-			//TODO: This is duplicate because AWS across calls update this value... we need to define our own type
-			association := podIdentityAssociation
-			namespaceToPodIdentityAssociations[namespace] = append(namespaceToPodIdentityAssociations[namespace], &PodIdentityAssociation{
-				ID:                 *association.AssociationId,
-				Namespace:          *association.Namespace,
-				ServiceAccountName: *association.ServiceAccount,
-				RoleArn:            "", // No role ARN yet at this point, as we need a call to DescribePodIdentityAssociation
-			})
-		}
+	for _, association := range m.PodIdentityAssociations {
+		namespaceToPodIdentityAssociations[association.Namespace] = append(namespaceToPodIdentityAssociations[association.Namespace], association)
 	}
 
 	// Step 2: Map assumable roles to pods
 	for podAssociationNamespace := range namespaceToPodIdentityAssociations {
 		log.Println("Analyzing namespace " + podAssociationNamespace + " which has " + strconv.Itoa(len(namespaceToPodIdentityAssociations[podAssociationNamespace])) + " Pod Identity associations")
 		for _, podAssociation := range namespaceToPodIdentityAssociations[podAssociationNamespace] {
-
-			// Retrieve the role attached to the pod identity association
-			podAssociationDetails, err := eksClient.DescribePodIdentityAssociation(context.Background(), &eks.DescribePodIdentityAssociationInput{
-				AssociationId: &podAssociation.ID,
-				ClusterName:   &m.Name,
-			})
-			if err != nil {
-				return fmt.Errorf("unable to describe pod identity association %s: %v", podAssociation.ID, err)
-			}
 			pods, ok := m.PodsByNamespace[podAssociationNamespace]
 			if !ok {
 				// no pods in podAssociationNamespace, go to the next one
@@ -175,10 +160,11 @@ func (m *EKSCluster) AnalyzeRoleRelationshipsForPodIdentity() error {
 
 			// All pods in this podAssociationNamespace with this service account can assume the role
 			for _, pod := range pods {
-				if pod.ServiceAccount.Name == podAssociation.ServiceAccountName {
+				if pod.ServiceAccount != nil && pod.ServiceAccount.Name == podAssociation.ServiceAccountName {
 					assumableIamRole := AssumableIAMRole{
-						IAMRole: &IAMRole{Arn: *podAssociationDetails.Association.RoleArn},
-						Reason:  AssumeIAMRoleReasonPodIdentity,
+						IAMRole:                  &IAMRole{Arn: podAssociation.RoleArn},
+						Reason:                   AssumeIAMRoleReasonPodIdentity,
+						PodIdentityAssociationID: podAssociation.ID,
 					}
 
 					// Did we already find this role for this SA? (case where multiple pods have the same SA)
@@ -217,19 +203,7 @@ func (m *EKSCluster) AnalyzeRoleRelationshipsForIRSA() error {
 		// Iterate over all service accounts in the cluster and figure out which ones can assume the role
 		for namespace, serviceAccounts := range m.ServiceAccountsByNamespace {
 			for _, serviceAccount := range serviceAccounts {
-				authzContext := iam_evaluation.AuthorizationContext{
-					Action: "sts:AssumeRoleWithWebIdentity",
-					Principal: &iam_evaluation.Principal{
-						Type: iam_evaluation.PrincipalTypeFederated,
-						ID:   fmt.Sprintf("arn:aws:iam::%s:oidc-provider/%s", m.AccountID, m.IssuerURL),
-					},
-					ContextKeys: map[string]string{
-						fmt.Sprintf("%s:sub", m.IssuerURL): fmt.Sprintf("system:serviceaccount:%s:%s", namespace, serviceAccount.Name),
-						fmt.Sprintf("%s:aud", m.IssuerURL): "sts.amazonaws.com",
-					},
-				}
-
-				if *trustPolicy.Authorize(&authzContext) == iam_evaluation.AuthorizationResultAllow {
+				if m.allowsWebIdentity(trustPolicy, namespace, serviceAccount.Name, stsAudience) {
 					serviceAccount.AssumableRoles = append(serviceAccount.AssumableRoles, &assumableIamRole)
 				}
 			}
@@ -239,61 +213,51 @@ func (m *EKSCluster) AnalyzeRoleRelationshipsForIRSA() error {
 	return nil
 }
 
-func (m *EKSCluster) retrieveClusterInformation() error {
-	log.Println("Retrieving cluster information")
-	clusterInfo, err := eks.NewFromConfig(*m.AwsClient).DescribeCluster(context.Background(), &eks.DescribeClusterInput{
-		Name: &m.Name,
-	})
-	if err != nil {
-		return fmt.Errorf("unable to retrieve cluster OIDC issuer: %v", err)
-	}
-	if clusterInfo.Cluster.Identity == nil || clusterInfo.Cluster.Identity.Oidc == nil {
-		// The cluster has no OIDC provider
-		m.IssuerURL = ""
-		return nil
+// allowsWebIdentity determines if a trust policy lets a service account assume the role through IRSA, with a token
+// for a given audience
+func (m *EKSCluster) allowsWebIdentity(trustPolicy *iam_evaluation.Policy, namespace string, serviceAccountName string, audience string) bool {
+	authzContext := iam_evaluation.AuthorizationContext{
+		Action: "sts:AssumeRoleWithWebIdentity",
+		Principal: &iam_evaluation.Principal{
+			Type: iam_evaluation.PrincipalTypeFederated,
+			ID:   fmt.Sprintf("arn:aws:iam::%s:oidc-provider/%s", m.AccountID, m.IssuerURL),
+		},
+		ContextKeys: map[string]string{
+			fmt.Sprintf("%s:sub", m.IssuerURL): fmt.Sprintf("system:serviceaccount:%s:%s", namespace, serviceAccountName),
+			fmt.Sprintf("%s:aud", m.IssuerURL): audience,
+		},
 	}
+	return *trustPolicy.Authorize(&authzContext) == iam_evaluation.AuthorizationResultAllow
+}
 
-	parsedClusterArn, _ := arn.Parse(*clusterInfo.Cluster.Arn)
-	m.AccountID = parsedClusterArn.AccountID
-	m.IssuerURL = strings.Replace(*clusterInfo.Cluster.Identity.Oidc.Issuer, "https://", "", 1)
-	m.KubernetesVersion = *clusterInfo.Cluster.Version
+// RetrieveClusterInformation retrieves the EKS cluster information from the cluster data source
+func (m *EKSCluster) RetrieveClusterInformation(ctx context.Context) error {
+	collector := snapshot.Collector{DataSources: m.DataSources, ClusterName: m.Name}
+	clusterInfo, err := collector.DescribeCluster(ctx)
+	if err != nil {
+		return err
+	}
+	m.applyClusterInformation(clusterInfo)
 	return nil
 }
 
-func (m *EKSCluster) retrieveIAMRoles() ([]*IAMRole, error) {
-	log.Println("Listing roles in the AWS account")
-	paginator := iam.NewListRolesPaginator(iam.NewFromConfig(*m.AwsClient, func(options *iam.Options) {
-		options.Region = "us-east-1"
-	}), &iam.ListRolesInput{})
-	allIAMRoles := []*IAMRole{}
-	for paginator.HasMorePages() {
-		roles, err := paginator.NextPage(context.Background())
-		if err != nil {
-			return nil, fmt.Errorf("unable to list roles in the AWS account: %v", err)
-		}
-		for _, role := range roles.Roles {
-			trustPolicy, err := url.PathUnescape(*role.AssumeRolePolicyDocument)
-			if err != nil {
-				return nil, err
-			}
-			role := IAMRole{
-				Arn:         *role.Arn,
-				TrustPolicy: trustPolicy,
-			}
-			allIAMRoles = append(allIAMRoles, &role)
-		}
+func (m *EKSCluster) applyClusterInformation(clusterInfo *types.Cluster) {
+	m.ClusterInfo = clusterInfo
+
+	parsedClusterArn, _ := arn.Parse(*clusterInfo.Arn)
+	m.AccountID = parsedClusterArn.AccountID
+	m.KubernetesVersion = *clusterInfo.Version
+	if clusterInfo.Identity == nil || clusterInfo.Identity.Oidc == nil {
+		// The cluster has no OIDC provider
+		m.IssuerURL = ""
+		return
 	}
-	return allIAMRoles, nil
+	m.IssuerURL = strings.Replace(*clusterInfo.Identity.Oidc.Issuer, "https://", "", 1)
 }
 
-func (m *EKSCluster) retrieveServiceAccountsByNamespace() (map[string][]*K8sServiceAccount, error) {
-	log.Println("Listing K8s service accounts in all namespaces")
+func buildServiceAccountsByNamespace(serviceAccounts []corev1.ServiceAccount) map[string][]*K8sServiceAccount {
 	serviceAccountsByNamespace := make(map[string][]*K8sServiceAccount)
-	serviceAccounts, err := m.K8sClient.CoreV1().ServiceAccounts("").List(context.Background(), v1.ListOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("unable to list K8s service accounts: %v", err)
-	}
-	for _, serviceAccount := range serviceAccounts.Items {
+	for _, serviceAccount := range serviceAccounts {
 		namespace := serviceAccount.Namespace
 		serviceAccountsByNamespace[namespace] = append(serviceAccountsByNamespace[namespace], &K8sServiceAccount{
 			Name:           serviceAccount.Name,
@@ -302,16 +266,13 @@ func (m *EKSCluster) retrieveServiceAccountsByNamespace() (map[string][]*K8sServ
 			AssumableRoles: []*AssumableIAMRole{},
 		})
 	}
-	return serviceAccountsByNamespace, nil
+	return serviceAccountsByNamespace
 }
 
-func (m *EKSCluster) getPodsByNamespace() (map[string][]*K8sPod, error) {
-	pods, err := m.K8sClient.CoreV1().Pods("").List(context.Background(), v1.ListOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("unable to list K8s pods: %v", err)
-	}
+func (m *EKSCluster) buildPodsByNamespace(pods []corev1.Pod) map[string][]*K8sPod {
 	podsByNamespace := make(map[string][]*K8sPod)
-	for _, pod := range pods.Items {
+	for i := range pods {
+		pod := &pods[i]
 		namespace := pod.Namespace
 		var serviceAccount *K8sServiceAccount = nil
 		candidateServiceAccounts := m.ServiceAccountsByNamespace[namespace]
@@ -324,24 +285,16 @@ func (m *EKSCluster) getPodsByNamespace() (map[string][]*K8sPod, error) {
 		podsByNamespace[namespace] = append(podsByNamespace[namespace], &K8sPod{
 			Name:                            pod.Name,
 			Namespace:                       namespace,
+			Labels:                          pod.Labels,
+			NodeName:                        pod.Spec.NodeName,
 			ServiceAccount:                  serviceAccount,
-			HasProjectedServiceAccountToken: hasProjectedServiceAccountToken(&pod),
+			Workload:                        podWorkload(pod),
+			HasProjectedServiceAccountToken: hasProjectedServiceAccountToken(pod),
+			FargateProfile:                  MatchFargateProfile(namespace, pod.Labels, pod.Spec.NodeName, m.FargateProfiles),
 		})
 	}
 
-	return podsByNamespace, nil
-}
-
-func (m *EKSCluster) supportsPodIdentity() bool {
-	currentVersion, err := version.NewVersion(m.KubernetesVersion)
-	minimumVersion, err2 := version.NewVersion(PodIdentityMinSupportedK8sVersion)
-	if err != nil || err2 != nil {
-		log.Println("WARNING: Unable to parse cluster K8s version, assuming it's >= 1.24 and supports Pod Identity")
-		log.Println("Error: " + err.Error() + " " + err2.Error())
-		return true
-	}
-
-	return currentVersion.GreaterThanOrEqual(minimumVersion)
+	return podsByNamespace
 }
 
 func hasProjectedServiceAccountToken(pod *corev1.Pod) bool {
@@ -355,7 +308,7 @@ func hasProjectedServiceAccountToken(pod *corev1.Pod) bool {
 			if saSource == nil {
 				continue
 			}
-			if saSource.Audience == "sts.amazonaws.com" {
+			if saSource.Audience == stsAudience {
 				return true
 			}
 		}
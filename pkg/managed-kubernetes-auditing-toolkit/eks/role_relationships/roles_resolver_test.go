@@ -0,0 +1,120 @@
+package role_relationships
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/datasource"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/snapshot"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testIssuer = "oidc.eks.us-east-1.amazonaws.com/id/EXAMPLE"
+
+const testIrsaTrustPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": [{
+    "Effect": "Allow",
+    "Principal": {"Federated": "arn:aws:iam::111111111111:oidc-provider/` + testIssuer + `"},
+    "Action": "sts:AssumeRoleWithWebIdentity",
+    "Condition": {"StringEquals": {"` + testIssuer + `:sub": "system:serviceaccount:default:irsa-sa"}}
+  }]
+}`
+
+func testSnapshot() *snapshot.Snapshot {
+	return &snapshot.Snapshot{
+		FormatVersion: snapshot.FormatVersion,
+		ClusterName:   "my-cluster",
+		Cluster: &types.Cluster{
+			Name:     aws.String("my-cluster"),
+			Arn:      aws.String("arn:aws:eks:us-east-1:111111111111:cluster/my-cluster"),
+			Version:  aws.String("1.28"),
+			Identity: &types.Identity{Oidc: &types.OIDC{Issuer: aws.String("https://" + testIssuer)}},
+		},
+		IAMRoles: []*snapshot.IAMRole{
+			{Arn: "arn:aws:iam::111111111111:role/irsa-role", TrustPolicy: testIrsaTrustPolicy},
+			{Arn: "arn:aws:iam::111111111111:role/unrelated-role", TrustPolicy: `{"Version": "2012-10-17", "Statement": []}`},
+		},
+		PodIdentityAssociations: []*snapshot.PodIdentityAssociation{
+			{ID: "a-1", Namespace: "default", ServiceAccountName: "pod-identity-sa", RoleArn: "arn:aws:iam::111111111111:role/pod-identity-role"},
+		},
+		ServiceAccounts: []corev1.ServiceAccount{
+			{ObjectMeta: metav1.ObjectMeta{Name: "irsa-sa", Namespace: "default"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "pod-identity-sa", Namespace: "default"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "other-sa", Namespace: "default"}},
+		},
+		Pods: []corev1.Pod{
+			{ObjectMeta: metav1.ObjectMeta{Name: "irsa-pod", Namespace: "default"}, Spec: corev1.PodSpec{ServiceAccountName: "irsa-sa"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "pod-identity-pod", Namespace: "default"}, Spec: corev1.PodSpec{ServiceAccountName: "pod-identity-sa"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "other-pod", Namespace: "default"}, Spec: corev1.PodSpec{ServiceAccountName: "other-sa"}},
+		},
+	}
+}
+
+func TestAnalyzesRoleRelationshipsFromSnapshot(t *testing.T) {
+	cluster := EKSCluster{}
+	err := cluster.AnalyzeRoleRelationshipsFromSnapshot(testSnapshot())
+	assert.Nil(t, err)
+
+	assert.Equal(t, "my-cluster", cluster.Name)
+	assert.Equal(t, "111111111111", cluster.AccountID)
+	assert.Equal(t, testIssuer, cluster.IssuerURL)
+	assert.Nil(t, cluster.PodIdentityAgent)
+
+	assumableRoles := map[string][]string{}
+	for _, pod := range cluster.PodsByNamespace["default"] {
+		for _, role := range pod.ServiceAccount.AssumableRoles {
+			assumableRoles[pod.Name] = append(assumableRoles[pod.Name], role.IAMRole.Arn+" ("+string(role.Reason)+")")
+		}
+	}
+	assert.Equal(t, map[string][]string{
+		"irsa-pod":         {"arn:aws:iam::111111111111:role/irsa-role (" + AssumeIAMRoleReasonIRSA + ")"},
+		"pod-identity-pod": {"arn:aws:iam::111111111111:role/pod-identity-role (" + AssumeIAMRoleReasonPodIdentity + ")"},
+	}, assumableRoles)
+}
+
+func TestAnalyzesRoleRelationshipsFromSnapshotWithoutOIDCProvider(t *testing.T) {
+	clusterSnapshot := testSnapshot()
+	clusterSnapshot.Cluster.Identity = nil
+
+	cluster := EKSCluster{}
+	err := cluster.AnalyzeRoleRelationshipsFromSnapshot(clusterSnapshot)
+	assert.Nil(t, err)
+	for _, pod := range cluster.PodsByNamespace["default"] {
+		for _, role := range pod.ServiceAccount.AssumableRoles {
+			assert.Equal(t, AssumeIAMRoleReason(AssumeIAMRoleReasonPodIdentity), role.Reason)
+		}
+	}
+}
+
+func TestAnalyzesRoleRelationshipsFromDataSources(t *testing.T) {
+	clusterSnapshot := testSnapshot()
+	dataSource := &datasource.InMemory{
+		Clusters:                []*types.Cluster{clusterSnapshot.Cluster},
+		PodIdentityAssociations: map[string][]*datasource.PodIdentityAssociation{"my-cluster": clusterSnapshot.PodIdentityAssociations},
+		IAMRoles:                clusterSnapshot.IAMRoles,
+		ServiceAccounts:         clusterSnapshot.ServiceAccounts,
+		Pods:                    clusterSnapshot.Pods,
+	}
+
+	cluster := EKSCluster{DataSources: dataSource.DataSources(), Name: "my-cluster"}
+	err := cluster.AnalyzeRoleRelationships(context.Background())
+	assert.Nil(t, err)
+
+	// The Pod Identity Agent is not installed, so the Pod Identity association has no effect
+	assert.NotNil(t, cluster.PodIdentityAgent)
+	for _, pod := range cluster.PodsByNamespace["default"] {
+		for _, role := range pod.ServiceAccount.AssumableRoles {
+			if role.Reason == AssumeIAMRoleReasonPodIdentity {
+				assert.Equal(t, "pod-identity-pod", pod.Name)
+				assert.Equal(t, "the eks-pod-identity-agent add-on is not installed", cluster.PodIdentityIneffectiveReason(pod, role))
+			} else {
+				assert.Equal(t, "irsa-pod", pod.Name)
+			}
+		}
+	}
+}
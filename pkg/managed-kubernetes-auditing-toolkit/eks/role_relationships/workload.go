@@ -0,0 +1,32 @@
+package role_relationships
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// K8sWorkload is the controller managing a pod, e.g. a Deployment
+type K8sWorkload struct {
+	Kind string
+	Name string
+}
+
+// Label that the Deployment controller adds to its ReplicaSets and their pods
+const podTemplateHashLabel = "pod-template-hash"
+
+// podWorkload determines the controller managing a pod, or returns nil for standalone pods. Pods of a ReplicaSet
+// created by a Deployment are attributed to the Deployment.
+func podWorkload(pod *corev1.Pod) *K8sWorkload {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return nil
+	}
+	if owner.Kind == "ReplicaSet" {
+		if hash, found := pod.Labels[podTemplateHashLabel]; found && strings.HasSuffix(owner.Name, "-"+hash) {
+			return &K8sWorkload{Kind: "Deployment", Name: strings.TrimSuffix(owner.Name, "-"+hash)}
+		}
+	}
+	return &K8sWorkload{Kind: owner.Kind, Name: owner.Name}
+}
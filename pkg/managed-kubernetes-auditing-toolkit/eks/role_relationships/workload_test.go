@@ -0,0 +1,52 @@
+package role_relationships
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodWorkload(t *testing.T) {
+	controller := true
+	scenarios := []struct {
+		Name             string
+		Labels           map[string]string
+		Owners           []metav1.OwnerReference
+		ExpectedWorkload *K8sWorkload
+	}{
+		{"standalone pod", nil, nil, nil},
+		{
+			"pod of a Deployment",
+			map[string]string{"pod-template-hash": "5f8b8d6f4"},
+			[]metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web-5f8b8d6f4", Controller: &controller}},
+			&K8sWorkload{Kind: "Deployment", Name: "web"},
+		},
+		{
+			"pod of a standalone ReplicaSet",
+			nil,
+			[]metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web", Controller: &controller}},
+			&K8sWorkload{Kind: "ReplicaSet", Name: "web"},
+		},
+		{
+			"pod of a StatefulSet",
+			nil,
+			[]metav1.OwnerReference{{Kind: "StatefulSet", Name: "db", Controller: &controller}},
+			&K8sWorkload{Kind: "StatefulSet", Name: "db"},
+		},
+		{
+			"pod with an owner that isn't its controller",
+			nil,
+			[]metav1.OwnerReference{{Kind: "ConfigMap", Name: "config"}},
+			nil,
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.Name, func(t *testing.T) {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Labels: scenario.Labels, OwnerReferences: scenario.Owners}}
+			assert.Equal(t, scenario.ExpectedWorkload, podWorkload(pod))
+		})
+	}
+}
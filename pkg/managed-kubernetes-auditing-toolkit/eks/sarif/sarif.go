@@ -0,0 +1,244 @@
+package sarif
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+)
+
+const (
+	Version   = "2.1.0"
+	SchemaURI = "https://json.schemastore.org/sarif-2.1.0.json"
+
+	toolName           = "mkat"
+	toolInformationURI = "https://github.com/DataDog/managed-kubernetes-auditing-toolkit"
+
+	// Key of the fingerprints we compute in partialFingerprints. The suffix is incremented if their computation changes.
+	FingerprintKey = "mkatFingerprint/v1"
+)
+
+// Log is a SARIF 2.1.0 log. Only the properties MKAT uses are modeled.
+// c.f. https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+type Log struct {
+	Schema  string `json:"$schema"`
+	Version string `json:"version"`
+	Runs    []*Run `json:"runs"`
+}
+
+type Run struct {
+	Tool              Tool               `json:"tool"`
+	AutomationDetails *AutomationDetails `json:"automationDetails,omitempty"`
+	Results           []*Result          `json:"results"`
+}
+
+type Tool struct {
+	Driver Driver `json:"driver"`
+}
+
+type Driver struct {
+	Name           string                 `json:"name"`
+	Version        string                 `json:"version,omitempty"`
+	InformationURI string                 `json:"informationUri"`
+	Rules          []*ReportingDescriptor `json:"rules"`
+}
+
+// AutomationDetails identifies the category of a run, so that consumers such as GitHub code scanning don't close
+// alerts of a command when uploading the results of another one
+type AutomationDetails struct {
+	ID string `json:"id"`
+}
+
+// ReportingDescriptor describes a rule
+type ReportingDescriptor struct {
+	ID                   string               `json:"id"`
+	Name                 string               `json:"name"`
+	ShortDescription     Message              `json:"shortDescription"`
+	Help                 Message              `json:"help"`
+	DefaultConfiguration Configuration        `json:"defaultConfiguration"`
+	Properties           DescriptorProperties `json:"properties"`
+}
+
+type Configuration struct {
+	Level Level `json:"level"`
+}
+
+type DescriptorProperties struct {
+	Tags []string `json:"tags"`
+	// Score from 0.0 to 10.0 that GitHub code scanning uses to display the severity of security alerts
+	SecuritySeverity string `json:"security-severity"`
+}
+
+type Result struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               Level             `json:"level"`
+	Message             Message           `json:"message"`
+	Locations           []*Location       `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+	Properties          ResultProperties  `json:"properties"`
+}
+
+type ResultProperties struct {
+	Severity     findings.Severity     `json:"severity"`
+	ResourceType findings.ResourceType `json:"resourceType"`
+}
+
+type Message struct {
+	Text string `json:"text"`
+}
+
+type Location struct {
+	PhysicalLocation PhysicalLocation   `json:"physicalLocation"`
+	LogicalLocations []*LogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+}
+
+// ArtifactLocation points to the resource of a finding: an ARN for AWS resources, a k8s:// URI for Kubernetes resources
+type ArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type LogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+type Level string
+
+const (
+	LevelError   Level = "error"
+	LevelWarning Level = "warning"
+	LevelNote    Level = "note"
+)
+
+var levels = map[findings.Severity]Level{
+	findings.SeverityCritical:      LevelError,
+	findings.SeverityHigh:          LevelError,
+	findings.SeverityMedium:        LevelWarning,
+	findings.SeverityLow:           LevelNote,
+	findings.SeverityInformational: LevelNote,
+}
+
+// Rules have the highest severity of their results, since findings of the same rule can have different severities
+var severityRanks = map[findings.Severity]int{
+	findings.SeverityInformational: 0,
+	findings.SeverityLow:           1,
+	findings.SeverityMedium:        2,
+	findings.SeverityHigh:          3,
+	findings.SeverityCritical:      4,
+}
+
+// c.f. https://docs.github.com/en/code-security/code-scanning/integrating-with-code-scanning/sarif-support-for-code-scanning#reportingdescriptor-object
+var securitySeverities = map[findings.Severity]string{
+	findings.SeverityCritical:      "9.5",
+	findings.SeverityHigh:          "8.0",
+	findings.SeverityMedium:        "5.5",
+	findings.SeverityLow:           "3.0",
+	findings.SeverityInformational: "0.0",
+}
+
+// NewLog converts findings into a SARIF log with a single run. category distinguishes the runs of different commands
+// or clusters, e.g. "find-secrets/my-cluster". Results are sorted so that the same findings produce the same log.
+func NewLog(toolVersion string, category string, mkatFindings []*findings.Finding) *Log {
+	sortedFindings := append([]*findings.Finding{}, mkatFindings...)
+	sort.SliceStable(sortedFindings, func(i, j int) bool {
+		first, second := sortedFindings[i], sortedFindings[j]
+		if first.RuleID != second.RuleID {
+			return first.RuleID < second.RuleID
+		}
+		if first.Resource.ID != second.Resource.ID {
+			return first.Resource.ID < second.Resource.ID
+		}
+		return first.Discriminator < second.Discriminator
+	})
+
+	run := &Run{
+		Tool:    Tool{Driver: Driver{Name: toolName, Version: toolVersion, InformationURI: toolInformationURI, Rules: []*ReportingDescriptor{}}},
+		Results: []*Result{},
+	}
+	if category != "" {
+		run.AutomationDetails = &AutomationDetails{ID: category + "/"}
+	}
+	ruleIndexes := map[string]int{}
+	var ruleSeverities []findings.Severity
+	for _, finding := range sortedFindings {
+		ruleIndex, found := ruleIndexes[finding.RuleID]
+		if !found {
+			// Findings are sorted by rule, so rules end up sorted by ID as well
+			ruleIndex = len(run.Tool.Driver.Rules)
+			ruleIndexes[finding.RuleID] = ruleIndex
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, newRule(finding))
+			ruleSeverities = append(ruleSeverities, finding.Severity)
+		}
+		if severityRanks[finding.Severity] > severityRanks[ruleSeverities[ruleIndex]] {
+			ruleSeverities[ruleIndex] = finding.Severity
+		}
+		run.Results = append(run.Results, newResult(finding, ruleIndex))
+	}
+	for i, rule := range run.Tool.Driver.Rules {
+		rule.DefaultConfiguration.Level = levels[ruleSeverities[i]]
+		rule.Properties.SecuritySeverity = securitySeverities[ruleSeverities[i]]
+	}
+	return &Log{Schema: SchemaURI, Version: Version, Runs: []*Run{run}}
+}
+
+// newRule describes the rule of a finding. Its severity is set once all its results are known.
+func newRule(finding *findings.Finding) *ReportingDescriptor {
+	return &ReportingDescriptor{
+		ID:               finding.RuleID,
+		Name:             ruleName(finding.RuleID),
+		ShortDescription: Message{Text: finding.Title},
+		Help:             Message{Text: finding.Remediation},
+		Properties:       DescriptorProperties{Tags: []string{"security"}},
+	}
+}
+
+func newResult(finding *findings.Finding, ruleIndex int) *Result {
+	logicalLocation := &LogicalLocation{
+		Name:               finding.Resource.Name,
+		FullyQualifiedName: finding.Resource.ID,
+		Kind:               string(finding.Resource.Type),
+	}
+	if finding.Resource.Namespace != "" && finding.Resource.Type != findings.ResourceTypeKubernetesNamespace {
+		logicalLocation.FullyQualifiedName = finding.Resource.Namespace + "/" + finding.Resource.Name
+	}
+	return &Result{
+		RuleID:    finding.RuleID,
+		RuleIndex: ruleIndex,
+		Level:     levels[finding.Severity],
+		Message:   Message{Text: finding.Description},
+		Locations: []*Location{{
+			PhysicalLocation: PhysicalLocation{ArtifactLocation: ArtifactLocation{URI: finding.Resource.ID}},
+			LogicalLocations: []*LogicalLocation{logicalLocation},
+		}},
+		PartialFingerprints: map[string]string{FingerprintKey: finding.Fingerprint()},
+		Properties:          ResultProperties{Severity: finding.Severity, ResourceType: finding.Resource.Type},
+	}
+}
+
+// ruleName converts a rule ID such as "eks-private-endpoint-disabled" to "EksPrivateEndpointDisabled"
+func ruleName(ruleID string) string {
+	words := strings.Split(ruleID, "-")
+	for i, word := range words {
+		if word != "" {
+			words[i] = strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return strings.Join(words, "")
+}
+
+// Marshal serializes the log to JSON
+func (m *Log) Marshal() ([]byte, error) {
+	output, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialize SARIF log: %v", err)
+	}
+	return append(output, '\n'), nil
+}
@@ -0,0 +1,112 @@
+package sarif
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+	"github.com/stretchr/testify/assert"
+)
+
+func testFindings() []*findings.Finding {
+	return []*findings.Finding{
+		{
+			RuleID:        "k8s-hardcoded-aws-credentials",
+			Title:         "Hardcoded AWS credentials",
+			Description:   "Pod app/web contains the AWS access key ID AKIA... in environment variable KEY",
+			Severity:      findings.SeverityHigh,
+			Remediation:   "Revoke the credentials",
+			Resource:      findings.KubernetesResource("my-cluster", findings.ResourceTypeKubernetesPod, "app", "web"),
+			Discriminator: "environment variable KEY|AKIA",
+		},
+		{
+			RuleID:      "eks-irsa-role-trust-policy-without-audience",
+			Title:       "IRSA trust policy does not check the token audience",
+			Description: "The trust policy of my-role accepts service account tokens issued for any audience",
+			Severity:    findings.SeverityLow,
+			Remediation: "Add an audience condition",
+			Resource:    findings.IAMRoleResource("arn:aws:iam::123456789012:role/my-role"),
+		},
+		{
+			RuleID:        "k8s-hardcoded-aws-credentials",
+			Title:         "Hardcoded AWS credentials",
+			Description:   "ConfigMap app/config contains the AWS access key ID AKIA... in key creds",
+			Severity:      findings.SeverityHigh,
+			Remediation:   "Revoke the credentials",
+			Resource:      findings.KubernetesResource("my-cluster", findings.ResourceTypeKubernetesConfigMap, "app", "config"),
+			Discriminator: "key creds|AKIA",
+		},
+	}
+}
+
+func TestNewLog(t *testing.T) {
+	log := NewLog("1.2.3", "find-secrets/my-cluster", testFindings())
+
+	assert.Equal(t, "2.1.0", log.Version)
+	assert.Len(t, log.Runs, 1)
+	run := log.Runs[0]
+	assert.Equal(t, "mkat", run.Tool.Driver.Name)
+	assert.Equal(t, "1.2.3", run.Tool.Driver.Version)
+	assert.Equal(t, "find-secrets/my-cluster/", run.AutomationDetails.ID)
+
+	// One rule per rule ID, sorted
+	assert.Len(t, run.Tool.Driver.Rules, 2)
+	assert.Equal(t, "eks-irsa-role-trust-policy-without-audience", run.Tool.Driver.Rules[0].ID)
+	assert.Equal(t, "EksIrsaRoleTrustPolicyWithoutAudience", run.Tool.Driver.Rules[0].Name)
+	assert.Equal(t, LevelNote, run.Tool.Driver.Rules[0].DefaultConfiguration.Level)
+	assert.Equal(t, "8.0", run.Tool.Driver.Rules[1].Properties.SecuritySeverity)
+
+	// Results are sorted by rule and resource, and reference their rule
+	assert.Len(t, run.Results, 3)
+	assert.Equal(t, 0, run.Results[0].RuleIndex)
+	assert.Equal(t, "arn:aws:iam::123456789012:role/my-role", run.Results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	assert.Equal(t, "my-role", run.Results[0].Locations[0].LogicalLocations[0].Name)
+	assert.Equal(t, 1, run.Results[1].RuleIndex)
+	assert.Equal(t, LevelError, run.Results[1].Level)
+	assert.Equal(t, "k8s://my-cluster/namespaces/app/configmaps/config", run.Results[1].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	assert.Equal(t, "app/config", run.Results[1].Locations[0].LogicalLocations[0].FullyQualifiedName)
+	assert.Equal(t, "k8s://my-cluster/namespaces/app/pods/web", run.Results[2].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+}
+
+func TestRulesHaveTheHighestSeverityOfTheirResults(t *testing.T) {
+	mkatFindings := testFindings()
+	// Credentials in Secrets are less severe, and live credentials more severe
+	mkatFindings[0].Severity = findings.SeverityCritical
+	mkatFindings[2].Severity = findings.SeverityMedium
+	for _, order := range [][]*findings.Finding{mkatFindings, {mkatFindings[2], mkatFindings[1], mkatFindings[0]}} {
+		run := NewLog("1.2.3", "", order).Runs[0]
+		rule := run.Tool.Driver.Rules[1]
+		assert.Equal(t, LevelError, rule.DefaultConfiguration.Level)
+		assert.Equal(t, "9.5", rule.Properties.SecuritySeverity)
+		assert.Equal(t, LevelWarning, run.Results[1].Level)
+		assert.Equal(t, LevelError, run.Results[2].Level)
+	}
+}
+
+func TestFingerprintsAreStable(t *testing.T) {
+	first := NewLog("1.0.0", "", testFindings())
+	changedFindings := testFindings()
+	changedFindings[0].Description = "the description changed"
+	changedFindings[0].Severity = findings.SeverityCritical
+	second := NewLog("1.1.0", "", changedFindings)
+
+	for i := range first.Runs[0].Results {
+		fingerprint := first.Runs[0].Results[i].PartialFingerprints[FingerprintKey]
+		assert.Len(t, fingerprint, 64)
+		assert.Equal(t, fingerprint, second.Runs[0].Results[i].PartialFingerprints[FingerprintKey])
+	}
+	assert.NotEqual(t, first.Runs[0].Results[1].PartialFingerprints, first.Runs[0].Results[2].PartialFingerprints)
+	assert.Nil(t, first.Runs[0].AutomationDetails)
+}
+
+func TestMarshalEmptyLog(t *testing.T) {
+	output, err := NewLog("", "", nil).Marshal()
+	assert.Nil(t, err)
+
+	var parsed map[string]interface{}
+	assert.Nil(t, json.Unmarshal(output, &parsed))
+	assert.Equal(t, SchemaURI, parsed["$schema"])
+	run := parsed["runs"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, []interface{}{}, run["results"])
+	assert.Equal(t, []interface{}{}, run["tool"].(map[string]interface{})["driver"].(map[string]interface{})["rules"])
+}
@@ -0,0 +1,150 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Annotation in which kubectl apply stores the whole object, including the data of Secrets
+const lastAppliedConfigurationAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// Path of a container of a pod or of a pod template, e.g. "spec.template.spec.containers[0]"
+var containerPathPattern = regexp.MustCompile(`^(.*\.)?(containers|initContainers|ephemeralContainers)\[[0-9]+\]`)
+
+// findSecretsInLastAppliedConfiguration scans the copy of an object that kubectl apply stores in its annotations.
+// Like in the object itself, tokens are only paired with other tokens of the annotation found in the same container.
+// Tokens whose value is the same in the object are only reported in the object.
+func findSecretsInLastAppliedConfiguration(resource scannedResource, object metav1.Object, detectors []Detector) []*SecretInfo {
+	rawConfiguration, found := object.GetAnnotations()[lastAppliedConfigurationAnnotation]
+	if !found {
+		return nil
+	}
+	location := "annotation " + lastAppliedConfigurationAnnotation
+	var configuration interface{}
+	if err := json.Unmarshal([]byte(rawConfiguration), &configuration); err != nil {
+		return findCredentials(detectors, resource, []scannedValue{{Location: location, Value: rawConfiguration}})
+	}
+	if resource.Type == "Secret" {
+		decodeSecretData(configuration)
+	}
+
+	liveValues := objectValues(resource, object)
+	liveLocations := map[string]bool{}
+	var containerPaths []string
+	valuesByContainer := map[string][]scannedValue{} // values outside containers have an empty container path
+	walkJSON(configuration, "", "", func(path string, key string, value string) {
+		valueLocation := "field " + path + " of " + location
+		if liveValue, found := liveValues[path]; found && liveValue == value {
+			liveLocations[valueLocation] = true
+		}
+		containerPath := containerPathPattern.FindString(path)
+		if _, found := valuesByContainer[containerPath]; !found {
+			containerPaths = append(containerPaths, containerPath)
+		}
+		valuesByContainer[containerPath] = append(valuesByContainer[containerPath], scannedValue{Location: valueLocation, Key: key, Value: value})
+	})
+
+	var secrets []*SecretInfo
+	for _, containerPath := range containerPaths {
+		for _, secret := range findCredentials(detectors, resource, valuesByContainer[containerPath]) {
+			valueLocation, _, _ := strings.Cut(secret.Location, decodingChainSeparator)
+			if !liveLocations[valueLocation] {
+				secrets = append(secrets, secret)
+			}
+		}
+	}
+	return secrets
+}
+
+// objectValues returns the strings of an object by path, in the same way as they're found in its last applied
+// configuration
+func objectValues(resource scannedResource, object metav1.Object) map[string]string {
+	values := map[string]string{}
+	rawObject, err := json.Marshal(object)
+	if err != nil {
+		return values
+	}
+	var liveConfiguration interface{}
+	if err := json.Unmarshal(rawObject, &liveConfiguration); err != nil {
+		return values
+	}
+	if resource.Type == "Secret" {
+		decodeSecretData(liveConfiguration)
+	}
+	walkJSON(liveConfiguration, "", "", func(path string, _ string, value string) {
+		values[path] = value
+	})
+	return values
+}
+
+// decodeSecretData decodes the base64-encoded data of a Secret and merges its stringData into it, as the API server
+// does, so that its values can be scanned. Like in the Secret itself, private keys are ignored in Secrets whose type is
+// meant to hold them.
+func decodeSecretData(configuration interface{}) {
+	object, isObject := configuration.(map[string]interface{})
+	if !isObject {
+		return
+	}
+	data, isObject := object["data"].(map[string]interface{})
+	if !isObject {
+		data = map[string]interface{}{}
+	}
+	for key, value := range data {
+		if encodedValue, isString := value.(string); isString {
+			if decodedValue, err := base64.StdEncoding.DecodeString(encodedValue); err == nil {
+				data[key] = string(decodedValue)
+			}
+		}
+	}
+	if stringData, isObject := object["stringData"].(map[string]interface{}); isObject {
+		for key, value := range stringData {
+			data[key] = value
+		}
+		delete(object, "stringData")
+	}
+	secretType, _ := object["type"].(string)
+	if expectedKey, found := privateKeySecretKeys[v1.SecretType(secretType)]; found {
+		delete(data, expectedKey)
+	}
+	if len(data) > 0 {
+		object["data"] = data
+	}
+}
+
+// walkJSON calls handleValue for every string of a JSON document, with its path (e.g. "spec.containers[0].image")
+// and its key. The key of the value of an environment variable is the name of the variable.
+func walkJSON(node interface{}, path string, key string, handleValue func(path string, key string, value string)) {
+	switch typedNode := node.(type) {
+	case string:
+		handleValue(path, key, typedNode)
+	case []interface{}:
+		for i, item := range typedNode {
+			walkJSON(item, fmt.Sprintf("%s[%d]", path, i), key, handleValue)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(typedNode))
+		for childKey := range typedNode {
+			keys = append(keys, childKey)
+		}
+		sort.Strings(keys)
+		name, hasName := typedNode["name"].(string)
+		for _, childKey := range keys {
+			childPath := childKey
+			if path != "" {
+				childPath = path + "." + childKey
+			}
+			valueKey := childKey
+			if hasName && childKey == "value" {
+				valueKey = name
+			}
+			walkJSON(typedNode[childKey], childPath, valueKey, handleValue)
+		}
+	}
+}
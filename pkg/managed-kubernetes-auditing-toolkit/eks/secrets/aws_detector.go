@@ -0,0 +1,125 @@
+package secrets
+
+import (
+	"strings"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+)
+
+const (
+	TokenTypeAwsAccessKeyID     = "AWS access key ID"
+	TokenTypeAwsSecretAccessKey = "AWS secret access key"
+	TokenTypeAwsSessionToken    = "AWS session token"
+)
+
+// AwsCredentialsDetector finds AWS credentials that don't need a session token, i.e. an access key ID along with a
+// secret access key
+type AwsCredentialsDetector struct{}
+
+// AwsTemporaryCredentialsDetector finds temporary AWS credentials issued by STS, i.e. an ASIA access key ID along with
+// a secret access key and a session token. A live session hardcoded in a resource usually means that a sidecar or a
+// job vending credentials leaked its output.
+type AwsTemporaryCredentialsDetector struct{}
+
+func init() {
+	RegisterDetector(&AwsCredentialsDetector{})
+	RegisterDetector(&AwsTemporaryCredentialsDetector{})
+}
+
+func (m *AwsCredentialsDetector) Name() string {
+	return "aws-credentials"
+}
+
+func (m *AwsCredentialsDetector) Title() string {
+	return "Hardcoded AWS credentials"
+}
+
+func (m *AwsCredentialsDetector) Severity() findings.Severity {
+	return findings.SeverityHigh
+}
+
+func (m *AwsCredentialsDetector) Remediation() string {
+	return "Revoke the credentials, and use IAM Roles for Service Accounts or Pod Identity to give pods temporary AWS credentials instead"
+}
+
+func (m *AwsCredentialsDetector) Tokens() []TokenType {
+	return []TokenType{
+		{Name: TokenTypeAwsAccessKeyID, Sensitive: false},
+		{Name: TokenTypeAwsSecretAccessKey, Sensitive: true},
+	}
+}
+
+func (m *AwsCredentialsDetector) FindTokens(key string, value string) []*Token {
+	var tokens []*Token
+	for _, accessKey := range matchAll(AwsAccessKeyPattern, value) {
+		if !strings.HasPrefix(accessKey, awsTemporaryAccessKeyPrefix) {
+			tokens = append(tokens, &Token{Type: TokenTypeAwsAccessKeyID, Value: accessKey})
+		}
+	}
+	return append(tokens, findAwsSecretKeys(value)...)
+}
+
+func (m *AwsCredentialsDetector) Validate(tokens map[string]string) bool {
+	return tokens[TokenTypeAwsAccessKeyID] != tokens[TokenTypeAwsSecretAccessKey]
+}
+
+func (m *AwsCredentialsDetector) KeyType(tokens map[string]string) string {
+	return AwsKeyType(tokens[TokenTypeAwsAccessKeyID])
+}
+
+func (m *AwsTemporaryCredentialsDetector) Name() string {
+	return "aws-temporary-credentials"
+}
+
+func (m *AwsTemporaryCredentialsDetector) Title() string {
+	return "Hardcoded temporary AWS credentials"
+}
+
+func (m *AwsTemporaryCredentialsDetector) Severity() findings.Severity {
+	return findings.SeverityHigh
+}
+
+func (m *AwsTemporaryCredentialsDetector) Remediation() string {
+	return "Find out which workload wrote the credentials to the resource and stop it, revoke the sessions of the role the credentials belong to, and use IAM Roles for Service Accounts or Pod Identity instead"
+}
+
+func (m *AwsTemporaryCredentialsDetector) Tokens() []TokenType {
+	return []TokenType{
+		{Name: TokenTypeAwsAccessKeyID, Sensitive: false},
+		{Name: TokenTypeAwsSecretAccessKey, Sensitive: true},
+		{Name: TokenTypeAwsSessionToken, Sensitive: true},
+	}
+}
+
+func (m *AwsTemporaryCredentialsDetector) FindTokens(key string, value string) []*Token {
+	var tokens []*Token
+	for _, accessKey := range matchAll(AwsAccessKeyPattern, value) {
+		if strings.HasPrefix(accessKey, awsTemporaryAccessKeyPrefix) {
+			tokens = append(tokens, &Token{Type: TokenTypeAwsAccessKeyID, Value: accessKey})
+		}
+	}
+	tokens = append(tokens, findAwsSecretKeys(value)...)
+	for _, sessionToken := range matchAll(AwsSessionTokenPattern, value) {
+		tokens = append(tokens, &Token{Type: TokenTypeAwsSessionToken, Value: sessionToken})
+	}
+	return tokens
+}
+
+func (m *AwsTemporaryCredentialsDetector) Validate(tokens map[string]string) bool {
+	return tokens[TokenTypeAwsAccessKeyID] != tokens[TokenTypeAwsSecretAccessKey]
+}
+
+func (m *AwsTemporaryCredentialsDetector) KeyType(tokens map[string]string) string {
+	return AwsKeyTypeTemporary
+}
+
+func findAwsSecretKeys(value string) []*Token {
+	if HashPattern.MatchString(value) {
+		return nil
+	}
+	var tokens []*Token
+	for _, secretKey := range matchAll(AwsSecretKeyPattern, value) {
+		tokens = append(tokens, &Token{Type: TokenTypeAwsSecretAccessKey, Value: secretKey})
+	}
+	return tokens
+}
@@ -0,0 +1,422 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/internal/aws/iam_evaluation"
+)
+
+const (
+	AwsKeyStatusLive    = "live"
+	AwsKeyStatusInvalid = "invalid" // the key doesn't exist, was deleted or deactivated, or the session expired
+	AwsKeyStatusUnknown = "unknown" // the key couldn't be validated, e.g. because STS wasn't reachable
+)
+
+// Regions of the STS endpoints that keys are validated against by default, one per AWS partition (aws, aws-cn and
+// aws-us-gov), as keys are only valid in the partition they were created in
+var defaultStsRegions = []string{"us-east-1", "cn-north-1", "us-gov-west-1"}
+
+// Error codes of STS meaning that credentials can't be used
+var invalidAwsKeyErrorCodes = map[string]bool{
+	"InvalidClientTokenId":  true,
+	"SignatureDoesNotMatch": true,
+	"ExpiredToken":          true,
+}
+
+// Actions we check whether the policies of the IAM user a key belongs to allow. They give access to sensitive data,
+// or let attackers escalate their privileges or persist in the account.
+var sensitiveAwsActions = []string{
+	"iam:CreateUser",
+	"iam:CreateAccessKey",
+	"iam:AttachUserPolicy",
+	"iam:PutUserPolicy",
+	"iam:PassRole",
+	"sts:AssumeRole",
+	"ec2:RunInstances",
+	"lambda:UpdateFunctionCode",
+	"s3:GetObject",
+	"s3:PutObject",
+	"secretsmanager:GetSecretValue",
+	"ssm:GetParameter",
+	"kms:Decrypt",
+	"eks:DescribeCluster",
+}
+
+// AwsKeyValidation is the result of the validation of AWS credentials
+type AwsKeyValidation struct {
+	Status       string `json:"status"` // live, invalid or unknown
+	PrincipalArn string `json:"principalArn,omitempty"`
+	AccountID    string `json:"accountId,omitempty"`
+	// Only for keys of IAM users of the account MKAT runs in
+	UserName        string     `json:"userName,omitempty"`
+	CreateDate      *time.Time `json:"createDate,omitempty"`
+	LastUsedDate    *time.Time `json:"lastUsedDate,omitempty"`
+	LastUsedService string     `json:"lastUsedService,omitempty"`
+	LastUsedRegion  string     `json:"lastUsedRegion,omitempty"`
+	// Sensitive actions that the identity policies of the user allow, regardless of resources. Statements with
+	// conditions are skipped, whether they allow or deny actions.
+	AllowedActions []string `json:"allowedActions,omitempty"`
+	// Number of policy statements with conditions that were skipped when determining AllowedActions
+	SkippedConditionalStatements int `json:"skippedConditionalStatements,omitempty"`
+	// Why the key couldn't be validated, or why information about its user couldn't be retrieved
+	Error string `json:"error,omitempty"`
+}
+
+// String summarizes the validation, e.g. "live (arn:aws:iam::111122223333:user/ci, created 2023-01-02)"
+func (m *AwsKeyValidation) String() string {
+	var details []string
+	if m.PrincipalArn != "" {
+		details = append(details, m.PrincipalArn)
+	}
+	if m.CreateDate != nil {
+		details = append(details, "created "+m.CreateDate.Format("2006-01-02"))
+	}
+	if m.LastUsedDate != nil {
+		details = append(details, "last used "+m.LastUsedDate.Format("2006-01-02"))
+	}
+	if len(m.AllowedActions) > 0 {
+		details = append(details, "allows "+strings.Join(m.AllowedActions, ", "))
+	}
+	if m.SkippedConditionalStatements > 0 {
+		details = append(details, fmt.Sprintf("%d policy statements with conditions skipped", m.SkippedConditionalStatements))
+	}
+	if len(details) == 0 {
+		return m.Status
+	}
+	return m.Status + " (" + strings.Join(details, ", ") + ")"
+}
+
+// description describes the validation in findings
+func (m *AwsKeyValidation) description() string {
+	switch m.Status {
+	case AwsKeyStatusLive:
+		return "The credentials are " + m.String()
+	case AwsKeyStatusInvalid:
+		return "The credentials are invalid"
+	default:
+		return "The credentials could not be validated: " + m.Error
+	}
+}
+
+// AwsKeyValidator checks if AWS credentials found in the cluster are live, by calling sts:GetCallerIdentity with
+// them. When they belong to an IAM user of the account MKAT runs in, it also retrieves the age and last use of the
+// key, and what the policies of the user allow.
+type AwsKeyValidator struct {
+	// AWSConfig is the configuration MKAT runs with, used to retrieve information about IAM users. Optional.
+	AWSConfig *aws.Config
+	// Optional, e.g. http://localhost:4566 to use LocalStack. Defaults to the endpoints of STS and IAM.
+	STSEndpoint string
+	IAMEndpoint string
+	// Regions whose STS endpoints keys are validated against, in order, e.g. cn-north-1 for keys of the China
+	// partition. Defaults to a region of each partition, or to us-east-1 when STSEndpoint is set.
+	STSRegions []string
+
+	httpClient aws.HTTPClient // client of the AWS APIs, overridden in tests
+
+	accountID       *string                           // account MKAT runs in, empty if it couldn't be determined
+	validations     map[string]*AwsKeyValidation      // by access key ID
+	managedPolicies map[string]*iam_evaluation.Policy // managed policies, by ARN
+}
+
+// ValidateSecrets validates the AWS credentials of secrets, once per access key
+func (m *AwsKeyValidator) ValidateSecrets(ctx context.Context, secrets []*SecretInfo) {
+	if m.validations == nil {
+		m.validations = map[string]*AwsKeyValidation{}
+		m.managedPolicies = map[string]*iam_evaluation.Policy{}
+	}
+	for _, secret := range secrets {
+		accessKeyID, secretAccessKey := secret.credential[TokenTypeAwsAccessKeyID], secret.credential[TokenTypeAwsSecretAccessKey]
+		if accessKeyID == "" || secretAccessKey == "" {
+			continue
+		}
+		validation, found := m.validations[accessKeyID]
+		if !found {
+			log.Printf("Validating the AWS access key %s...", accessKeyID)
+			validation = m.validateKey(ctx, accessKeyID, secretAccessKey, secret.credential[TokenTypeAwsSessionToken])
+			m.validations[accessKeyID] = validation
+		}
+		secret.Validation = validation
+	}
+}
+
+func (m *AwsKeyValidator) validateKey(ctx context.Context, accessKeyID string, secretAccessKey string, sessionToken string) *AwsKeyValidation {
+	regions := m.stsRegions()
+	var identity *sts.GetCallerIdentityOutput
+	var errs []string
+	rejected := 0
+	for _, region := range regions {
+		keyConfig := aws.Config{Region: region, Credentials: credentials.NewStaticCredentialsProvider(accessKeyID, secretAccessKey, sessionToken)}
+		var err error
+		if identity, err = sts.NewFromConfig(keyConfig, m.stsOptions).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{}); err == nil {
+			break
+		}
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && invalidAwsKeyErrorCodes[apiErr.ErrorCode()] {
+			rejected++
+		}
+		errs = append(errs, fmt.Sprintf("unable to call sts:GetCallerIdentity in %s: %v", region, err))
+	}
+	if len(errs) == len(regions) {
+		// Keys are only invalid if all partitions rejected them, rather than if some STS endpoints weren't reachable
+		if rejected == len(regions) {
+			return &AwsKeyValidation{Status: AwsKeyStatusInvalid}
+		}
+		return &AwsKeyValidation{Status: AwsKeyStatusUnknown, Error: strings.Join(errs, ", ")}
+	}
+	validation := &AwsKeyValidation{Status: AwsKeyStatusLive, PrincipalArn: aws.ToString(identity.Arn), AccountID: aws.ToString(identity.Account)}
+	// Temporary credentials belong to a role session, not to an IAM user
+	if sessionToken != "" || m.AWSConfig == nil || validation.AccountID != m.scannerAccountID(ctx) {
+		return validation
+	}
+	if err := m.retrieveKeyInformation(ctx, accessKeyID, validation); err != nil {
+		validation.Error = err.Error()
+	}
+	return validation
+}
+
+// scannerAccountID returns the account MKAT runs in, or an empty string if it can't be determined
+func (m *AwsKeyValidator) scannerAccountID(ctx context.Context) string {
+	if m.accountID == nil {
+		identity, err := sts.NewFromConfig(*m.AWSConfig, m.stsOptions).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+		if err != nil {
+			log.Printf("[WARN] Unable to determine the current AWS account, information about the IAM users of valid keys won't be retrieved: %v", err)
+			m.accountID = aws.String("")
+		} else {
+			m.accountID = identity.Account
+		}
+	}
+	return *m.accountID
+}
+
+// retrieveKeyInformation retrieves the age and last use of a key, and what its user is allowed to do
+func (m *AwsKeyValidator) retrieveKeyInformation(ctx context.Context, accessKeyID string, validation *AwsKeyValidation) error {
+	client := iam.NewFromConfig(*m.AWSConfig, m.iamOptions)
+	lastUsed, err := client.GetAccessKeyLastUsed(ctx, &iam.GetAccessKeyLastUsedInput{AccessKeyId: &accessKeyID})
+	if err != nil {
+		return fmt.Errorf("unable to retrieve the last use of the key: %v", err)
+	}
+	if lastUsed.AccessKeyLastUsed != nil {
+		validation.LastUsedDate = lastUsed.AccessKeyLastUsed.LastUsedDate
+		// Keys that were never used have N/A as service and region
+		if service := aws.ToString(lastUsed.AccessKeyLastUsed.ServiceName); service != "N/A" {
+			validation.LastUsedService = service
+		}
+		if region := aws.ToString(lastUsed.AccessKeyLastUsed.Region); region != "N/A" {
+			validation.LastUsedRegion = region
+		}
+	}
+
+	validation.UserName = aws.ToString(lastUsed.UserName)
+	// Keys of the root user have no policies
+	if parsedArn, err := arn.Parse(validation.PrincipalArn); err != nil || !strings.HasPrefix(parsedArn.Resource, "user/") {
+		return nil
+	}
+	accessKeys := iam.NewListAccessKeysPaginator(client, &iam.ListAccessKeysInput{UserName: &validation.UserName})
+	for accessKeys.HasMorePages() {
+		page, err := accessKeys.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to list the access keys of %s: %v", validation.UserName, err)
+		}
+		for _, accessKey := range page.AccessKeyMetadata {
+			if aws.ToString(accessKey.AccessKeyId) == accessKeyID {
+				validation.CreateDate = accessKey.CreateDate
+			}
+		}
+	}
+
+	policy, err := m.userPolicy(ctx, client, validation.UserName)
+	if err != nil {
+		return err
+	}
+	// We don't know the context of the requests an attacker would make, so conditions can't be evaluated
+	unconditionalPolicy := &iam_evaluation.Policy{}
+	for _, statement := range policy.Statements {
+		if len(statement.Conditions) > 0 {
+			validation.SkippedConditionalStatements++
+			continue
+		}
+		unconditionalPolicy.Statements = append(unconditionalPolicy.Statements, statement)
+	}
+	principal := &iam_evaluation.Principal{Type: iam_evaluation.PrincipalTypeAWS, ID: validation.PrincipalArn}
+	for _, action := range sensitiveAwsActions {
+		if unconditionalPolicy.Authorize(&iam_evaluation.AuthorizationContext{Action: action, Principal: principal}).Decision == iam_evaluation.AuthorizationDecisionAllow {
+			validation.AllowedActions = append(validation.AllowedActions, action)
+		}
+	}
+	return nil
+}
+
+// userPolicy returns the statements of all the identity policies of a user, including the ones of its groups.
+// Permissions boundaries and service control policies aren't taken into account.
+func (m *AwsKeyValidator) userPolicy(ctx context.Context, client *iam.Client, userName string) (*iam_evaluation.Policy, error) {
+	var documents []string
+	var managedPolicyArns []string
+
+	inlinePolicies := iam.NewListUserPoliciesPaginator(client, &iam.ListUserPoliciesInput{UserName: &userName})
+	for inlinePolicies.HasMorePages() {
+		page, err := inlinePolicies.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list the inline policies of %s: %v", userName, err)
+		}
+		for _, policyName := range page.PolicyNames {
+			policy, err := client.GetUserPolicy(ctx, &iam.GetUserPolicyInput{UserName: &userName, PolicyName: aws.String(policyName)})
+			if err != nil {
+				return nil, fmt.Errorf("unable to retrieve the inline policy %s of %s: %v", policyName, userName, err)
+			}
+			documents = append(documents, aws.ToString(policy.PolicyDocument))
+		}
+	}
+	attachedPolicies := iam.NewListAttachedUserPoliciesPaginator(client, &iam.ListAttachedUserPoliciesInput{UserName: &userName})
+	for attachedPolicies.HasMorePages() {
+		page, err := attachedPolicies.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list the managed policies of %s: %v", userName, err)
+		}
+		for _, policy := range page.AttachedPolicies {
+			managedPolicyArns = append(managedPolicyArns, aws.ToString(policy.PolicyArn))
+		}
+	}
+
+	groups := iam.NewListGroupsForUserPaginator(client, &iam.ListGroupsForUserInput{UserName: &userName})
+	for groups.HasMorePages() {
+		page, err := groups.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list the groups of %s: %v", userName, err)
+		}
+		for _, group := range page.Groups {
+			groupDocuments, groupPolicyArns, err := groupPolicies(ctx, client, aws.ToString(group.GroupName))
+			if err != nil {
+				return nil, err
+			}
+			documents = append(documents, groupDocuments...)
+			managedPolicyArns = append(managedPolicyArns, groupPolicyArns...)
+		}
+	}
+
+	policy := &iam_evaluation.Policy{}
+	for _, document := range documents {
+		inlinePolicy, err := parsePolicyDocument(document)
+		if err != nil {
+			return nil, err
+		}
+		policy.Statements = append(policy.Statements, inlinePolicy.Statements...)
+	}
+	for _, policyArn := range managedPolicyArns {
+		managedPolicy, err := m.managedPolicy(ctx, client, policyArn)
+		if err != nil {
+			return nil, err
+		}
+		policy.Statements = append(policy.Statements, managedPolicy.Statements...)
+	}
+	return policy, nil
+}
+
+// groupPolicies returns the documents of the inline policies of a group, and the ARNs of its managed policies
+func groupPolicies(ctx context.Context, client *iam.Client, groupName string) ([]string, []string, error) {
+	var documents []string
+	var managedPolicyArns []string
+	inlinePolicies := iam.NewListGroupPoliciesPaginator(client, &iam.ListGroupPoliciesInput{GroupName: &groupName})
+	for inlinePolicies.HasMorePages() {
+		page, err := inlinePolicies.NextPage(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to list the inline policies of the group %s: %v", groupName, err)
+		}
+		for _, policyName := range page.PolicyNames {
+			policy, err := client.GetGroupPolicy(ctx, &iam.GetGroupPolicyInput{GroupName: &groupName, PolicyName: aws.String(policyName)})
+			if err != nil {
+				return nil, nil, fmt.Errorf("unable to retrieve the inline policy %s of the group %s: %v", policyName, groupName, err)
+			}
+			documents = append(documents, aws.ToString(policy.PolicyDocument))
+		}
+	}
+	attachedPolicies := iam.NewListAttachedGroupPoliciesPaginator(client, &iam.ListAttachedGroupPoliciesInput{GroupName: &groupName})
+	for attachedPolicies.HasMorePages() {
+		page, err := attachedPolicies.NextPage(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to list the managed policies of the group %s: %v", groupName, err)
+		}
+		for _, policy := range page.AttachedPolicies {
+			managedPolicyArns = append(managedPolicyArns, aws.ToString(policy.PolicyArn))
+		}
+	}
+	return documents, managedPolicyArns, nil
+}
+
+// managedPolicy returns the default version of a managed policy, retrieving each policy only once
+func (m *AwsKeyValidator) managedPolicy(ctx context.Context, client *iam.Client, policyArn string) (*iam_evaluation.Policy, error) {
+	if policy, found := m.managedPolicies[policyArn]; found {
+		return policy, nil
+	}
+	policy, err := client.GetPolicy(ctx, &iam.GetPolicyInput{PolicyArn: &policyArn})
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve the managed policy %s: %v", policyArn, err)
+	}
+	version, err := client.GetPolicyVersion(ctx, &iam.GetPolicyVersionInput{PolicyArn: &policyArn, VersionId: policy.Policy.DefaultVersionId})
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve the default version of the managed policy %s: %v", policyArn, err)
+	}
+	parsedPolicy, err := parsePolicyDocument(aws.ToString(version.PolicyVersion.Document))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the managed policy %s: %v", policyArn, err)
+	}
+	m.managedPolicies[policyArn] = parsedPolicy
+	return parsedPolicy, nil
+}
+
+// parsePolicyDocument parses a policy document returned by the IAM API, which URL-encodes them
+func parsePolicyDocument(document string) (*iam_evaluation.Policy, error) {
+	decodedDocument, err := url.QueryUnescape(document)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode policy document: %v", err)
+	}
+	return iam_evaluation.ParseIdentityPolicy(decodedDocument)
+}
+
+// stsRegions returns the regions to validate keys in. A custom endpoint is only called once per key, since it's the
+// same in all regions.
+func (m *AwsKeyValidator) stsRegions() []string {
+	if len(m.STSRegions) > 0 {
+		return m.STSRegions
+	}
+	if m.STSEndpoint != "" {
+		return defaultStsRegions[:1]
+	}
+	return defaultStsRegions
+}
+
+func (m *AwsKeyValidator) stsOptions(options *sts.Options) {
+	if options.Region == "" {
+		options.Region = "us-east-1"
+	}
+	if m.STSEndpoint != "" {
+		options.BaseEndpoint = aws.String(m.STSEndpoint)
+	}
+	if m.httpClient != nil {
+		options.HTTPClient = m.httpClient
+	}
+}
+
+func (m *AwsKeyValidator) iamOptions(options *iam.Options) {
+	// IAM is a global service, whose endpoint depends on the partition of the region MKAT runs in
+	if options.Region == "" {
+		options.Region = "us-east-1"
+	}
+	if m.IAMEndpoint != "" {
+		options.BaseEndpoint = aws.String(m.IAMEndpoint)
+	}
+	if m.httpClient != nil {
+		options.HTTPClient = m.httpClient
+	}
+}
@@ -2,54 +2,90 @@ package secrets
 
 import "regexp"
 
+// AwsSecretScanningResult holds the first AWS access key ID and secret access key found in a value
+//
+// Deprecated: use the tokens returned by the FindTokens method of detectors instead.
 type AwsSecretScanningResult struct {
 	AccessKey string
 	SecretKey string
 }
 
 var (
-	AwsAccessKeyPattern = regexp.MustCompile("^AKIA[0-9A-Z]{16}$")
+	AwsAccessKeyPattern = regexp.MustCompile("^(AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}$")
 	AwsSecretKeyPattern = regexp.MustCompile("^[A-Za-z0-9/+=]{40}$")
-	HashPattern         = regexp.MustCompile("^[0-9a-fA-F]{40}$")
+	// Session tokens are base64-encoded, and usually several hundreds of characters long
+	AwsSessionTokenPattern = regexp.MustCompile("^[A-Za-z0-9/+=]{100,}$")
+	HashPattern            = regexp.MustCompile("^[0-9a-fA-F]{40}$")
 )
 
-func FindAwsCredentialsInUnstructuredString(input string) *AwsSecretScanningResult {
-	var result = &AwsSecretScanningResult{}
+// Types of AWS keys, as reported in findings
+const (
+	AwsKeyTypeLongTerm          = "long-term access key"
+	AwsKeyTypeTemporary         = "temporary access key"
+	AwsKeyTypeBearerToken       = "STS service bearer token"
+	AwsKeyTypeContextSpecific   = "context-specific credentials"
+	awsTemporaryAccessKeyPrefix = "ASIA"
+)
+
+// awsKeyTypes maps the prefix of AWS access key IDs to the type of key they identify
+var awsKeyTypes = map[string]string{
+	"AKIA":                      AwsKeyTypeLongTerm,
+	awsTemporaryAccessKeyPrefix: AwsKeyTypeTemporary,
+	"ABIA":                      AwsKeyTypeBearerToken,
+	"ACCA":                      AwsKeyTypeContextSpecific,
+}
 
-	accessKey := matchAwsAccessKey(input)
-	if accessKey != nil {
-		result.AccessKey = *accessKey
+// AwsKeyType returns the type of key an AWS access key ID identifies, based on its prefix
+func AwsKeyType(accessKeyID string) string {
+	if len(accessKeyID) < 4 {
+		return ""
 	}
+	return awsKeyTypes[accessKeyID[:4]]
+}
 
-	secretKey := matchAwsSecretKey(input)
-	if secretKey != nil {
-		result.SecretKey = *secretKey
+// FindAwsCredentialsInUnstructuredString returns the first AWS access key ID and secret access key found in a value,
+// with the registered AWS detectors. Fields are empty when nothing is found.
+//
+// Deprecated: use the FindTokens method of the aws-credentials and aws-temporary-credentials detectors instead, or a
+// SecretsDetector to pair tokens into credentials.
+func FindAwsCredentialsInUnstructuredString(input string) *AwsSecretScanningResult {
+	result := &AwsSecretScanningResult{}
+	for _, name := range []string{"aws-credentials", "aws-temporary-credentials"} {
+		detector := DetectorByName(name)
+		if detector == nil {
+			continue
+		}
+		for _, token := range detector.FindTokens("", input) {
+			if token.Type == TokenTypeAwsAccessKeyID && result.AccessKey == "" {
+				result.AccessKey = token.Value
+			} else if token.Type == TokenTypeAwsSecretAccessKey && result.SecretKey == "" {
+				result.SecretKey = token.Value
+			}
+		}
 	}
 	return result
 }
 
-func match(regex *regexp.Regexp, input string) *string {
-	tokens := regexp.MustCompile(`(?s)\s*[^a-zA-Z0-9./+)_-]+\s*`).Split(input, -1)
-	for _, token := range tokens {
+// Separators between the tokens of unstructured data, such as JSON or YAML documents
+var tokenSeparatorPattern = regexp.MustCompile(`(?s)\s*[^a-zA-Z0-9./+)_-]+\s*`)
+
+// matchAll returns the tokens of the input that match a regex
+func matchAll(regex *regexp.Regexp, input string) []string {
+	return matchAllSeparatedBy(tokenSeparatorPattern, regex, input)
+}
+
+// matchAllSeparatedBy returns the tokens of the input that match a regex, for credentials that contain characters
+// that usually separate tokens
+func matchAllSeparatedBy(separator *regexp.Regexp, regex *regexp.Regexp, input string) []string {
+	var matches []string
+	for _, token := range separator.Split(input, -1) {
 		if token == "" {
 			continue
 		}
 		test := regex.FindStringIndex(token)
 		if test != nil {
-			matchedValue := token[test[0]:test[1]]
-			return &matchedValue
+			matches = append(matches, token[test[0]:test[1]])
 		}
 	}
-	return nil
-}
-func matchAwsAccessKey(value string) *string {
-	return match(AwsAccessKeyPattern, value)
-}
-
-func matchAwsSecretKey(value string) *string {
-	result := match(AwsSecretKeyPattern, value)
-	if result != nil && !HashPattern.MatchString(value) {
-		return result
-	}
-	return nil
+	return matches
 }
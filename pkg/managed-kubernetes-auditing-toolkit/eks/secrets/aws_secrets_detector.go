@@ -3,94 +3,165 @@ package secrets
 import (
 	"context"
 	"fmt"
-	"github.com/aws/aws-sdk-go-v2/aws"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/datasource"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/filters"
+	v1 "k8s.io/api/core/v1"
 	"log"
 	"strconv"
 )
 
 type SecretsDetector struct {
-	AwsClient *aws.Config
-	K8sClient *kubernetes.Clientset
-	Namespace string // empty for all namespaces
+	DataSource datasource.KubernetesDataSource
+	Filters    *filters.Filters // optional, only namespaces and the pod label selector apply
+	Detectors  []Detector       // types of credentials to look for, the registered detectors if empty
+
+	namespaces []string                   // resolved from the filters, nil for all namespaces
+	owners     map[string]scannedResource // workloads managing the ReplicaSets and Jobs scanned so far
+	configMaps map[string]*v1.ConfigMap   // ConfigMaps referenced by containers, nil if they don't exist
+	secrets    map[string]*v1.Secret      // Secrets referenced by containers, nil if they don't exist
+	incomplete bool                       // set when resources couldn't be listed or retrieved
 }
 
 type SecretInfo struct {
-	Namespace string
-	Type      string
-	Name      string
-	Value     string
+	Namespace    string
+	Type         string
+	Name         string // name of the resource, and where the secret is in it
+	Value        string
+	ResourceName string
+	Location     string // where the secret is in the resource, e.g. "key credentials"
+	Detector     string // name of the detector that found the secret
+	TokenType    string // which part of a credential the secret is, e.g. "AWS access key ID"
+	KeyType      string // set by detectors that find several types of keys, e.g. "temporary access key"
+	// DecodingChain lists how the secret was decoded from the value at its location, e.g. ["helm release", "values.yaml"]
+	DecodingChain []string
+	// Source is the ConfigMap or Secret that a pod takes the secret from, e.g. "Secret aws-credentials"
+	Source string
+	// Validation is set by AwsKeyValidator for AWS credentials
+	Validation *AwsKeyValidation
+	// File is the manifest the resource is defined in, when scanning local files rather than a cluster
+	File string
+
+	credential map[string]string // values of all the tokens of the credential, by token type
 }
 
 func (m *SecretsDetector) FindSecrets() ([]*SecretInfo, error) {
 	var secrets []*SecretInfo
+	var err error
+	if m.namespaces, err = m.Filters.ResolveNamespaces(context.Background(), m.DataSource); err != nil {
+		return nil, err
+	}
+	if len(m.Detectors) == 0 {
+		m.Detectors = RegisteredDetectors()
+	}
+	m.owners = map[string]scannedResource{}
+	m.configMaps = map[string]*v1.ConfigMap{}
+	m.secrets = map[string]*v1.Secret{}
+	m.incomplete = false
 
-	log.Println("Searching for AWS secrets in ConfigMaps...")
+	log.Println("Searching for secrets in ConfigMaps...")
 	configMapCredentials, err := m.findCredentialsInConfigMaps()
 	if err == nil {
 		secrets = append(secrets, configMapCredentials...)
 	} else {
+		m.incomplete = true
 		log.Println("[WARN] Unable to access ConfigMaps: " + err.Error())
 	}
 
-	log.Println("Searching for AWS secrets in Secrets...")
+	log.Println("Searching for secrets in Secrets...")
 	secretCredentials, err := m.findCredentialsInSecrets()
 	if err == nil {
 		secrets = append(secrets, secretCredentials...)
 	} else {
+		m.incomplete = true
 		log.Println("[WARN] Unable to access Secrets: " + err.Error())
 	}
 
-	log.Println("Searching for AWS secrets in Pod definitions...")
+	// Workloads are scanned before pods, so that secrets found in both are attributed to the workload
+	log.Println("Searching for secrets in workload pod templates...")
+	workloadCredentials, err := m.findCredentialsInWorkloads()
+	secrets = append(secrets, workloadCredentials...)
+	if err != nil {
+		m.incomplete = true
+		log.Println("[WARN] Unable to access some workloads: " + err.Error())
+	}
+
+	log.Println("Searching for secrets in Pod definitions...")
 	podCredentials, err := m.findCredentialsInPodDefinitions()
 	if err == nil {
 		secrets = append(secrets, podCredentials...)
 	} else {
+		m.incomplete = true
 		log.Println("[WARN] Unable to access Pod definitions: " + err.Error())
 	}
 
-	return secrets, nil
+	return deduplicateSecrets(secrets), nil
+}
+
+// Complete determines if the last call to FindSecrets scanned all the resources matching the filters. It's false when
+// resources couldn't be listed or retrieved, in which case secrets may be missing from the results.
+func (m *SecretsDetector) Complete() bool {
+	if dataSource, ok := m.DataSource.(interface{ SkippedNamespaces() bool }); ok && dataSource.SkippedNamespaces() {
+		return false
+	}
+	return !m.incomplete
 }
 
+// Resources are scanned page by page, so that we never hold all of them in memory
+
 func (m *SecretsDetector) findCredentialsInConfigMaps() ([]*SecretInfo, error) {
-	configMaps, err := m.K8sClient.CoreV1().ConfigMaps(m.Namespace).List(context.Background(), metav1.ListOptions{})
+	var secrets []*SecretInfo
+	numConfigMaps := 0
+	err := filters.List(context.Background(), m.Filters, m.namespaces, "", m.DataSource.ListConfigMaps, func(configMaps []v1.ConfigMap) error {
+		numConfigMaps += len(configMaps)
+		for i := range configMaps {
+			secrets = append(secrets, findSecretsInSingleConfigMap(&configMaps[i], m.Detectors)...)
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("unable to list ConfigMaps: %v", err)
 	}
-	var secrets []*SecretInfo
-	log.Println("Analyzing " + strconv.Itoa(len(configMaps.Items)) + " ConfigMaps...")
-	for _, configMap := range configMaps.Items {
-		configMapSecrets := findSecretsInSingleConfigMap(&configMap)
-		secrets = append(secrets, configMapSecrets...)
-	}
+	log.Println("Analyzed " + strconv.Itoa(numConfigMaps) + " ConfigMaps")
 	return secrets, nil
 }
 
 func (m *SecretsDetector) findCredentialsInSecrets() ([]*SecretInfo, error) {
-	k8sSecrets, err := m.K8sClient.CoreV1().Secrets(m.Namespace).List(context.Background(), metav1.ListOptions{})
+	var secrets []*SecretInfo
+	numSecrets := 0
+	err := filters.List(context.Background(), m.Filters, m.namespaces, "", m.DataSource.ListSecrets, func(k8sSecrets []v1.Secret) error {
+		numSecrets += len(k8sSecrets)
+		for i := range k8sSecrets {
+			secrets = append(secrets, findSecretsInSingleSecret(&k8sSecrets[i], m.Detectors)...)
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("unable to list Secrets: %v", err)
 	}
-	var secrets []*SecretInfo
-	log.Println("Analyzing " + strconv.Itoa(len(k8sSecrets.Items)) + " Secrets...")
-	for _, k8sSecret := range k8sSecrets.Items {
-		configMapSecrets := findSecretsInSingleSecret(&k8sSecret)
-		secrets = append(secrets, configMapSecrets...)
-	}
+	log.Println("Analyzed " + strconv.Itoa(numSecrets) + " Secrets")
 	return secrets, nil
 }
 
 func (m *SecretsDetector) findCredentialsInPodDefinitions() ([]*SecretInfo, error) {
-	pods, err := m.K8sClient.CoreV1().Pods(m.Namespace).List(context.Background(), metav1.ListOptions{})
+	var secrets []*SecretInfo
+	numPods := 0
+	err := filters.List(context.Background(), m.Filters, m.namespaces, m.podLabelSelector(), m.DataSource.ListPods, func(pods []v1.Pod) error {
+		numPods += len(pods)
+		for i := range pods {
+			secrets = append(secrets, m.findSecretsInPod(m.ownerOf(&pods[i], "Pod"), &pods[i], &pods[i].Spec)...)
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("unable to list Pods: %v", err)
 	}
-	var secrets []*SecretInfo
-	log.Println("Analyzing " + strconv.Itoa(len(pods.Items)) + " Pod definitions...")
-	for _, pod := range pods.Items {
-		podSecrets := findSecretsInSinglePodDefinition(&pod)
-		secrets = append(secrets, podSecrets...)
-	}
+	log.Println("Analyzed " + strconv.Itoa(numPods) + " Pod definitions")
 	return secrets, nil
 }
+
+func (m *SecretsDetector) podLabelSelector() string {
+	if m.Filters == nil {
+		return ""
+	}
+	return m.Filters.PodLabelSelector
+}
@@ -0,0 +1,66 @@
+package secrets
+
+import (
+	"regexp"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+)
+
+const (
+	TokenTypeAzureClientID     = "Azure client ID"
+	TokenTypeAzureClientSecret = "Azure client secret"
+)
+
+var (
+	AzureClientIDPattern = regexp.MustCompile("^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$")
+	// Client secrets generated by Microsoft Entra ID since 2021, e.g. "abc8Q~..."
+	AzureClientSecretPattern = regexp.MustCompile(`^[a-zA-Z0-9_~.-]{3}[0-9]Q~[a-zA-Z0-9_~.-]{31,34}$`)
+	// Client secrets contain "~", which usually separates tokens
+	azureTokenSeparatorPattern = regexp.MustCompile(`[^a-zA-Z0-9_~.-]+`)
+)
+
+// AzureClientSecretDetector finds credentials of Microsoft Entra ID applications, i.e. a client secret along with the
+// ID of an application
+type AzureClientSecretDetector struct{}
+
+func init() {
+	RegisterDetector(&AzureClientSecretDetector{})
+}
+
+func (m *AzureClientSecretDetector) Name() string {
+	return "azure-client-secret"
+}
+
+func (m *AzureClientSecretDetector) Title() string {
+	return "Hardcoded Azure client secret"
+}
+
+func (m *AzureClientSecretDetector) Severity() findings.Severity {
+	return findings.SeverityHigh
+}
+
+func (m *AzureClientSecretDetector) Remediation() string {
+	return "Delete the client secret of the application, and use workload identity federation to give pods access to Azure instead"
+}
+
+func (m *AzureClientSecretDetector) Tokens() []TokenType {
+	return []TokenType{
+		{Name: TokenTypeAzureClientID, Sensitive: false},
+		{Name: TokenTypeAzureClientSecret, Sensitive: true},
+	}
+}
+
+func (m *AzureClientSecretDetector) FindTokens(key string, value string) []*Token {
+	var tokens []*Token
+	for _, clientID := range matchAll(AzureClientIDPattern, value) {
+		tokens = append(tokens, &Token{Type: TokenTypeAzureClientID, Value: clientID})
+	}
+	for _, clientSecret := range matchAllSeparatedBy(azureTokenSeparatorPattern, AzureClientSecretPattern, value) {
+		tokens = append(tokens, &Token{Type: TokenTypeAzureClientSecret, Value: clientSecret})
+	}
+	return tokens
+}
+
+func (m *AzureClientSecretDetector) Validate(tokens map[string]string) bool {
+	return true
+}
@@ -1,38 +1,30 @@
 package secrets
 
 import (
-	"fmt"
+	"sort"
+
 	v1 "k8s.io/api/core/v1"
 )
 
-func findSecretsInSingleConfigMap(configMap *v1.ConfigMap) []*SecretInfo {
-	var secrets []*SecretInfo
-	var accessKeyInfo *SecretInfo
-	var secretKeyInfo *SecretInfo
-	for key, value := range configMap.Data {
-		configMapSecrets := FindAwsCredentialsInUnstructuredString(value)
-		if configMapSecrets.AccessKey != "" {
-			accessKeyInfo = &SecretInfo{
-				Namespace: configMap.Namespace,
-				Name:      fmt.Sprintf("%s (key %s)", configMap.Name, key),
-				Type:      "ConfigMap",
-				Value:     configMapSecrets.AccessKey,
-			}
-		}
-		if configMapSecrets.SecretKey != "" {
-			secretKeyInfo = &SecretInfo{
-				Namespace: configMap.Namespace,
-				Name:      fmt.Sprintf("%s (key %s)", configMap.Name, key),
-				Type:      "ConfigMap",
-				Value:     configMapSecrets.SecretKey,
-			}
-		}
-		if accessKeyInfo != nil && secretKeyInfo != nil {
-			secrets = append(secrets, accessKeyInfo, secretKeyInfo)
-			// start searching for a new set of credentials
-			accessKeyInfo = nil
-			secretKeyInfo = nil
-		}
+func findSecretsInSingleConfigMap(configMap *v1.ConfigMap, detectors []Detector) []*SecretInfo {
+	resource := scannedResource{Type: "ConfigMap", Namespace: configMap.Namespace, Name: configMap.Name}
+	var values []scannedValue
+	for _, key := range sortedKeys(configMap.Data) {
+		values = append(values, scannedValue{Location: "key " + key, Key: key, Value: configMap.Data[key]})
+	}
+	for _, key := range sortedKeys(configMap.BinaryData) {
+		values = append(values, scannedValue{Location: "binary key " + key, Key: key, Value: string(configMap.BinaryData[key])})
+	}
+	secrets := findCredentials(detectors, resource, values)
+	return append(secrets, findSecretsInLastAppliedConfiguration(resource, configMap, detectors)...)
+}
+
+// sortedKeys returns the keys of the data of a ConfigMap or Secret, so that they're scanned in a stable order
+func sortedKeys[T any](data map[string]T) []string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
 	}
-	return secrets
+	sort.Strings(keys)
+	return keys
 }
@@ -104,7 +104,7 @@ secret_key = HP8lBRs8X50F/0nCAXqEPQ95+jlG/0pLdlNui2XF
 
 	for _, scenario := range scenarios {
 		t.Run(scenario.Name, func(t *testing.T) {
-			result := findSecretsInSingleConfigMap(scenario.ConfigMap)
+			result := findSecretsInSingleConfigMap(scenario.ConfigMap, RegisteredDetectors())
 			if scenario.ShouldFindSecret && len(result) == 0 {
 				t.Errorf("expected to find secrets, but found none")
 			}
@@ -0,0 +1,41 @@
+package secrets
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+)
+
+// Connection URLs of databases and message brokers that embed a password
+var DatabaseURLPattern = regexp.MustCompile(`(?i)\b(postgres|postgresql|mysql|mariadb|mongodb|mongodb\+srv|redis|rediss|amqp|amqps|sqlserver|mssql)://[^\s:/?#@'"]+:[^\s/?#@'"]+@[^\s/?#'"]+`)
+
+// Passwords that are placeholders rather than actual credentials
+var databasePasswordPlaceholderPattern = regexp.MustCompile(`(?i)^(password|passwd|pass|pwd|secret|changeme|xxx+|\*+|\.+|<.*>|\$\{.*}|\$\(.*\)|\{\{.*}}|%.*%|\$[A-Z_]+)$`)
+
+func init() {
+	RegisterDetector(&SingleTokenDetector{
+		DetectorName:        "database-url",
+		DetectorTitle:       "Hardcoded database password",
+		DetectorSeverity:    findings.SeverityHigh,
+		DetectorRemediation: "Change the password, and store the connection URL in a Secret or an external secrets manager",
+		TokenType:           TokenType{Name: "database URL with a password", Sensitive: true},
+		Match: func(key string, value string) []string {
+			return DatabaseURLPattern.FindAllString(value, -1)
+		},
+		IsValid: isDatabaseURLWithPassword,
+		GetKeyType: func(token string) string {
+			return strings.ToLower(token[:strings.Index(token, ":")])
+		},
+	})
+}
+
+func isDatabaseURLWithPassword(token string) bool {
+	parsedURL, err := url.Parse(token)
+	if err != nil || parsedURL.User == nil {
+		return false
+	}
+	password, hasPassword := parsedURL.User.Password()
+	return hasPassword && password != "" && !databasePasswordPlaceholderPattern.MatchString(password)
+}
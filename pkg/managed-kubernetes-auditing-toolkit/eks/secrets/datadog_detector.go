@@ -0,0 +1,102 @@
+package secrets
+
+import (
+	"regexp"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+)
+
+const (
+	TokenTypeDatadogAPIKey = "Datadog API key"
+	TokenTypeDatadogAppKey = "Datadog application key"
+)
+
+var (
+	DatadogAPIKeyPattern = regexp.MustCompile("^[0-9a-f]{32}$")
+	DatadogAppKeyPattern = regexp.MustCompile("^[0-9a-f]{40}$")
+	// Hexadecimal strings are common, so keys are only looked for in values that mention Datadog
+	datadogContextPattern = regexp.MustCompile(`(?i)datadog|\bdd[_-]`)
+)
+
+// DatadogAPIKeyDetector finds Datadog API keys, which allow submitting data to an organization
+type DatadogAPIKeyDetector struct{}
+
+// DatadogAppKeyDetector finds Datadog application keys along with an API key, which together allow reading the data of
+// an organization and changing its configuration
+type DatadogAppKeyDetector struct{}
+
+func init() {
+	RegisterDetector(&DatadogAPIKeyDetector{})
+	RegisterDetector(&DatadogAppKeyDetector{})
+}
+
+func (m *DatadogAPIKeyDetector) Name() string {
+	return "datadog-api-key"
+}
+
+func (m *DatadogAPIKeyDetector) Title() string {
+	return "Hardcoded Datadog API key"
+}
+
+func (m *DatadogAPIKeyDetector) Severity() findings.Severity {
+	return findings.SeverityMedium
+}
+
+func (m *DatadogAPIKeyDetector) Remediation() string {
+	return "Revoke the API key, and store it in a Secret or an external secrets manager"
+}
+
+func (m *DatadogAPIKeyDetector) Tokens() []TokenType {
+	return []TokenType{{Name: TokenTypeDatadogAPIKey, Sensitive: true}}
+}
+
+func (m *DatadogAPIKeyDetector) FindTokens(key string, value string) []*Token {
+	return findDatadogKeys(key, value, DatadogAPIKeyPattern, TokenTypeDatadogAPIKey)
+}
+
+func (m *DatadogAPIKeyDetector) Validate(tokens map[string]string) bool {
+	return true
+}
+
+func (m *DatadogAppKeyDetector) Name() string {
+	return "datadog-app-key"
+}
+
+func (m *DatadogAppKeyDetector) Title() string {
+	return "Hardcoded Datadog application key"
+}
+
+func (m *DatadogAppKeyDetector) Severity() findings.Severity {
+	return findings.SeverityHigh
+}
+
+func (m *DatadogAppKeyDetector) Remediation() string {
+	return "Revoke the application key, and store it in a Secret or an external secrets manager"
+}
+
+func (m *DatadogAppKeyDetector) Tokens() []TokenType {
+	return []TokenType{
+		{Name: TokenTypeDatadogAPIKey, Sensitive: true},
+		{Name: TokenTypeDatadogAppKey, Sensitive: true},
+	}
+}
+
+func (m *DatadogAppKeyDetector) FindTokens(key string, value string) []*Token {
+	tokens := findDatadogKeys(key, value, DatadogAPIKeyPattern, TokenTypeDatadogAPIKey)
+	return append(tokens, findDatadogKeys(key, value, DatadogAppKeyPattern, TokenTypeDatadogAppKey)...)
+}
+
+func (m *DatadogAppKeyDetector) Validate(tokens map[string]string) bool {
+	return true
+}
+
+func findDatadogKeys(key string, value string, pattern *regexp.Regexp, tokenType string) []*Token {
+	if !datadogContextPattern.MatchString(key) && !datadogContextPattern.MatchString(value) {
+		return nil
+	}
+	var tokens []*Token
+	for _, match := range matchAll(pattern, value) {
+		tokens = append(tokens, &Token{Type: tokenType, Value: match})
+	}
+	return tokens
+}
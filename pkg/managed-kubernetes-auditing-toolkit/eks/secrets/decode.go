@@ -0,0 +1,252 @@
+package secrets
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// Maximum number of nested encodings we decode, e.g. base64 then gzip then JSON is 3
+	maxDecodingDepth = 5
+	// Maximum size of a decompressed payload, to protect against gzip bombs
+	maxDecompressedSize = 10 * 1024 * 1024
+	// Shorter base64 strings are more likely to be words that happen to be valid base64 than encoded payloads
+	minBase64Length = 16
+)
+
+var (
+	base64Pattern           = regexp.MustCompile(`^[A-Za-z0-9+/_-]+={0,2}$`)
+	yamlDocumentSeparator   = regexp.MustCompile(`(?m)^---[ \t]*$`)
+	gzipMagicNumber         = []byte{0x1f, 0x8b}
+	decodingChainSeparator  = " → "
+	helmReleaseDecodingStep = "helm release"
+)
+
+// helmRelease holds the fields of a Helm release that may contain credentials. Helm stores releases in Secrets named
+// sh.helm.release.v1.<release>.v<revision>, as gzipped and base64-encoded JSON.
+type helmRelease struct {
+	Name  string `json:"name"`
+	Chart *struct {
+		Values map[string]interface{} `json:"values"`
+		Files  []struct {
+			Name string `json:"name"`
+			Data []byte `json:"data"`
+		} `json:"files"`
+	} `json:"chart"`
+	Config   map[string]interface{} `json:"config"`
+	Manifest string                 `json:"manifest"`
+}
+
+// decodeValues adds to the values of a resource the values they decode to, e.g. the fields of a base64-encoded JSON
+// document. Decoded values come before the value they were decoded from, so that credentials are reported where
+// they're the most precisely located.
+func decodeValues(values []scannedValue) []scannedValue {
+	var decodedValues []scannedValue
+	for _, value := range values {
+		decodedValues = append(decodedValues, expandValue(value, 0)...)
+	}
+	return decodedValues
+}
+
+func expandValue(value scannedValue, depth int) []scannedValue {
+	var expanded []scannedValue
+	if depth < maxDecodingDepth {
+		for _, decodedValue := range decodeValue(value) {
+			expanded = append(expanded, expandValue(decodedValue, depth+1)...)
+		}
+	}
+	return append(expanded, value)
+}
+
+// decodeValue decodes one level of encoding of a value
+func decodeValue(value scannedValue) []scannedValue {
+	if release := decodeHelmRelease(value.Value); release != nil {
+		return helmReleaseValues(value, release)
+	}
+	if decoded, ok := gunzip([]byte(value.Value)); ok {
+		return []scannedValue{value.decoded(value.Key, decoded, "gzip")}
+	}
+	if decoded, ok := decodeBase64(value.Value); ok {
+		return []scannedValue{value.decoded(value.Key, decoded, "base64")}
+	}
+	return decodeDocuments(value)
+}
+
+// decodeHelmRelease returns the Helm release that a value holds, or nil
+func decodeHelmRelease(value string) *helmRelease {
+	compressed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil || !bytes.HasPrefix(compressed, gzipMagicNumber) {
+		return nil
+	}
+	decompressed, ok := gunzip(compressed)
+	if !ok {
+		return nil
+	}
+	var release helmRelease
+	if err := json.Unmarshal([]byte(decompressed), &release); err != nil || release.Name == "" || release.Chart == nil {
+		return nil
+	}
+	return &release
+}
+
+// helmReleaseValues returns the values supplied when installing a release, the default values and files of its chart,
+// and its rendered manifest
+func helmReleaseValues(value scannedValue, release *helmRelease) []scannedValue {
+	var values []scannedValue
+	walkJSON(release.Config, "", "", func(path string, key string, fieldValue string) {
+		values = append(values, value.decoded(key, fieldValue, helmReleaseDecodingStep, "values.yaml", path))
+	})
+	walkJSON(release.Chart.Values, "", "", func(path string, key string, fieldValue string) {
+		values = append(values, value.decoded(key, fieldValue, helmReleaseDecodingStep, "chart values.yaml", path))
+	})
+	for _, file := range release.Chart.Files {
+		values = append(values, value.decoded(file.Name, string(file.Data), helmReleaseDecodingStep, "chart file "+file.Name))
+	}
+	if release.Manifest != "" {
+		values = append(values, value.decoded(value.Key, release.Manifest, helmReleaseDecodingStep, "manifest"))
+	}
+	return values
+}
+
+// decodeDocuments returns the fields of the JSON or YAML documents that a value holds. Documents that are a single
+// scalar aren't decoded, as the value itself is scanned. So are GCP service account keys, which their detector
+// parses as a whole.
+func decodeDocuments(value scannedValue) []scannedValue {
+	trimmedValue := strings.TrimSpace(value.Value)
+	if parseGcpServiceAccountKey(trimmedValue) != nil {
+		return nil
+	}
+	var documents []interface{}
+	if strings.HasPrefix(trimmedValue, "{") || strings.HasPrefix(trimmedValue, "[") {
+		var document interface{}
+		if err := json.Unmarshal([]byte(trimmedValue), &document); err == nil {
+			documents = append(documents, document)
+		}
+	}
+	if documents == nil && strings.Contains(trimmedValue, "\n") && strings.Contains(trimmedValue, ":") {
+		for _, rawDocument := range yamlDocumentSeparator.Split(trimmedValue, -1) {
+			var document interface{}
+			if err := yaml.Unmarshal([]byte(rawDocument), &document); err != nil {
+				return nil
+			}
+			if document != nil {
+				documents = append(documents, document)
+			}
+		}
+	}
+
+	var values []scannedValue
+	for i, document := range documents {
+		switch document.(type) {
+		case map[string]interface{}, []interface{}:
+		default:
+			continue
+		}
+		decodeSecretData(document)
+		var steps []string
+		if len(documents) > 1 {
+			steps = append(steps, documentName(document, i))
+		}
+		walkJSON(document, "", "", func(path string, key string, fieldValue string) {
+			values = append(values, value.decoded(key, fieldValue, append(steps, path)...))
+		})
+	}
+	return values
+}
+
+// documentName identifies a document of a multi-document YAML value, e.g. "Secret db-credentials" for Kubernetes
+// manifests
+func documentName(document interface{}, index int) string {
+	object, _ := document.(map[string]interface{})
+	kind, _ := object["kind"].(string)
+	metadata, _ := object["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	if kind != "" && name != "" {
+		return kind + " " + name
+	}
+	return fmt.Sprintf("document %d", index)
+}
+
+// decodeBase64 decodes base64-encoded text, possibly wrapped over several lines. Binary data is ignored, unless it's
+// compressed.
+func decodeBase64(value string) (string, bool) {
+	encoded := strings.NewReplacer("\n", "", "\r", "").Replace(strings.TrimSpace(value))
+	if len(encoded) < minBase64Length || !base64Pattern.MatchString(encoded) {
+		return "", false
+	}
+	encoded = strings.TrimRight(encoded, "=")
+	encoding := base64.RawStdEncoding
+	if strings.ContainsAny(encoded, "-_") {
+		encoding = base64.RawURLEncoding
+	}
+	decoded, err := encoding.DecodeString(encoded)
+	if err != nil || !(isText(decoded) || bytes.HasPrefix(decoded, gzipMagicNumber)) {
+		return "", false
+	}
+	return string(decoded), true
+}
+
+// gunzip decompresses gzipped text
+func gunzip(compressed []byte) (string, bool) {
+	if !bytes.HasPrefix(compressed, gzipMagicNumber) {
+		return "", false
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return "", false
+	}
+	decompressed, err := io.ReadAll(io.LimitReader(reader, maxDecompressedSize+1))
+	if err != nil || len(decompressed) > maxDecompressedSize || !isText(decompressed) {
+		return "", false
+	}
+	return string(decompressed), true
+}
+
+func isText(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	for _, character := range string(data) {
+		if (character < 0x20 && character != '\n' && character != '\r' && character != '\t') || character == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// decoded returns a value decoded from another one, with the decoding steps appended to its decoding chain
+func (m scannedValue) decoded(key string, value string, steps ...string) scannedValue {
+	decodingChain := append(append([]string{}, m.DecodingChain...), steps...)
+	return scannedValue{Location: m.Location, Key: key, Value: value, Source: m.Source, DecodingChain: decodingChain}
+}
+
+// fullLocation returns where a value is in a resource, including how it was decoded, e.g.
+// "key release → helm release → values.yaml → env.AWS_SECRET"
+func (m scannedValue) fullLocation() string {
+	if len(m.DecodingChain) == 0 {
+		return m.Location
+	}
+	return m.Location + decodingChainSeparator + strings.Join(m.DecodingChain, decodingChainSeparator)
+}
+
+// isDecodedFrom determines if a value was decoded, possibly over several steps, from another one
+func (m scannedValue) isDecodedFrom(other scannedValue) bool {
+	if m.Location != other.Location || len(m.DecodingChain) <= len(other.DecodingChain) {
+		return false
+	}
+	for i, step := range other.DecodingChain {
+		if m.DecodingChain[i] != step {
+			return false
+		}
+	}
+	return true
+}
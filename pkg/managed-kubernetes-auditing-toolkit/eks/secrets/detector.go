@@ -0,0 +1,208 @@
+package secrets
+
+import (
+	"fmt"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+)
+
+// TokenType is a part of a credential, e.g. an AWS access key ID
+type TokenType struct {
+	Name      string // e.g. "AWS secret access key"
+	Sensitive bool   // false if the value can be disclosed in findings, like AWS access key IDs
+}
+
+// Token is a value that looks like a part of a credential
+type Token struct {
+	Type  string // name of the token type
+	Value string
+}
+
+// Detector finds a type of credentials in the values of Kubernetes resources. A credential is only reported when all
+// its tokens are found in the same resource (or the same container of a pod), which keeps false positives low.
+type Detector interface {
+	// Name identifies the detector, findings have the rule ID "k8s-hardcoded-<name>"
+	Name() string
+	Title() string // e.g. "Hardcoded AWS credentials"
+	Severity() findings.Severity
+	Remediation() string
+	// Tokens returns the tokens that must all be found to report a credential, in the order they're reported
+	Tokens() []TokenType
+	// FindTokens returns the tokens found in a value, in order. The key is the name of the ConfigMap or Secret key, or
+	// of the environment variable, holding the value.
+	FindTokens(key string, value string) []*Token
+	// Validate determines if tokens found in the same resource, by token type, make up a credential
+	Validate(tokens map[string]string) bool
+}
+
+// KeyTypeDetector is implemented by detectors that find several types of keys, e.g. long-term and temporary ones
+type KeyTypeDetector interface {
+	// KeyType returns the type of key that tokens make up
+	KeyType(tokens map[string]string) string
+}
+
+var registeredDetectors []Detector
+
+// RegisterDetector adds a detector to the ones find-secrets uses by default
+func RegisterDetector(detector Detector) {
+	if DetectorByName(detector.Name()) != nil {
+		panic(fmt.Sprintf("a secrets detector named %s is already registered", detector.Name()))
+	}
+	registeredDetectors = append(registeredDetectors, detector)
+}
+
+// RegisteredDetectors returns the built-in detectors, and the ones added with RegisterDetector
+func RegisteredDetectors() []Detector {
+	return append([]Detector{}, registeredDetectors...)
+}
+
+// DetectorByName returns the registered detector with a name, or nil
+func DetectorByName(name string) Detector {
+	for _, detector := range registeredDetectors {
+		if detector.Name() == name {
+			return detector
+		}
+	}
+	return nil
+}
+
+// scannedResource is a Kubernetes resource in which we look for credentials
+type scannedResource struct {
+	Type      string // ConfigMap, Secret, Pod, or a workload such as Deployment
+	Namespace string
+	Name      string
+}
+
+// scannedValue is a value of a Kubernetes resource that may hold credentials
+type scannedValue struct {
+	Location string // where the value is in the resource, e.g. "key credentials"
+	Key      string // name of the key or environment variable, e.g. "credentials"
+	Value    string
+	// Source is the ConfigMap or Secret a container takes the value from, e.g. "Secret aws-credentials"
+	Source string
+	// DecodingChain lists how the value was decoded from the one at Location, e.g. ["base64", "auths.ghcr.io.auth"]
+	DecodingChain []string
+}
+
+// findCredentials pairs the tokens found in the values of a resource into credentials. Tokens are paired in the
+// order they're found, so that a resource with several credentials has all of them reported. Encoded values are
+// decoded first, and tokens are only reported in the most decoded value they're found in. A credential whose tokens are
+// all part of a larger credential, e.g. a Datadog API key paired with an application key, is only reported as the
+// larger one.
+func findCredentials(detectors []Detector, resource scannedResource, values []scannedValue) []*SecretInfo {
+	var credentials [][]*SecretInfo
+	values = decodeValues(values)
+	for _, detector := range detectors {
+		tokenTypes := detector.Tokens()
+		pendingTokens := map[string][]*SecretInfo{}
+		tokenSources := map[Token][]scannedValue{}
+		for _, value := range values {
+			for _, token := range detector.FindTokens(value.Key, value.Value) {
+				if isFoundInDecodedValue(tokenSources[*token], value) {
+					continue
+				}
+				tokenSources[*token] = append(tokenSources[*token], value)
+				location := value.fullLocation()
+				pendingTokens[token.Type] = append(pendingTokens[token.Type], &SecretInfo{
+					Namespace:     resource.Namespace,
+					Type:          resource.Type,
+					Name:          fmt.Sprintf("%s (%s)", resource.Name, location),
+					Value:         token.Value,
+					ResourceName:  resource.Name,
+					Location:      location,
+					Detector:      detector.Name(),
+					TokenType:     token.Type,
+					DecodingChain: value.DecodingChain,
+					Source:        value.Source,
+				})
+			}
+			for hasAllTokens(pendingTokens, tokenTypes) {
+				credential := make([]*SecretInfo, 0, len(tokenTypes))
+				tokenValues := make(map[string]string, len(tokenTypes))
+				for _, tokenType := range tokenTypes {
+					credential = append(credential, pendingTokens[tokenType.Name][0])
+					tokenValues[tokenType.Name] = pendingTokens[tokenType.Name][0].Value
+					pendingTokens[tokenType.Name] = pendingTokens[tokenType.Name][1:]
+				}
+				if !detector.Validate(tokenValues) || isFromSingleSource(credential) {
+					continue
+				}
+				for _, secret := range credential {
+					secret.credential = tokenValues
+				}
+				if keyTypeDetector, ok := detector.(KeyTypeDetector); ok {
+					keyType := keyTypeDetector.KeyType(tokenValues)
+					for _, secret := range credential {
+						secret.KeyType = keyType
+					}
+				}
+				credentials = append(credentials, credential)
+			}
+		}
+	}
+	var secrets []*SecretInfo
+	for _, credential := range credentials {
+		if !isPartOfLargerCredential(credential, credentials) {
+			secrets = append(secrets, credential...)
+		}
+	}
+	return secrets
+}
+
+// isPartOfLargerCredential determines if all tokens of a credential, at the same locations, were also found as part of
+// a credential with more tokens
+func isPartOfLargerCredential(credential []*SecretInfo, credentials [][]*SecretInfo) bool {
+	for _, other := range credentials {
+		if len(other) > len(credential) && containsTokens(other, credential) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsTokens(credential []*SecretInfo, tokens []*SecretInfo) bool {
+	for _, token := range tokens {
+		found := false
+		for _, secret := range credential {
+			if secret.TokenType == token.TokenType && secret.Value == token.Value && secret.Location == token.Location {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func hasAllTokens(pendingTokens map[string][]*SecretInfo, tokenTypes []TokenType) bool {
+	for _, tokenType := range tokenTypes {
+		if len(pendingTokens[tokenType.Name]) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// isFoundInDecodedValue determines if a token was already found in a value decoded from the one it's found in, e.g.
+// in a field of a JSON document
+func isFoundInDecodedValue(sources []scannedValue, value scannedValue) bool {
+	for _, source := range sources {
+		if source.isDecodedFrom(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// isFromSingleSource determines if all tokens of a credential come from the same ConfigMap or Secret referenced by a
+// container, in which case the credential is reported in that object rather than in the pod
+func isFromSingleSource(credential []*SecretInfo) bool {
+	for _, secret := range credential {
+		if secret.Source == "" || secret.Source != credential[0].Source {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,146 @@
+package secrets
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+)
+
+// Rule IDs of hardcoded credentials are this prefix followed by the name of the detector
+const RuleIDPrefix = "k8s-hardcoded-"
+
+const (
+	RuleIDHardcodedAWSCredentials          = RuleIDPrefix + "aws-credentials"
+	RuleIDHardcodedAWSTemporaryCredentials = RuleIDPrefix + "aws-temporary-credentials"
+	RuleIDHardcodedDatabaseURL             = RuleIDPrefix + "database-url"
+)
+
+var resourceTypes = map[string]findings.ResourceType{
+	"ConfigMap":   findings.ResourceTypeKubernetesConfigMap,
+	"Secret":      findings.ResourceTypeKubernetesSecret,
+	"Pod":         findings.ResourceTypeKubernetesPod,
+	"Deployment":  findings.ResourceTypeKubernetesDeployment,
+	"StatefulSet": findings.ResourceTypeKubernetesStatefulSet,
+	"DaemonSet":   findings.ResourceTypeKubernetesDaemonSet,
+	"ReplicaSet":  findings.ResourceTypeKubernetesReplicaSet,
+	"Job":         findings.ResourceTypeKubernetesJob,
+	"CronJob":     findings.ResourceTypeKubernetesCronJob,
+}
+
+const (
+	// Number of characters of a masked value that are kept, to help recognize the type of secret
+	maskedPrefixLength = 4
+	// Number of hexadecimal characters of the hash of a masked value, enough to tell secrets apart
+	maskedHashLength = 12
+)
+
+// Key of the HMAC of masked values, generated at each run so that hashes can't be matched against the ones of guessed
+// values. Masked values can be told apart within a run, but not across runs.
+var maskingKey = newMaskingKey()
+
+// Secrets are meant to hold credentials, so credentials in Secrets are one level less severe, but still a risk
+var secretSeverities = map[findings.Severity]findings.Severity{
+	findings.SeverityCritical:      findings.SeverityHigh,
+	findings.SeverityHigh:          findings.SeverityMedium,
+	findings.SeverityMedium:        findings.SeverityLow,
+	findings.SeverityLow:           findings.SeverityLow,
+	findings.SeverityInformational: findings.SeverityInformational,
+}
+
+// Finding describes where the secret was found, without disclosing it
+func (m *SecretInfo) Finding(clusterName string) *findings.Finding {
+	finding := &findings.Finding{
+		RuleID:      RuleIDPrefix + "credentials",
+		Title:       "Hardcoded credentials",
+		Severity:    findings.SeverityHigh,
+		Remediation: "Revoke the credentials, and store them in a Secret or an external secrets manager",
+		Resource:    findings.KubernetesResource(clusterName, resourceTypes[m.Type], m.Namespace, m.ResourceName),
+		// Identifies the secret when a resource holds several ones. It doesn't include the value, so that fingerprints
+		// can't be used to guess it.
+		Discriminator: m.Location + "|" + m.TokenType,
+		Secret:        m.Value,
+	}
+	tokenType := TokenType{Name: m.TokenType, Sensitive: true}
+	if detector := DetectorByName(m.Detector); detector != nil {
+		finding.RuleID = RuleIDPrefix + detector.Name()
+		finding.Title = detector.Title()
+		finding.Severity = detector.Severity()
+		finding.Remediation = detector.Remediation()
+		for _, candidate := range detector.Tokens() {
+			if candidate.Name == m.TokenType {
+				tokenType = candidate
+			}
+		}
+	}
+	// Validated credentials are more severe when live, and less when they can't be used anymore
+	if m.Validation != nil && m.Validation.Status == AwsKeyStatusLive {
+		finding.Severity = findings.SeverityCritical
+	} else if m.Validation != nil && m.Validation.Status == AwsKeyStatusInvalid {
+		finding.Severity = findings.SeverityLow
+	}
+	if m.Type == "Secret" {
+		finding.Severity = secretSeverities[finding.Severity]
+	}
+	secret := redact(tokenType, m.Value)
+	if m.KeyType != "" {
+		secret += " (" + m.KeyType + ")"
+	}
+	finding.Description = fmt.Sprintf("%s %s/%s contains %s in %s", m.Type, m.Namespace, m.ResourceName, secret, m.Location)
+	if m.Validation != nil {
+		finding.Description += ". " + m.Validation.description()
+	}
+	return finding
+}
+
+// Fingerprint identifies the finding of the secret across runs, e.g. in baselines
+func (m *SecretInfo) Fingerprint(clusterName string) string {
+	return m.Finding(clusterName).Fingerprint()
+}
+
+// MaskValue hides a secret in outputs that may be retained, such as CI logs. It keeps a short prefix to recognize the
+// secret, and a keyed hash to tell secrets apart, e.g. "AKIA…(hmac:5d3b8f1c2a9e)". Short values have no prefix.
+func MaskValue(value string) string {
+	mac := hmac.New(sha256.New, maskingKey)
+	mac.Write([]byte(value))
+	prefix := ""
+	if len(value) >= 4*maskedPrefixLength {
+		prefix = value[:maskedPrefixLength]
+	}
+	return prefix + "…(hmac:" + hex.EncodeToString(mac.Sum(nil))[:maskedHashLength] + ")"
+}
+
+func newMaskingKey() []byte {
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("unable to generate the key to mask secrets: %v", err))
+	}
+	return key
+}
+
+// redact returns a description of a secret value that can be shared. Sensitive values, such as AWS secret access
+// keys, are truncated.
+func redact(tokenType TokenType, value string) string {
+	name := tokenType.Name
+	if name == "" {
+		name = "secret"
+	}
+	if !tokenType.Sensitive {
+		return "the " + name + " " + value
+	}
+	if len(value) > 4 {
+		value = value[:4]
+	}
+	return article(name) + " " + name + " starting with " + value
+}
+
+func article(noun string) string {
+	if strings.ContainsAny(noun[:1], "AEIOUaeiou") {
+		return "an"
+	}
+	return "a"
+}
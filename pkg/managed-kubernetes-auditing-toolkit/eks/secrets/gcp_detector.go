@@ -0,0 +1,85 @@
+package secrets
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+)
+
+const (
+	TokenTypeGcpServiceAccount = "GCP service account"
+	TokenTypeGcpPrivateKeyID   = "GCP private key ID"
+)
+
+var gcpServiceAccountKeyPattern = regexp.MustCompile(`"type"\s*:\s*"service_account"`)
+
+// gcpServiceAccountKey is a JSON key of a GCP service account
+type gcpServiceAccountKey struct {
+	Type         string `json:"type"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	ClientEmail  string `json:"client_email"`
+}
+
+// GcpServiceAccountKeyDetector finds JSON keys of GCP service accounts. A key is only reported when the whole value is
+// a JSON document with the email of the service account and a private key.
+type GcpServiceAccountKeyDetector struct{}
+
+func init() {
+	RegisterDetector(&GcpServiceAccountKeyDetector{})
+}
+
+func (m *GcpServiceAccountKeyDetector) Name() string {
+	return "gcp-service-account-key"
+}
+
+func (m *GcpServiceAccountKeyDetector) Title() string {
+	return "Hardcoded GCP service account key"
+}
+
+func (m *GcpServiceAccountKeyDetector) Severity() findings.Severity {
+	return findings.SeverityHigh
+}
+
+func (m *GcpServiceAccountKeyDetector) Remediation() string {
+	return "Delete the key of the service account, and use Workload Identity Federation to give pods access to GCP instead"
+}
+
+func (m *GcpServiceAccountKeyDetector) Tokens() []TokenType {
+	return []TokenType{
+		{Name: TokenTypeGcpServiceAccount, Sensitive: false},
+		{Name: TokenTypeGcpPrivateKeyID, Sensitive: true},
+	}
+}
+
+func (m *GcpServiceAccountKeyDetector) FindTokens(key string, value string) []*Token {
+	serviceAccountKey := parseGcpServiceAccountKey(value)
+	if serviceAccountKey == nil {
+		return nil
+	}
+	return []*Token{
+		{Type: TokenTypeGcpServiceAccount, Value: serviceAccountKey.ClientEmail},
+		{Type: TokenTypeGcpPrivateKeyID, Value: serviceAccountKey.PrivateKeyID},
+	}
+}
+
+func (m *GcpServiceAccountKeyDetector) Validate(tokens map[string]string) bool {
+	return strings.Contains(tokens[TokenTypeGcpServiceAccount], "@")
+}
+
+// parseGcpServiceAccountKey returns the GCP service account key a value is, or nil
+func parseGcpServiceAccountKey(value string) *gcpServiceAccountKey {
+	if !gcpServiceAccountKeyPattern.MatchString(value) {
+		return nil
+	}
+	var serviceAccountKey gcpServiceAccountKey
+	if err := json.Unmarshal([]byte(strings.TrimSpace(value)), &serviceAccountKey); err != nil {
+		return nil
+	}
+	if serviceAccountKey.Type != "service_account" || serviceAccountKey.ClientEmail == "" || serviceAccountKey.PrivateKeyID == "" || !strings.Contains(serviceAccountKey.PrivateKey, "PRIVATE KEY") {
+		return nil
+	}
+	return &serviceAccountKey
+}
@@ -0,0 +1,60 @@
+package secrets
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+)
+
+var (
+	GitHubTokenPattern = regexp.MustCompile("^((ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9]{22}_[A-Za-z0-9]{59})$")
+	GitLabTokenPattern = regexp.MustCompile("^(glpat|gldt|glrt)-[0-9a-zA-Z_.-]{20,}$")
+)
+
+// Prefixes of GitHub and GitLab tokens, and the type of token they identify
+var gitTokenTypes = map[string]string{
+	"ghp_":        "personal access token (classic)",
+	"github_pat_": "fine-grained personal access token",
+	"gho_":        "OAuth access token",
+	"ghu_":        "GitHub App user access token",
+	"ghs_":        "GitHub App installation access token",
+	"ghr_":        "GitHub App refresh token",
+	"glpat-":      "personal access token",
+	"gldt-":       "deploy token",
+	"glrt-":       "runner authentication token",
+}
+
+func init() {
+	RegisterDetector(&SingleTokenDetector{
+		DetectorName:        "github-token",
+		DetectorTitle:       "Hardcoded GitHub token",
+		DetectorSeverity:    findings.SeverityHigh,
+		DetectorRemediation: "Revoke the token, and store it in a Secret or an external secrets manager",
+		TokenType:           TokenType{Name: "GitHub token", Sensitive: true},
+		Match: func(key string, value string) []string {
+			return matchAll(GitHubTokenPattern, value)
+		},
+		GetKeyType: gitTokenType,
+	})
+	RegisterDetector(&SingleTokenDetector{
+		DetectorName:        "gitlab-token",
+		DetectorTitle:       "Hardcoded GitLab token",
+		DetectorSeverity:    findings.SeverityHigh,
+		DetectorRemediation: "Revoke the token, and store it in a Secret or an external secrets manager",
+		TokenType:           TokenType{Name: "GitLab token", Sensitive: true},
+		Match: func(key string, value string) []string {
+			return matchAll(GitLabTokenPattern, value)
+		},
+		GetKeyType: gitTokenType,
+	})
+}
+
+func gitTokenType(token string) string {
+	for prefix, tokenType := range gitTokenTypes {
+		if strings.HasPrefix(token, prefix) {
+			return tokenType
+		}
+	}
+	return ""
+}
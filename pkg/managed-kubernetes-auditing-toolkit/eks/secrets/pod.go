@@ -1,52 +1,98 @@
 package secrets
 
 import (
-	"fmt"
+	"strconv"
+	"strings"
+
 	v1 "k8s.io/api/core/v1"
 )
 
-func findSecretsInSinglePodDefinition(pod *v1.Pod) []*SecretInfo {
+// objectResolver retrieves the ConfigMaps and Secrets that containers reference, or nil if they don't exist
+type objectResolver interface {
+	getConfigMap(namespace string, name string) *v1.ConfigMap
+	getSecret(namespace string, name string) *v1.Secret
+}
+
+func findSecretsInSinglePodDefinition(pod *v1.Pod, detectors []Detector) []*SecretInfo {
+	resource := scannedResource{Type: "Pod", Namespace: pod.Namespace, Name: pod.Name}
+	secrets := findSecretsInPodSpec(resource, &pod.Spec, nil, detectors)
+	return append(secrets, findSecretsInLastAppliedConfiguration(resource, pod, detectors)...)
+}
+
+// findSecretsInPodSpec scans the containers of a pod, or of the pod template of a workload. When a resolver is given,
+// the ConfigMaps and Secrets that containers take environment variables and files from are scanned along with the
+// container, so that credentials split between them and the pod are found.
+func findSecretsInPodSpec(resource scannedResource, spec *v1.PodSpec, resolver objectResolver, detectors []Detector) []*SecretInfo {
 	var secrets []*SecretInfo
-	for _, container := range pod.Spec.Containers {
-		secrets = append(secrets, findSecretsInContainerDefinition(pod, &container)...)
+	for i := range spec.Containers {
+		secrets = append(secrets, findSecretsInContainerDefinition(resource, spec, &spec.Containers[i], resolver, detectors)...)
+	}
+	for i := range spec.InitContainers {
+		secrets = append(secrets, findSecretsInContainerDefinition(resource, spec, &spec.InitContainers[i], resolver, detectors)...)
 	}
-	for _, container := range pod.Spec.InitContainers {
-		secrets = append(secrets, findSecretsInContainerDefinition(pod, &container)...)
+	for i := range spec.EphemeralContainers {
+		container := v1.Container(spec.EphemeralContainers[i].EphemeralContainerCommon)
+		secrets = append(secrets, findSecretsInContainerDefinition(resource, spec, &container, resolver, detectors)...)
 	}
 	return secrets
 }
 
-func findSecretsInContainerDefinition(pod *v1.Pod, container *v1.Container) []*SecretInfo {
-	var secrets []*SecretInfo
-
-	var accessKeyInfo *SecretInfo
-	var secretKeyInfo *SecretInfo
-
+// findSecretsInContainerDefinition only pairs tokens found in the same container, or in the objects it references
+func findSecretsInContainerDefinition(resource scannedResource, spec *v1.PodSpec, container *v1.Container, resolver objectResolver, detectors []Detector) []*SecretInfo {
+	ofContainer := ""
+	if container.Name != "" {
+		ofContainer = " of container " + container.Name
+	}
+	var values []scannedValue
 	for _, env := range container.Env {
-		foundCredentials := FindAwsCredentialsInUnstructuredString(env.Value)
-		if foundCredentials.AccessKey != "" {
-			accessKeyInfo = &SecretInfo{
-				Namespace: pod.Namespace,
-				Name:      fmt.Sprintf("%s (environment variable %s)", pod.Name, env.Name),
-				Type:      "Pod",
-				Value:     foundCredentials.AccessKey,
-			}
+		if env.ValueFrom == nil {
+			values = append(values, scannedValue{Location: "environment variable " + env.Name + ofContainer, Key: env.Name, Value: env.Value})
+			continue
 		}
-		if foundCredentials.SecretKey != "" {
-			secretKeyInfo = &SecretInfo{
-				Namespace: pod.Namespace,
-				Name:      fmt.Sprintf("%s (environment variable %s)", pod.Name, env.Name),
-				Type:      "Pod",
-				Value:     foundCredentials.SecretKey,
-			}
+		object, key := envVarReference(env.ValueFrom)
+		if object == nil || resolver == nil {
+			continue
 		}
-		if accessKeyInfo != nil && secretKeyInfo != nil {
-			secrets = append(secrets, accessKeyInfo, secretKeyInfo)
-			// start searching for a new set of credentials
-			accessKeyInfo = nil
-			secretKeyInfo = nil
+		if value, found := object.data(resource.Namespace, resolver)[key]; found {
+			location := "environment variable " + env.Name + ofContainer + " (key " + key + " of " + object.String() + ")"
+			values = append(values, scannedValue{Location: location, Key: env.Name, Value: value, Source: object.String()})
 		}
 	}
+	for _, envFrom := range container.EnvFrom {
+		object := envFromReference(envFrom)
+		if object == nil || resolver == nil {
+			continue
+		}
+		data := object.data(resource.Namespace, resolver)
+		for _, key := range sortedKeys(data) {
+			name := envFrom.Prefix + key
+			location := "environment variable " + name + ofContainer + " (" + object.String() + ")"
+			values = append(values, scannedValue{Location: location, Key: name, Value: data[key], Source: object.String()})
+		}
+	}
+	if resolver != nil {
+		values = append(values, mountedValues(resource.Namespace, spec, container, ofContainer, resolver)...)
+	}
+	for i, command := range container.Command {
+		values = append(values, scannedValue{Location: "command argument " + strconv.Itoa(i) + ofContainer, Key: argumentKey(container.Command, i), Value: command})
+	}
+	for i, arg := range container.Args {
+		values = append(values, scannedValue{Location: "argument " + strconv.Itoa(i) + ofContainer, Key: argumentKey(container.Args, i), Value: arg})
+	}
+	return findCredentials(detectors, resource, values)
+}
 
-	return secrets
+// argumentKey returns the name of the flag a command-line argument is the value of, e.g. "--api-key" for
+// "--api-key=value", or for "value" when it follows "--api-key"
+func argumentKey(args []string, i int) string {
+	if strings.HasPrefix(args[i], "-") {
+		if flag, _, found := strings.Cut(args[i], "="); found {
+			return flag
+		}
+		return ""
+	}
+	if i > 0 && strings.HasPrefix(args[i-1], "-") && !strings.Contains(args[i-1], "=") {
+		return args[i-1]
+	}
+	return ""
 }
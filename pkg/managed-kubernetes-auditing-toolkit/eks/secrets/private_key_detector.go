@@ -0,0 +1,41 @@
+package secrets
+
+import (
+	"regexp"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+)
+
+// Unencrypted private keys in PEM format. Their body may have escaped newlines, e.g. in JSON documents.
+var PrivateKeyPattern = regexp.MustCompile(`-----BEGIN ((RSA|EC|DSA|OPENSSH) )?PRIVATE KEY-----([A-Za-z0-9+/=\s]|\\n)+-----END ((RSA|EC|DSA|OPENSSH) )?PRIVATE KEY-----`)
+
+// Keys with fewer base64 characters are placeholders or truncated examples
+const minPrivateKeyBodyLength = 64
+
+var privateKeyBodyPattern = regexp.MustCompile(`[A-Za-z0-9+/=]`)
+
+func init() {
+	RegisterDetector(&SingleTokenDetector{
+		DetectorName:        "private-key",
+		DetectorTitle:       "Hardcoded private key",
+		DetectorSeverity:    findings.SeverityHigh,
+		DetectorRemediation: "Rotate the key pair, and store the private key in a Secret or an external secrets manager",
+		TokenType:           TokenType{Name: "private key", Sensitive: true},
+		Match: func(key string, value string) []string {
+			// Private keys of GCP service accounts are reported by the GCP detector
+			if parseGcpServiceAccountKey(value) != nil {
+				return nil
+			}
+			return PrivateKeyPattern.FindAllString(value, -1)
+		},
+		IsValid: func(token string) bool {
+			return len(privateKeyBodyPattern.FindAllString(token, -1)) >= minPrivateKeyBodyLength
+		},
+		GetKeyType: func(token string) string {
+			if algorithm := PrivateKeyPattern.FindStringSubmatch(token)[2]; algorithm != "" {
+				return algorithm
+			}
+			return "PKCS #8"
+		},
+	})
+}
@@ -0,0 +1,145 @@
+package secrets
+
+import (
+	"path"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// referencedObject is a ConfigMap or Secret that a container takes environment variables or files from
+type referencedObject struct {
+	Kind string // ConfigMap or Secret
+	Name string
+}
+
+// String returns how the object is reported in findings, e.g. "Secret aws-credentials"
+func (m *referencedObject) String() string {
+	return m.Kind + " " + m.Name
+}
+
+// data returns the data of the object by key, or nil if it doesn't exist. Like in the Secret itself, private keys are
+// ignored in Secrets whose type is meant to hold them.
+func (m *referencedObject) data(namespace string, resolver objectResolver) map[string]string {
+	data := map[string]string{}
+	switch m.Kind {
+	case "ConfigMap":
+		configMap := resolver.getConfigMap(namespace, m.Name)
+		if configMap == nil {
+			return nil
+		}
+		for key, value := range configMap.Data {
+			data[key] = value
+		}
+		for key, value := range configMap.BinaryData {
+			data[key] = string(value)
+		}
+	case "Secret":
+		secret := resolver.getSecret(namespace, m.Name)
+		if secret == nil {
+			return nil
+		}
+		for key, value := range secret.Data {
+			if !isExpectedPrivateKey(secret, key) {
+				data[key] = string(value)
+			}
+		}
+	}
+	return data
+}
+
+// envVarReference returns the object and key that an environment variable takes its value from, or nil if it
+// doesn't come from a ConfigMap or a Secret
+func envVarReference(source *v1.EnvVarSource) (*referencedObject, string) {
+	if source.ConfigMapKeyRef != nil {
+		return &referencedObject{Kind: "ConfigMap", Name: source.ConfigMapKeyRef.Name}, source.ConfigMapKeyRef.Key
+	}
+	if source.SecretKeyRef != nil {
+		return &referencedObject{Kind: "Secret", Name: source.SecretKeyRef.Name}, source.SecretKeyRef.Key
+	}
+	return nil, ""
+}
+
+// envFromReference returns the object that a container takes environment variables from
+func envFromReference(source v1.EnvFromSource) *referencedObject {
+	if source.ConfigMapRef != nil {
+		return &referencedObject{Kind: "ConfigMap", Name: source.ConfigMapRef.Name}
+	}
+	if source.SecretRef != nil {
+		return &referencedObject{Kind: "Secret", Name: source.SecretRef.Name}
+	}
+	return nil
+}
+
+// volumeProjection is a ConfigMap or Secret whose keys are files of a volume
+type volumeProjection struct {
+	Object *referencedObject
+	Items  []v1.KeyToPath // keys to project and the path of their file, all keys if empty
+}
+
+// volumeProjections returns the ConfigMaps and Secrets that a volume holds the data of
+func volumeProjections(volume *v1.Volume) []volumeProjection {
+	var projections []volumeProjection
+	if volume.ConfigMap != nil {
+		projections = append(projections, volumeProjection{Object: &referencedObject{Kind: "ConfigMap", Name: volume.ConfigMap.Name}, Items: volume.ConfigMap.Items})
+	}
+	if volume.Secret != nil {
+		projections = append(projections, volumeProjection{Object: &referencedObject{Kind: "Secret", Name: volume.Secret.SecretName}, Items: volume.Secret.Items})
+	}
+	if volume.Projected != nil {
+		for _, source := range volume.Projected.Sources {
+			if source.ConfigMap != nil {
+				projections = append(projections, volumeProjection{Object: &referencedObject{Kind: "ConfigMap", Name: source.ConfigMap.Name}, Items: source.ConfigMap.Items})
+			}
+			if source.Secret != nil {
+				projections = append(projections, volumeProjection{Object: &referencedObject{Kind: "Secret", Name: source.Secret.Name}, Items: source.Secret.Items})
+			}
+		}
+	}
+	return projections
+}
+
+// files returns the keys of the object that are projected into the volume, and their path in the volume
+func (m *volumeProjection) files(data map[string]string) []v1.KeyToPath {
+	if len(m.Items) == 0 {
+		var files []v1.KeyToPath
+		for _, key := range sortedKeys(data) {
+			files = append(files, v1.KeyToPath{Key: key, Path: key})
+		}
+		return files
+	}
+	var files []v1.KeyToPath
+	for _, item := range m.Items {
+		if _, found := data[item.Key]; found {
+			files = append(files, item)
+		}
+	}
+	return files
+}
+
+// mountedValues returns the content of the files that a container mounts from ConfigMaps and Secrets
+func mountedValues(namespace string, spec *v1.PodSpec, container *v1.Container, ofContainer string, resolver objectResolver) []scannedValue {
+	volumes := map[string]*v1.Volume{}
+	for i := range spec.Volumes {
+		volumes[spec.Volumes[i].Name] = &spec.Volumes[i]
+	}
+	var values []scannedValue
+	for _, mount := range container.VolumeMounts {
+		volume, found := volumes[mount.Name]
+		if !found {
+			continue
+		}
+		for _, projection := range volumeProjections(volume) {
+			data := projection.Object.data(namespace, resolver)
+			for _, file := range projection.files(data) {
+				if mount.SubPath != "" && file.Path != mount.SubPath && !strings.HasPrefix(file.Path, mount.SubPath+"/") {
+					continue
+				}
+				filePath := path.Join(mount.MountPath, strings.TrimPrefix(file.Path, mount.SubPath))
+				location := "file " + filePath + ofContainer + " (key " + file.Key + " of " + projection.Object.String() + ")"
+				values = append(values, scannedValue{Location: location, Key: file.Key, Value: data[file.Key], Source: projection.Object.String()})
+			}
+		}
+	}
+	return values
+}
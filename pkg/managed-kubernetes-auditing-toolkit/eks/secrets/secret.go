@@ -1,41 +1,29 @@
 package secrets
 
 import (
-	"fmt"
 	v1 "k8s.io/api/core/v1"
 )
 
-func findSecretsInSingleSecret(secret *v1.Secret) []*SecretInfo {
-	// TODO: The code is very similar to ConfigMaps, we should probably refactor it
-	var secrets []*SecretInfo
-	var accessKeyInfo *SecretInfo
-	var secretKeyInfo *SecretInfo
-
-	for key, value := range secret.Data {
-		foundCredentials := FindAwsCredentialsInUnstructuredString(string(value))
-		if foundCredentials.AccessKey != "" {
-			accessKeyInfo = &SecretInfo{
-				Namespace: secret.Namespace,
-				Name:      fmt.Sprintf("%s (key %s)", secret.Name, key),
-				Type:      "Secret",
-				Value:     foundCredentials.AccessKey,
-			}
-		}
-		if foundCredentials.SecretKey != "" {
-			secretKeyInfo = &SecretInfo{
-				Namespace: secret.Namespace,
-				Name:      fmt.Sprintf("%s (key %s)", secret.Name, key),
-				Type:      "Secret",
-				Value:     foundCredentials.SecretKey,
-			}
+func findSecretsInSingleSecret(secret *v1.Secret, detectors []Detector) []*SecretInfo {
+	resource := scannedResource{Type: "Secret", Namespace: secret.Namespace, Name: secret.Name}
+	var values []scannedValue
+	for _, key := range sortedKeys(secret.Data) {
+		if isExpectedPrivateKey(secret, key) {
+			continue
 		}
-		if accessKeyInfo != nil && secretKeyInfo != nil {
-			secrets = append(secrets, accessKeyInfo, secretKeyInfo)
-			// start searching for a new set of credentials
-			accessKeyInfo = nil
-			secretKeyInfo = nil
-		}
-
+		values = append(values, scannedValue{Location: "key " + key, Key: key, Value: string(secret.Data[key])})
 	}
-	return secrets
+	secrets := findCredentials(detectors, resource, values)
+	return append(secrets, findSecretsInLastAppliedConfiguration(resource, secret, detectors)...)
+}
+
+// Keys holding a private key in Secrets of types meant for it, which aren't hardcoded credentials
+var privateKeySecretKeys = map[v1.SecretType]string{
+	v1.SecretTypeTLS:     v1.TLSPrivateKeyKey,
+	v1.SecretTypeSSHAuth: v1.SSHAuthPrivateKey,
+}
+
+func isExpectedPrivateKey(secret *v1.Secret, key string) bool {
+	expectedKey, found := privateKeySecretKeys[secret.Type]
+	return found && key == expectedKey
 }
@@ -0,0 +1,63 @@
+package secrets
+
+import (
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+)
+
+// SingleTokenDetector finds credentials made of a single token, such as API tokens with a distinctive prefix. Their
+// format must be specific enough to keep false positives low without pairing them with other tokens.
+type SingleTokenDetector struct {
+	DetectorName        string
+	DetectorTitle       string
+	DetectorSeverity    findings.Severity
+	DetectorRemediation string
+	TokenType           TokenType
+	// Match returns the candidate tokens found in a value
+	Match func(key string, value string) []string
+	// IsValid filters out candidate tokens that aren't credentials, e.g. placeholders. Optional.
+	IsValid func(token string) bool
+	// GetKeyType returns the type of key a token is, e.g. "fine-grained personal access token". Optional.
+	GetKeyType func(token string) string
+}
+
+func (m *SingleTokenDetector) Name() string {
+	return m.DetectorName
+}
+
+func (m *SingleTokenDetector) Title() string {
+	return m.DetectorTitle
+}
+
+func (m *SingleTokenDetector) Severity() findings.Severity {
+	return m.DetectorSeverity
+}
+
+func (m *SingleTokenDetector) Remediation() string {
+	return m.DetectorRemediation
+}
+
+func (m *SingleTokenDetector) Tokens() []TokenType {
+	return []TokenType{m.TokenType}
+}
+
+func (m *SingleTokenDetector) FindTokens(key string, value string) []*Token {
+	var tokens []*Token
+	for _, match := range m.Match(key, value) {
+		tokens = append(tokens, &Token{Type: m.TokenType.Name, Value: match})
+	}
+	return tokens
+}
+
+func (m *SingleTokenDetector) Validate(tokens map[string]string) bool {
+	if m.IsValid == nil {
+		return true
+	}
+	return m.IsValid(tokens[m.TokenType.Name])
+}
+
+func (m *SingleTokenDetector) KeyType(tokens map[string]string) string {
+	if m.GetKeyType == nil {
+		return ""
+	}
+	return m.GetKeyType(tokens[m.TokenType.Name])
+}
@@ -0,0 +1,22 @@
+package secrets
+
+import (
+	"regexp"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/findings"
+)
+
+var SlackWebhookPattern = regexp.MustCompile(`https://hooks\.slack\.com/(services|workflows|triggers)/T[A-Z0-9]+/[AB][A-Z0-9]+/[A-Za-z0-9]{24}\b`)
+
+func init() {
+	RegisterDetector(&SingleTokenDetector{
+		DetectorName:        "slack-webhook",
+		DetectorTitle:       "Hardcoded Slack webhook",
+		DetectorSeverity:    findings.SeverityMedium,
+		DetectorRemediation: "Regenerate the webhook URL, and store it in a Secret or an external secrets manager",
+		TokenType:           TokenType{Name: "Slack webhook URL", Sensitive: true},
+		Match: func(key string, value string) []string {
+			return SlackWebhookPattern.FindAllString(value, -1)
+		},
+	})
+}
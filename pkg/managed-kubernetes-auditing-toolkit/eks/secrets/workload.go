@@ -0,0 +1,182 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/filters"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Label that the Deployment controller adds to its ReplicaSets and their pods
+const podTemplateHashLabel = "pod-template-hash"
+
+// Kinds of workloads whose pod templates are scanned. Secrets found in a pod, or in a ReplicaSet or Job managed by
+// another workload, are attributed to the workload at the top of the chain.
+var workloadKinds = map[string]bool{
+	"Deployment":  true,
+	"StatefulSet": true,
+	"DaemonSet":   true,
+	"ReplicaSet":  true,
+	"Job":         true,
+	"CronJob":     true,
+}
+
+// findCredentialsInWorkloads scans the pod templates of workloads, so that we find secrets of workloads that have no
+// running pods, such as suspended CronJobs or Deployments scaled to zero
+func (m *SecretsDetector) findCredentialsInWorkloads() ([]*SecretInfo, error) {
+	var secrets []*SecretInfo
+	var errs []string
+	scan := func(kind string, listWorkloads func() ([]*SecretInfo, int, error)) {
+		workloadSecrets, numWorkloads, err := listWorkloads()
+		if err != nil {
+			errs = append(errs, err.Error())
+			return
+		}
+		log.Printf("Analyzed %d %ss", numWorkloads, kind)
+		secrets = append(secrets, workloadSecrets...)
+	}
+	scan("Deployment", func() ([]*SecretInfo, int, error) {
+		return scanWorkloads(m, "Deployment", m.DataSource.ListDeployments, func(deployment *appsv1.Deployment) *v1.PodTemplateSpec {
+			return &deployment.Spec.Template
+		})
+	})
+	scan("StatefulSet", func() ([]*SecretInfo, int, error) {
+		return scanWorkloads(m, "StatefulSet", m.DataSource.ListStatefulSets, func(statefulSet *appsv1.StatefulSet) *v1.PodTemplateSpec {
+			return &statefulSet.Spec.Template
+		})
+	})
+	scan("DaemonSet", func() ([]*SecretInfo, int, error) {
+		return scanWorkloads(m, "DaemonSet", m.DataSource.ListDaemonSets, func(daemonSet *appsv1.DaemonSet) *v1.PodTemplateSpec {
+			return &daemonSet.Spec.Template
+		})
+	})
+	scan("CronJob", func() ([]*SecretInfo, int, error) {
+		return scanWorkloads(m, "CronJob", m.DataSource.ListCronJobs, func(cronJob *batchv1.CronJob) *v1.PodTemplateSpec {
+			return &cronJob.Spec.JobTemplate.Spec.Template
+		})
+	})
+	scan("ReplicaSet", func() ([]*SecretInfo, int, error) {
+		return scanWorkloads(m, "ReplicaSet", m.DataSource.ListReplicaSets, func(replicaSet *appsv1.ReplicaSet) *v1.PodTemplateSpec {
+			return &replicaSet.Spec.Template
+		})
+	})
+	scan("Job", func() ([]*SecretInfo, int, error) {
+		return scanWorkloads(m, "Job", m.DataSource.ListJobs, func(job *batchv1.Job) *v1.PodTemplateSpec {
+			return &job.Spec.Template
+		})
+	})
+	if len(errs) > 0 {
+		return secrets, fmt.Errorf("%s", strings.Join(errs, ", "))
+	}
+	return secrets, nil
+}
+
+// scanWorkloads scans the pod templates of all workloads of a kind, page by page
+func scanWorkloads[T any, PT interface {
+	*T
+	metav1.Object
+}](m *SecretsDetector, kind string, list filters.ListFunc[T], podTemplate func(*T) *v1.PodTemplateSpec) ([]*SecretInfo, int, error) {
+	var secrets []*SecretInfo
+	numWorkloads := 0
+	err := filters.List[T, PT](context.Background(), m.Filters, m.namespaces, "", list, func(workloads []T) error {
+		for i := range workloads {
+			workload := PT(&workloads[i])
+			owner := m.ownerOf(workload, kind)
+			if kind == "ReplicaSet" || kind == "Job" {
+				m.owners[ownerKey(kind, workload.GetNamespace(), workload.GetName())] = owner
+			}
+			template := podTemplate(&workloads[i])
+			if !m.Filters.MatchesPodTemplate(workload.GetNamespace(), template) {
+				continue
+			}
+			numWorkloads++
+			secrets = append(secrets, m.findSecretsInPod(owner, workload, &template.Spec)...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to list %ss: %v", kind, err)
+	}
+	return secrets, numWorkloads, nil
+}
+
+// ownerOf returns the workload that secrets found in a resource are attributed to. ReplicaSets and Jobs must be
+// scanned before pods, so that pods can be attributed to the Deployment or CronJob managing their controller.
+func (m *SecretsDetector) ownerOf(object metav1.Object, kind string) scannedResource {
+	resource := scannedResource{Type: kind, Namespace: object.GetNamespace(), Name: object.GetName()}
+	controller := metav1.GetControllerOf(object)
+	if controller == nil || !workloadKinds[controller.Kind] {
+		return resource
+	}
+	if owner, found := m.owners[ownerKey(controller.Kind, object.GetNamespace(), controller.Name)]; found {
+		return owner
+	}
+	// The ReplicaSets of a Deployment may not have been listed, e.g. because of the pod label selector
+	if controller.Kind == "ReplicaSet" {
+		if hash, found := object.GetLabels()[podTemplateHashLabel]; found && strings.HasSuffix(controller.Name, "-"+hash) {
+			return scannedResource{Type: "Deployment", Namespace: object.GetNamespace(), Name: strings.TrimSuffix(controller.Name, "-"+hash)}
+		}
+	}
+	return scannedResource{Type: controller.Kind, Namespace: object.GetNamespace(), Name: controller.Name}
+}
+
+// findSecretsInPod scans the pod spec of a pod or workload, and the last applied configuration of the object
+func (m *SecretsDetector) findSecretsInPod(resource scannedResource, object metav1.Object, spec *v1.PodSpec) []*SecretInfo {
+	secrets := findSecretsInPodSpec(resource, spec, m, m.Detectors)
+	return append(secrets, findSecretsInLastAppliedConfiguration(resource, object, m.Detectors)...)
+}
+
+// getConfigMap retrieves ConfigMaps that containers take environment variables or files from, only once each
+func (m *SecretsDetector) getConfigMap(namespace string, name string) *v1.ConfigMap {
+	key := namespace + "/" + name
+	if configMap, found := m.configMaps[key]; found {
+		return configMap
+	}
+	configMap, err := m.DataSource.GetConfigMap(context.Background(), namespace, name)
+	if err != nil {
+		m.incomplete = true
+		log.Printf("[WARN] Unable to retrieve the ConfigMap %s: %v", key, err)
+	}
+	m.configMaps[key] = configMap
+	return configMap
+}
+
+// getSecret retrieves Secrets that containers take environment variables or files from, only once each
+func (m *SecretsDetector) getSecret(namespace string, name string) *v1.Secret {
+	key := namespace + "/" + name
+	if secret, found := m.secrets[key]; found {
+		return secret
+	}
+	secret, err := m.DataSource.GetSecret(context.Background(), namespace, name)
+	if err != nil {
+		m.incomplete = true
+		log.Printf("[WARN] Unable to retrieve the Secret %s: %v", key, err)
+	}
+	m.secrets[key] = secret
+	return secret
+}
+
+func ownerKey(kind string, namespace string, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
+
+// deduplicateSecrets removes secrets found several times in the same resource, such as in a Deployment and in each
+// of its pods
+func deduplicateSecrets(secrets []*SecretInfo) []*SecretInfo {
+	var deduplicated []*SecretInfo
+	seen := map[string]bool{}
+	for _, secret := range secrets {
+		key := strings.Join([]string{secret.Namespace, secret.Type, secret.ResourceName, secret.Location, secret.Detector, secret.TokenType, secret.Value}, "\x00")
+		if !seen[key] {
+			seen[key] = true
+			deduplicated = append(deduplicated, secret)
+		}
+	}
+	return deduplicated
+}
@@ -0,0 +1,371 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/datasource"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/filters"
+	"github.com/hashicorp/go-version"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// https://docs.aws.amazon.com/eks/latest/userguide/pod-identities.html#pod-id-cluster-versions
+const PodIdentityMinSupportedK8sVersion = "1.24"
+
+// Annotation set by "kubectl apply", containing a copy of the whole resource. find-secrets scans it, so it's only
+// removed from the resources that aren't scanned for secrets.
+const lastAppliedConfigurationAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+const (
+	PodIdentityAgentAddonName     = "eks-pod-identity-agent"
+	PodIdentityAgentDaemonSetName = "eks-pod-identity-agent"
+	PodIdentityAgentNamespace     = "kube-system"
+)
+
+// Collector retrieves a snapshot of an EKS cluster and its AWS account from a set of data sources
+type Collector struct {
+	DataSources *datasource.DataSources
+	ClusterName string
+	Filters     *filters.Filters // optional, restricts the Kubernetes resources and IAM roles to collect
+
+	IncludeConfigMaps bool
+	IncludeSecrets    bool
+	IncludeWorkloads  bool
+
+	Stats *CollectionStats // set by Collect
+}
+
+// CollectionStats describes how long the collection took, and how many API calls it needed
+type CollectionStats struct {
+	Duration      time.Duration
+	StepDurations map[string]time.Duration
+	APICalls      *datasource.APICallStats // nil if the data sources don't make API calls
+}
+
+// collectionStep retrieves one part of the snapshot. Steps run concurrently, and each one writes distinct fields.
+type collectionStep struct {
+	Name     string
+	Required bool // if false, failures are recorded as warnings in the snapshot
+	Run      func(ctx context.Context) error
+}
+
+// Collect retrieves all the data needed to analyze the cluster. Optional data that cannot be retrieved (such as
+// Fargate profiles) is recorded as a warning in the snapshot instead of failing the collection.
+func (m *Collector) Collect(ctx context.Context) (*Snapshot, error) {
+	startTime := time.Now()
+	m.Stats = &CollectionStats{StepDurations: map[string]time.Duration{}, APICalls: m.DataSources.Stats}
+	snapshot := &Snapshot{
+		FormatVersion: FormatVersion,
+		CollectedAt:   startTime.UTC(),
+		ClusterName:   m.ClusterName,
+		Filters:       m.Filters,
+	}
+	var err error
+
+	// The cluster version determines what else we need to collect
+	if snapshot.Cluster, err = m.DescribeCluster(ctx); err != nil {
+		return nil, fmt.Errorf("unable to retrieve EKS cluster information: %v", err)
+	}
+	m.Stats.StepDurations["cluster"] = time.Since(startTime)
+
+	// Resolve namespace patterns once, so that all list calls only target matching namespaces
+	namespaces, err := m.Filters.ResolveNamespaces(ctx, m.DataSources.Kubernetes)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.runSteps(ctx, snapshot, m.collectionSteps(snapshot, namespaces)); err != nil {
+		return nil, err
+	}
+	if dataSource, ok := m.DataSources.Kubernetes.(interface{ SkippedNamespaces() bool }); ok && dataSource.SkippedNamespaces() {
+		snapshot.addWarning("Not allowed to list Kubernetes resources in some namespaces, they are missing from the snapshot")
+	}
+
+	m.Stats.Duration = time.Since(startTime)
+	log.Println(m.Stats.Summary())
+	return snapshot, nil
+}
+
+func (m *Collector) collectionSteps(snapshot *Snapshot, namespaces []string) []*collectionStep {
+	namespacesDescription := m.namespacesDescription(namespaces)
+	steps := []*collectionStep{
+		{Name: "Fargate profiles", Run: func(ctx context.Context) (err error) {
+			log.Println("Listing Fargate profiles of the cluster")
+			snapshot.FargateProfiles, err = m.DataSources.Cluster.ListFargateProfiles(ctx, m.ClusterName)
+			return err
+		}},
+		{Name: "service accounts", Required: true, Run: func(ctx context.Context) (err error) {
+			log.Println("Listing K8s service accounts in " + namespacesDescription)
+			snapshot.ServiceAccounts, err = listFiltered(ctx, m.Filters, namespaces, m.serviceAccountLabelSelector(), false, m.DataSources.Kubernetes.ListServiceAccounts)
+			return err
+		}},
+		{Name: "pods", Required: true, Run: func(ctx context.Context) (err error) {
+			log.Println("Listing K8s pods in " + namespacesDescription)
+			snapshot.Pods, err = listFiltered(ctx, m.Filters, namespaces, m.podLabelSelector(), m.scansSecrets(), m.DataSources.Kubernetes.ListPods)
+			return err
+		}},
+		{Name: "IAM roles", Required: true, Run: func(ctx context.Context) (err error) {
+			log.Println("Listing roles in the AWS account")
+			roles, err := m.DataSources.IAM.ListRoles(ctx)
+			if err != nil {
+				return err
+			}
+			snapshot.IAMRoles = filterItems(roles, func(role **IAMRole) bool { return m.Filters.MatchesRole((*role).Arn) })
+			log.Printf("Found %d IAM roles in the AWS account", len(snapshot.IAMRoles))
+			return nil
+		}},
+	}
+
+	if supportsPodIdentity(*snapshot.Cluster.Version) {
+		steps = append(steps,
+			&collectionStep{Name: "Pod Identity associations", Required: true, Run: func(ctx context.Context) (err error) {
+				log.Println("Listing Pod Identity associations of the cluster")
+				associations, err := m.DataSources.Cluster.ListPodIdentityAssociations(ctx, m.ClusterName)
+				snapshot.PodIdentityAssociations = filterItems(associations, func(association **PodIdentityAssociation) bool {
+					return m.Filters.MatchesPodIdentityAssociation((*association).Namespace, (*association).RoleArn)
+				})
+				return err
+			}},
+			&collectionStep{Name: "the status of the EKS Pod Identity Agent", Run: func(ctx context.Context) (err error) {
+				snapshot.PodIdentityAgent, err = m.collectPodIdentityAgent(ctx)
+				return err
+			}},
+		)
+	}
+
+	if m.IncludeConfigMaps {
+		steps = append(steps, &collectionStep{Name: "ConfigMaps", Run: func(ctx context.Context) (err error) {
+			log.Println("Listing K8s ConfigMaps in " + namespacesDescription)
+			snapshot.ConfigMaps, err = listFiltered(ctx, m.Filters, namespaces, "", true, m.DataSources.Kubernetes.ListConfigMaps)
+			return err
+		}})
+	}
+
+	if m.IncludeWorkloads {
+		steps = append(steps, &collectionStep{Name: "workloads", Run: func(ctx context.Context) (err error) {
+			log.Println("Listing K8s workloads in " + namespacesDescription)
+			snapshot.Workloads, err = m.collectWorkloads(ctx, namespaces)
+			return err
+		}})
+	}
+
+	if m.IncludeSecrets {
+		steps = append(steps, &collectionStep{Name: "Secrets", Run: func(ctx context.Context) (err error) {
+			log.Println("Listing K8s Secrets in " + namespacesDescription)
+			snapshot.Secrets, err = listFiltered(ctx, m.Filters, namespaces, "", true, m.DataSources.Kubernetes.ListSecrets)
+			return err
+		}})
+	}
+	return steps
+}
+
+// runSteps runs all steps concurrently. The failure of a required step cancels the other ones.
+func (m *Collector) runSteps(ctx context.Context, snapshot *Snapshot, steps []*collectionStep) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stepErrors := make([]error, len(steps))
+	stepDurations := make([]time.Duration, len(steps))
+	var wg sync.WaitGroup
+	for i, step := range steps {
+		wg.Add(1)
+		go func(i int, step *collectionStep) {
+			defer wg.Done()
+			stepStartTime := time.Now()
+			stepErrors[i] = step.Run(ctx)
+			stepDurations[i] = time.Since(stepStartTime)
+			if stepErrors[i] != nil && step.Required {
+				cancel()
+			}
+		}(i, step)
+	}
+	wg.Wait()
+
+	// Process results in a deterministic order
+	for i, step := range steps {
+		m.Stats.StepDurations[step.Name] = stepDurations[i]
+		if stepErrors[i] != nil && step.Required {
+			return fmt.Errorf("unable to retrieve %s: %v", step.Name, stepErrors[i])
+		}
+	}
+	for i, step := range steps {
+		if stepErrors[i] != nil {
+			snapshot.addWarning("Unable to retrieve " + step.Name + ": " + stepErrors[i].Error())
+		}
+	}
+	return nil
+}
+
+// Summary returns a human-readable summary of the collection
+func (m *CollectionStats) Summary() string {
+	stepNames := make([]string, 0, len(m.StepDurations))
+	for name := range m.StepDurations {
+		stepNames = append(stepNames, name)
+	}
+	sort.Strings(stepNames)
+	stepSummaries := make([]string, 0, len(stepNames))
+	for _, name := range stepNames {
+		stepSummaries = append(stepSummaries, fmt.Sprintf("%s: %s", name, m.StepDurations[name].Round(time.Millisecond)))
+	}
+	summary := fmt.Sprintf("Collected cluster data in %s (%s)", m.Duration.Round(time.Millisecond), strings.Join(stepSummaries, ", "))
+	if m.APICalls != nil {
+		summary += fmt.Sprintf(" using %d API calls: %s", m.APICalls.TotalCalls(), m.APICalls.String())
+	}
+	return summary
+}
+
+func (m *Snapshot) addWarning(warning string) {
+	log.Println("[WARNING] " + warning)
+	m.Warnings = append(m.Warnings, warning)
+}
+
+// DescribeCluster retrieves the raw EKS cluster information
+func (m *Collector) DescribeCluster(ctx context.Context) (*types.Cluster, error) {
+	log.Println("Retrieving cluster information")
+	return m.DataSources.Cluster.DescribeCluster(ctx, m.ClusterName)
+}
+
+func (m *Collector) collectPodIdentityAgent(ctx context.Context) (*PodIdentityAgent, error) {
+	log.Println("Retrieving the status of the EKS Pod Identity Agent")
+	addon, err := m.DataSources.Cluster.DescribeAddon(ctx, m.ClusterName, PodIdentityAgentAddonName)
+	if err != nil {
+		return nil, err
+	}
+
+	// The agent can also be self-managed, so we look at the DaemonSet regardless of the add-on status
+	daemonSet, err := m.DataSources.Kubernetes.GetDaemonSet(ctx, PodIdentityAgentNamespace, PodIdentityAgentDaemonSetName)
+	if err != nil {
+		return nil, err
+	}
+	if daemonSet == nil || daemonSet.Spec.Selector == nil {
+		return &PodIdentityAgent{Addon: addon, DaemonSet: daemonSet}, nil
+	}
+
+	// Only retrieve the pods of the agent, regardless of the namespaces we collect
+	selector, err := metav1.LabelSelectorAsSelector(daemonSet.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector for DaemonSet %s: %v", PodIdentityAgentDaemonSetName, err)
+	}
+	agentPods, err := listFiltered(ctx, nil, []string{daemonSet.Namespace}, selector.String(), false, m.DataSources.Kubernetes.ListPods)
+	if err != nil {
+		return nil, err
+	}
+	return &PodIdentityAgent{Addon: addon, DaemonSet: daemonSet, Pods: agentPods}, nil
+}
+
+// listFiltered retrieves Kubernetes resources page by page, and trims them to reduce the memory they use. The
+// last-applied configuration of resources scanned for secrets is kept.
+func listFiltered[T any, PT interface {
+	*T
+	metav1.Object
+}](ctx context.Context, filter *filters.Filters, namespaces []string, labelSelector string, keepLastAppliedConfiguration bool, list filters.ListFunc[T]) ([]T, error) {
+	var items []T
+	err := filters.List[T, PT](ctx, filter, namespaces, labelSelector, list, func(page []T) error {
+		for i := range page {
+			trimObjectMeta(PT(&page[i]), keepLastAppliedConfiguration)
+		}
+		items = append(items, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// trimObjectMeta removes metadata that can make up most of the size of a resource: managed fields, which no analysis
+// uses, and the last-applied configuration unless the resource is scanned for secrets
+func trimObjectMeta(object metav1.Object, keepLastAppliedConfiguration bool) {
+	object.SetManagedFields(nil)
+	annotations := object.GetAnnotations()
+	if _, found := annotations[lastAppliedConfigurationAnnotation]; !found || keepLastAppliedConfiguration {
+		return
+	}
+	// Data sources may share annotations with other copies of the resource, so we don't modify them in place
+	trimmedAnnotations := make(map[string]string, len(annotations)-1)
+	for key, value := range annotations {
+		if key != lastAppliedConfigurationAnnotation {
+			trimmedAnnotations[key] = value
+		}
+	}
+	object.SetAnnotations(trimmedAnnotations)
+}
+
+// scansSecrets determines if the snapshot is collected to find secrets, in which case pods are scanned too
+func (m *Collector) scansSecrets() bool {
+	return m.IncludeConfigMaps || m.IncludeSecrets || m.IncludeWorkloads
+}
+
+func (m *Collector) namespacesDescription(namespaces []string) string {
+	description := "namespaces " + strings.Join(namespaces, ", ")
+	if namespaces == nil {
+		description = "all namespaces"
+	} else if len(namespaces) == 0 {
+		description = "no namespace (none matches the namespace filters)"
+	}
+	if m.Filters != nil && len(m.Filters.ExcludeNamespaces) > 0 {
+		description += " except " + strings.Join(m.Filters.ExcludeNamespaces, ", ")
+	}
+	return description
+}
+
+func (m *Collector) podLabelSelector() string {
+	if m.Filters == nil {
+		return ""
+	}
+	return m.Filters.PodLabelSelector
+}
+
+func (m *Collector) serviceAccountLabelSelector() string {
+	if m.Filters == nil {
+		return ""
+	}
+	return m.Filters.ServiceAccountLabelSelector
+}
+
+func supportsPodIdentity(kubernetesVersion string) bool {
+	currentVersion, err := version.NewVersion(kubernetesVersion)
+	minimumVersion, _ := version.NewVersion(PodIdentityMinSupportedK8sVersion)
+	if err != nil {
+		log.Println("[WARNING] Unable to parse cluster K8s version, assuming it's >= 1.24 and supports Pod Identity")
+		return true
+	}
+	if !currentVersion.GreaterThanOrEqual(minimumVersion) {
+		log.Println("Your cluster runs a Kubernetes version that does not support Pod Identity - skipping")
+		log.Println("Your K8s version is " + kubernetesVersion + ", and Pod Identity is supported starting from " + PodIdentityMinSupportedK8sVersion)
+		return false
+	}
+	return true
+}
+
+// collectWorkloads lists the controllers of pods. Workloads are only kept when all of them could be listed.
+func (m *Collector) collectWorkloads(ctx context.Context, namespaces []string) (workloads *Workloads, err error) {
+	workloads = &Workloads{}
+	kubernetes := m.DataSources.Kubernetes
+	if workloads.Deployments, err = listFiltered(ctx, m.Filters, namespaces, "", true, kubernetes.ListDeployments); err != nil {
+		return nil, err
+	}
+	if workloads.StatefulSets, err = listFiltered(ctx, m.Filters, namespaces, "", true, kubernetes.ListStatefulSets); err != nil {
+		return nil, err
+	}
+	if workloads.DaemonSets, err = listFiltered(ctx, m.Filters, namespaces, "", true, kubernetes.ListDaemonSets); err != nil {
+		return nil, err
+	}
+	if workloads.ReplicaSets, err = listFiltered(ctx, m.Filters, namespaces, "", true, kubernetes.ListReplicaSets); err != nil {
+		return nil, err
+	}
+	if workloads.Jobs, err = listFiltered(ctx, m.Filters, namespaces, "", true, kubernetes.ListJobs); err != nil {
+		return nil, err
+	}
+	if workloads.CronJobs, err = listFiltered(ctx, m.Filters, namespaces, "", true, kubernetes.ListCronJobs); err != nil {
+		return nil, err
+	}
+	return workloads, nil
+}
@@ -0,0 +1,279 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/datasource"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/filters"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func testDataSource(kubernetesVersion string) *datasource.InMemory {
+	return &datasource.InMemory{
+		Clusters: []*types.Cluster{{Name: aws.String("my-cluster"), Version: aws.String(kubernetesVersion)}},
+		PodIdentityAssociations: map[string][]*datasource.PodIdentityAssociation{"my-cluster": {
+			{ID: "a-1", Namespace: "default", ServiceAccountName: "sa", RoleArn: "arn:aws:iam::111111111111:role/role"},
+		}},
+		Addons:          map[string][]*types.Addon{"my-cluster": {{AddonName: aws.String(PodIdentityAgentAddonName)}}},
+		IAMRoles:        []*datasource.IAMRole{{Arn: "arn:aws:iam::111111111111:role/role", TrustPolicy: "{}"}},
+		ServiceAccounts: []corev1.ServiceAccount{{ObjectMeta: metav1.ObjectMeta{Name: "sa", Namespace: "default"}}},
+		Pods:            []corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default"}}},
+		ConfigMaps:      []corev1.ConfigMap{{ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "default"}}},
+		Secrets:         []corev1.Secret{{ObjectMeta: metav1.ObjectMeta{Name: "secret", Namespace: "default"}}},
+		DaemonSets: []appsv1.DaemonSet{
+			{ObjectMeta: metav1.ObjectMeta{Name: PodIdentityAgentDaemonSetName, Namespace: PodIdentityAgentNamespace}},
+		},
+	}
+}
+
+func TestCollectsSnapshotFromDataSources(t *testing.T) {
+	collector := Collector{DataSources: testDataSource("1.28").DataSources(), ClusterName: "my-cluster", IncludeConfigMaps: true}
+	snapshot, err := collector.Collect(context.Background())
+	assert.Nil(t, err)
+
+	assert.Equal(t, FormatVersion, snapshot.FormatVersion)
+	assert.Equal(t, "my-cluster", *snapshot.Cluster.Name)
+	assert.Len(t, snapshot.IAMRoles, 1)
+	assert.Len(t, snapshot.PodIdentityAssociations, 1)
+	assert.NotNil(t, snapshot.PodIdentityAgent.Addon)
+	assert.NotNil(t, snapshot.PodIdentityAgent.DaemonSet)
+	assert.Len(t, snapshot.ConfigMaps, 1)
+	assert.Len(t, snapshot.Secrets, 0, "secrets should only be collected when explicitly requested")
+	assert.Empty(t, snapshot.Warnings)
+}
+
+func TestCollectorSkipsPodIdentityOnOldClusters(t *testing.T) {
+	collector := Collector{DataSources: testDataSource("1.23").DataSources(), ClusterName: "my-cluster"}
+	snapshot, err := collector.Collect(context.Background())
+	assert.Nil(t, err)
+	assert.Empty(t, snapshot.PodIdentityAssociations)
+	assert.Nil(t, snapshot.PodIdentityAgent)
+}
+
+func TestCollectorFailsOnUnknownCluster(t *testing.T) {
+	collector := Collector{DataSources: testDataSource("1.28").DataSources(), ClusterName: "other-cluster"}
+	_, err := collector.Collect(context.Background())
+	assert.NotNil(t, err)
+}
+
+func TestSnapshotDataSourcesServeSnapshotContent(t *testing.T) {
+	collector := Collector{DataSources: testDataSource("1.28").DataSources(), ClusterName: "my-cluster", IncludeConfigMaps: true, IncludeSecrets: true, IncludeWorkloads: true}
+	snapshot, err := collector.Collect(context.Background())
+	assert.Nil(t, err)
+	assert.NotNil(t, snapshot.Workloads)
+	assert.Len(t, snapshot.Workloads.DaemonSets, 1)
+
+	// Collecting again from the snapshot itself should give the same result
+	recollector := Collector{DataSources: snapshot.DataSources(), ClusterName: "my-cluster", IncludeConfigMaps: true, IncludeSecrets: true, IncludeWorkloads: true}
+	recollected, err := recollector.Collect(context.Background())
+	assert.Nil(t, err)
+	recollected.CollectedAt = snapshot.CollectedAt
+	assert.Equal(t, snapshot, recollected)
+}
+
+func TestSnapshotDataSourcesWithUnknownPodIdentityAgent(t *testing.T) {
+	collector := Collector{DataSources: testDataSource("1.28").DataSources(), ClusterName: "my-cluster"}
+	snapshot, err := collector.Collect(context.Background())
+	assert.Nil(t, err)
+	snapshot.PodIdentityAgent = nil
+
+	recollector := Collector{DataSources: snapshot.DataSources(), ClusterName: "my-cluster"}
+	recollected, err := recollector.Collect(context.Background())
+	assert.Nil(t, err)
+	assert.Nil(t, recollected.PodIdentityAgent)
+	assert.Len(t, recollected.Warnings, 1)
+}
+
+type failingDataSource struct {
+	*datasource.InMemory
+	failFargate bool
+	failIAM     bool
+}
+
+func (m *failingDataSource) ListFargateProfiles(ctx context.Context, clusterName string) ([]*types.FargateProfile, error) {
+	if m.failFargate {
+		return nil, errors.New("access denied")
+	}
+	return m.InMemory.ListFargateProfiles(ctx, clusterName)
+}
+
+func (m *failingDataSource) ListRoles(ctx context.Context) ([]*datasource.IAMRole, error) {
+	if m.failIAM {
+		return nil, errors.New("access denied")
+	}
+	return m.InMemory.ListRoles(ctx)
+}
+
+func TestCollectorHandlesFailures(t *testing.T) {
+	scenarios := []struct {
+		Name             string
+		FailFargate      bool
+		FailIAM          bool
+		ExpectError      bool
+		ExpectedWarnings []string
+	}{
+		{Name: "no failure"},
+		{Name: "optional data", FailFargate: true, ExpectedWarnings: []string{"Unable to retrieve Fargate profiles: access denied"}},
+		{Name: "required data", FailIAM: true, ExpectError: true},
+		{Name: "required and optional data", FailFargate: true, FailIAM: true, ExpectError: true},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.Name, func(t *testing.T) {
+			dataSource := &failingDataSource{InMemory: testDataSource("1.28"), failFargate: scenario.FailFargate, failIAM: scenario.FailIAM}
+			collector := Collector{
+				DataSources: &datasource.DataSources{Cluster: dataSource, IAM: dataSource, Kubernetes: dataSource},
+				ClusterName: "my-cluster",
+			}
+			snapshot, err := collector.Collect(context.Background())
+			if scenario.ExpectError {
+				assert.EqualError(t, err, "unable to retrieve IAM roles: access denied")
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, scenario.ExpectedWarnings, snapshot.Warnings)
+		})
+	}
+}
+
+func TestCollectorRecordsStats(t *testing.T) {
+	collector := Collector{DataSources: testDataSource("1.28").DataSources(), ClusterName: "my-cluster"}
+	_, err := collector.Collect(context.Background())
+	assert.Nil(t, err)
+
+	assert.Nil(t, collector.Stats.APICalls)
+	for _, step := range []string{"cluster", "Fargate profiles", "service accounts", "pods", "IAM roles", "Pod Identity associations"} {
+		assert.Contains(t, collector.Stats.StepDurations, step)
+	}
+	assert.Contains(t, collector.Stats.Summary(), "Collected cluster data in ")
+}
+
+func TestCollectorHonorsCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("no API call should be made once the context is cancelled")
+	}))
+	defer server.Close()
+
+	dataSources := datasource.NewLiveDataSources(&aws.Config{
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("AKIA", "secret", ""),
+		BaseEndpoint: aws.String(server.URL),
+	}, fake.NewSimpleClientset())
+	collector := Collector{DataSources: dataSources, ClusterName: "my-cluster"}
+	_, err := collector.Collect(ctx)
+	assert.ErrorContains(t, err, "context canceled")
+}
+
+func TestCollectorRestrictsNamespaces(t *testing.T) {
+	dataSource := testDataSource("1.28")
+	dataSource.Pods = []corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "app", Annotations: map[string]string{
+			lastAppliedConfigurationAnnotation: `{"huge": "json"}`,
+			"keep":                             "me",
+		}, ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubectl"}}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "other"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: PodIdentityAgentNamespace, Labels: map[string]string{"app": "agent"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: PodIdentityAgentNamespace}},
+	}
+	dataSource.DaemonSets[0].Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "agent"}}
+
+	collector := Collector{DataSources: dataSource.DataSources(), ClusterName: "my-cluster", Filters: &filters.Filters{IncludeNamespaces: []string{"app"}}}
+	snapshot, err := collector.Collect(context.Background())
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"app"}, snapshot.Filters.IncludeNamespaces)
+	assert.Len(t, snapshot.Pods, 1)
+	assert.Equal(t, "app", snapshot.Pods[0].Name)
+	assert.Empty(t, snapshot.ServiceAccounts)
+
+	// Pods are trimmed to reduce memory usage, including their last-applied configuration when secrets aren't scanned
+	assert.Nil(t, snapshot.Pods[0].ManagedFields)
+	assert.Equal(t, map[string]string{"keep": "me"}, snapshot.Pods[0].Annotations)
+	assert.Len(t, dataSource.Pods[0].Annotations, 2, "the data source should not be modified")
+
+	// Agent pods are collected regardless of the namespaces
+	assert.Len(t, snapshot.PodIdentityAgent.Pods, 1)
+	assert.Equal(t, "agent", snapshot.PodIdentityAgent.Pods[0].Name)
+}
+
+func TestCollectorFilters(t *testing.T) {
+	dataSource := testDataSource("1.28")
+	dataSource.Pods = []corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team-a", Labels: map[string]string{"app": "web"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "team-a", Labels: map[string]string{"app": "worker"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team-b", Labels: map[string]string{"app": "web"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team-b-staging", Labels: map[string]string{"app": "web"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "other", Labels: map[string]string{"app": "web"}}},
+	}
+	dataSource.IAMRoles = []*IAMRole{
+		{Arn: "arn:aws:iam::123456789012:role/app-role"},
+		{Arn: "arn:aws:iam::123456789012:role/admin-role"},
+	}
+	dataSource.PodIdentityAssociations["my-cluster"] = []*PodIdentityAssociation{
+		{ID: "a", Namespace: "team-a", ServiceAccountName: "sa", RoleArn: "arn:aws:iam::123456789012:role/app-role"},
+		{ID: "b", Namespace: "team-a", ServiceAccountName: "sa", RoleArn: "arn:aws:iam::123456789012:role/admin-role"},
+		{ID: "c", Namespace: "other", ServiceAccountName: "sa", RoleArn: "arn:aws:iam::123456789012:role/app-role"},
+	}
+
+	collector := Collector{DataSources: dataSource.DataSources(), ClusterName: "my-cluster", Filters: &filters.Filters{
+		IncludeNamespaces: []string{"team-*"},
+		ExcludeNamespaces: []string{"*-staging"},
+		PodLabelSelector:  "app=web",
+		ExcludeRoles:      []string{"^admin-"},
+	}}
+	snapshot, err := collector.Collect(context.Background())
+	assert.Nil(t, err)
+
+	var pods []string
+	for _, pod := range snapshot.Pods {
+		pods = append(pods, pod.Namespace+"/"+pod.Name)
+	}
+	assert.Equal(t, []string{"team-a/web", "team-b/web"}, pods)
+	assert.Len(t, snapshot.IAMRoles, 1)
+	assert.Equal(t, "arn:aws:iam::123456789012:role/app-role", snapshot.IAMRoles[0].Arn)
+	assert.Len(t, snapshot.PodIdentityAssociations, 1)
+	assert.Equal(t, "a", snapshot.PodIdentityAssociations[0].ID)
+}
+
+func TestSnapshotFilter(t *testing.T) {
+	snapshot := &Snapshot{
+		ServiceAccounts: []corev1.ServiceAccount{
+			{ObjectMeta: metav1.ObjectMeta{Name: "sa", Namespace: "app", Labels: map[string]string{"team": "a"}}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "other-sa", Namespace: "app"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "sa", Namespace: "other", Labels: map[string]string{"team": "a"}}},
+		},
+		Pods: []corev1.Pod{
+			{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "app"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "other"}},
+		},
+		IAMRoles: []*IAMRole{
+			{Arn: "arn:aws:iam::123456789012:role/app-role"},
+			{Arn: "arn:aws:iam::123456789012:role/admin-role"},
+		},
+	}
+	assert.Equal(t, snapshot, snapshot.Filter(nil))
+	assert.Equal(t, snapshot, snapshot.Filter(&filters.Filters{}))
+
+	filter := &filters.Filters{IncludeNamespaces: []string{"app"}, ServiceAccountLabelSelector: "team=a", IncludeRoles: []string{"app-"}}
+	filtered := snapshot.Filter(filter)
+	assert.Equal(t, filter, filtered.Filters)
+	assert.Len(t, filtered.ServiceAccounts, 1)
+	assert.Equal(t, "sa", filtered.ServiceAccounts[0].Name)
+	assert.Len(t, filtered.Pods, 1)
+	assert.Equal(t, "app", filtered.Pods[0].Namespace)
+	assert.Len(t, filtered.IAMRoles, 1)
+	assert.Len(t, snapshot.Pods, 2, "the original snapshot should not be modified")
+}
@@ -0,0 +1,136 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/datasource"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/filters"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// FormatVersion is the version of the snapshot file format. It's incremented on every breaking change.
+const FormatVersion = 1
+
+// Snapshot contains everything MKAT analyses need about an EKS cluster and its AWS account, so that they can run
+// offline and produce reproducible results
+type Snapshot struct {
+	FormatVersion int              `json:"formatVersion"`
+	CollectedAt   time.Time        `json:"collectedAt"`
+	ClusterName   string           `json:"clusterName"`
+	Filters       *filters.Filters `json:"filters,omitempty"` // filters the snapshot was collected with, nil for everything
+
+	// AWS data
+	Cluster                 *types.Cluster            `json:"cluster"` // raw DescribeCluster response
+	FargateProfiles         []*types.FargateProfile   `json:"fargateProfiles"`
+	PodIdentityAssociations []*PodIdentityAssociation `json:"podIdentityAssociations"`
+	IAMRoles                []*IAMRole                `json:"iamRoles"`
+
+	// Kubernetes data
+	ServiceAccounts  []corev1.ServiceAccount `json:"serviceAccounts"`
+	Pods             []corev1.Pod            `json:"pods"`
+	PodIdentityAgent *PodIdentityAgent       `json:"podIdentityAgent,omitempty"` // nil if its status could not be determined
+	ConfigMaps       []corev1.ConfigMap      `json:"configMaps,omitempty"`
+	Secrets          []corev1.Secret         `json:"secrets,omitempty"`
+	Workloads        *Workloads              `json:"workloads,omitempty"` // nil if workloads weren't collected
+
+	// Parts of the snapshot that could not be collected, e.g. because of missing permissions
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+type IAMRole = datasource.IAMRole
+
+// Workloads are the controllers of pods, whose pod templates find-secrets scans
+type Workloads struct {
+	Deployments  []appsv1.Deployment  `json:"deployments,omitempty"`
+	StatefulSets []appsv1.StatefulSet `json:"statefulSets,omitempty"`
+	DaemonSets   []appsv1.DaemonSet   `json:"daemonSets,omitempty"`
+	ReplicaSets  []appsv1.ReplicaSet  `json:"replicaSets,omitempty"`
+	Jobs         []batchv1.Job        `json:"jobs,omitempty"`
+	CronJobs     []batchv1.CronJob    `json:"cronJobs,omitempty"`
+}
+
+// PodIdentityAgent describes how the EKS Pod Identity Agent is installed in the cluster
+type PodIdentityAgent struct {
+	Addon     *types.Addon      `json:"addon,omitempty"`     // nil if the add-on is not installed
+	DaemonSet *appsv1.DaemonSet `json:"daemonSet,omitempty"` // nil if the DaemonSet does not exist
+
+	// Pods of the DaemonSet. They're collected separately, since the snapshot may not include the namespace of the
+	// agent. When it does, they're also part of the snapshot pods.
+	Pods []corev1.Pod `json:"pods,omitempty"`
+}
+
+type PodIdentityAssociation = datasource.PodIdentityAssociation
+
+// Load reads a snapshot previously written with Save
+func Load(path string) (*Snapshot, error) {
+	rawSnapshot, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read snapshot file %s: %v", path, err)
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(rawSnapshot, &snapshot); err != nil {
+		return nil, fmt.Errorf("unable to parse snapshot file %s: %v", path, err)
+	}
+	if snapshot.FormatVersion < 1 || snapshot.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("unsupported snapshot format version %d (this version of MKAT supports version %d)", snapshot.FormatVersion, FormatVersion)
+	}
+	if snapshot.Cluster == nil {
+		return nil, fmt.Errorf("snapshot file %s does not contain any cluster information", path)
+	}
+	return &snapshot, nil
+}
+
+// Filter returns a copy of the snapshot only containing the Kubernetes resources and IAM roles matching filters.
+// The Pod Identity Agent is kept regardless of the filters, since it serves all namespaces.
+func (m *Snapshot) Filter(filter *filters.Filters) *Snapshot {
+	if filter.IsEmpty() {
+		return m
+	}
+	filtered := *m
+	filtered.Filters = filter
+	filtered.IAMRoles = filterItems(m.IAMRoles, func(role **IAMRole) bool { return filter.MatchesRole((*role).Arn) })
+	filtered.PodIdentityAssociations = filterItems(m.PodIdentityAssociations, func(association **PodIdentityAssociation) bool {
+		return filter.MatchesPodIdentityAssociation((*association).Namespace, (*association).RoleArn)
+	})
+	filtered.ServiceAccounts = filterItems(m.ServiceAccounts, filter.MatchesServiceAccount)
+	filtered.Pods = filterItems(m.Pods, filter.MatchesPod)
+	filtered.ConfigMaps = filterItems(m.ConfigMaps, func(configMap *corev1.ConfigMap) bool { return filter.MatchesNamespace(configMap.Namespace) })
+	filtered.Secrets = filterItems(m.Secrets, func(secret *corev1.Secret) bool { return filter.MatchesNamespace(secret.Namespace) })
+	if m.Workloads != nil {
+		filtered.Workloads = &Workloads{
+			Deployments:  filterItems(m.Workloads.Deployments, func(deployment *appsv1.Deployment) bool { return filter.MatchesNamespace(deployment.Namespace) }),
+			StatefulSets: filterItems(m.Workloads.StatefulSets, func(statefulSet *appsv1.StatefulSet) bool { return filter.MatchesNamespace(statefulSet.Namespace) }),
+			DaemonSets:   filterItems(m.Workloads.DaemonSets, func(daemonSet *appsv1.DaemonSet) bool { return filter.MatchesNamespace(daemonSet.Namespace) }),
+			ReplicaSets:  filterItems(m.Workloads.ReplicaSets, func(replicaSet *appsv1.ReplicaSet) bool { return filter.MatchesNamespace(replicaSet.Namespace) }),
+			Jobs:         filterItems(m.Workloads.Jobs, func(job *batchv1.Job) bool { return filter.MatchesNamespace(job.Namespace) }),
+			CronJobs:     filterItems(m.Workloads.CronJobs, func(cronJob *batchv1.CronJob) bool { return filter.MatchesNamespace(cronJob.Namespace) }),
+		}
+	}
+	return &filtered
+}
+
+func filterItems[T any](items []T, keep func(*T) bool) []T {
+	var filtered []T
+	for i := range items {
+		if keep(&items[i]) {
+			filtered = append(filtered, items[i])
+		}
+	}
+	return filtered
+}
+
+// Save writes the snapshot to a JSON file
+func (m *Snapshot) Save(path string) error {
+	rawSnapshot, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to serialize snapshot: %v", err)
+	}
+	// The snapshot may contain sensitive data, such as environment variables of pods
+	return os.WriteFile(path, rawSnapshot, 0600)
+}
@@ -0,0 +1,71 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	snapshot := &Snapshot{
+		FormatVersion: FormatVersion,
+		CollectedAt:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		ClusterName:   "my-cluster",
+		Cluster: &types.Cluster{
+			Name:    aws.String("my-cluster"),
+			Arn:     aws.String("arn:aws:eks:us-east-1:111111111111:cluster/my-cluster"),
+			Version: aws.String("1.28"),
+		},
+		IAMRoles: []*IAMRole{{Arn: "arn:aws:iam::111111111111:role/my-role", TrustPolicy: "{}"}},
+		Pods: []corev1.Pod{{
+			ObjectMeta: metav1.ObjectMeta{Name: "my-pod", Namespace: "default"},
+			Spec:       corev1.PodSpec{ServiceAccountName: "my-sa", NodeName: "node-1"},
+		}},
+		PodIdentityAgent: &PodIdentityAgent{Addon: &types.Addon{Status: types.AddonStatusActive}},
+		Warnings:         []string{"Unable to retrieve Fargate profiles"},
+	}
+
+	assert.Nil(t, snapshot.Save(path))
+	fileInfo, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), fileInfo.Mode().Perm())
+
+	loaded, err := Load(path)
+	assert.Nil(t, err)
+	assert.Equal(t, snapshot, loaded)
+}
+
+func TestSnapshotLoadRejectsInvalidFiles(t *testing.T) {
+	scenarios := []struct {
+		Name    string
+		Content string
+	}{
+		{Name: "invalid JSON", Content: "not json"},
+		{Name: "missing format version", Content: `{"cluster": {"name": "my-cluster"}}`},
+		{Name: "newer format version", Content: `{"formatVersion": 1000, "cluster": {"name": "my-cluster"}}`},
+		{Name: "missing cluster", Content: `{"formatVersion": 1}`},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.Name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "snapshot.json")
+			assert.Nil(t, os.WriteFile(path, []byte(scenario.Content), 0600))
+			snapshot, err := Load(path)
+			assert.NotNil(t, err)
+			assert.Nil(t, snapshot)
+		})
+	}
+}
+
+func TestSnapshotLoadMissingFile(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), "does-not-exist.json"))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,99 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/datadog/managed-kubernetes-auditing-toolkit/pkg/managed-kubernetes-auditing-toolkit/eks/datasource"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var errPodIdentityAgentUnknown = errors.New("the status of the EKS Pod Identity Agent was not collected in the snapshot")
+
+// source serves the content of a snapshot through the data source interfaces
+type source struct {
+	*datasource.InMemory
+	snapshot *Snapshot
+}
+
+// DataSources returns data sources serving the content of the snapshot
+func (m *Snapshot) DataSources() *datasource.DataSources {
+	inMemory := &datasource.InMemory{
+		Clusters:                []*types.Cluster{m.Cluster},
+		FargateProfiles:         map[string][]*types.FargateProfile{m.ClusterName: m.FargateProfiles},
+		PodIdentityAssociations: map[string][]*datasource.PodIdentityAssociation{m.ClusterName: m.PodIdentityAssociations},
+		IAMRoles:                m.IAMRoles,
+		ServiceAccounts:         m.ServiceAccounts,
+		Pods:                    m.Pods,
+		ConfigMaps:              m.ConfigMaps,
+		Secrets:                 m.Secrets,
+	}
+	if m.Workloads != nil {
+		inMemory.Deployments = m.Workloads.Deployments
+		inMemory.StatefulSets = m.Workloads.StatefulSets
+		inMemory.DaemonSets = m.Workloads.DaemonSets
+		inMemory.ReplicaSets = m.Workloads.ReplicaSets
+		inMemory.Jobs = m.Workloads.Jobs
+		inMemory.CronJobs = m.Workloads.CronJobs
+	}
+	if m.PodIdentityAgent != nil && m.PodIdentityAgent.Addon != nil {
+		inMemory.Addons = map[string][]*types.Addon{m.ClusterName: {m.PodIdentityAgent.Addon}}
+	}
+	if m.PodIdentityAgent != nil && m.PodIdentityAgent.DaemonSet != nil {
+		inMemory.DaemonSets = mergeDaemonSets(inMemory.DaemonSets, m.PodIdentityAgent.DaemonSet)
+		inMemory.Pods = mergePods(m.Pods, m.PodIdentityAgent.Pods)
+	}
+	source := &source{InMemory: inMemory, snapshot: m}
+	return &datasource.DataSources{Cluster: source, IAM: source, Kubernetes: source}
+}
+
+// LoadDataSources returns data sources serving the content of a snapshot file
+func LoadDataSources(path string) (*datasource.DataSources, error) {
+	snapshot, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot.DataSources(), nil
+}
+
+// The snapshot only contains the Pod Identity Agent add-on and DaemonSet, and only if their status could be collected
+func (m *source) DescribeAddon(ctx context.Context, clusterName string, addonName string) (*types.Addon, error) {
+	if addonName == PodIdentityAgentAddonName && m.snapshot.PodIdentityAgent == nil {
+		return nil, errPodIdentityAgentUnknown
+	}
+	return m.InMemory.DescribeAddon(ctx, clusterName, addonName)
+}
+
+func (m *source) GetDaemonSet(ctx context.Context, namespace string, name string) (*appsv1.DaemonSet, error) {
+	if namespace == PodIdentityAgentNamespace && name == PodIdentityAgentDaemonSetName && m.snapshot.PodIdentityAgent == nil {
+		return nil, errPodIdentityAgentUnknown
+	}
+	return m.InMemory.GetDaemonSet(ctx, namespace, name)
+}
+
+// mergePods adds the agent pods to the snapshot pods, unless they're already part of them
+func mergePods(pods []corev1.Pod, agentPods []corev1.Pod) []corev1.Pod {
+	merged := append([]corev1.Pod{}, pods...)
+	existingPods := map[string]bool{}
+	for _, pod := range pods {
+		existingPods[pod.Namespace+"/"+pod.Name] = true
+	}
+	for _, agentPod := range agentPods {
+		if !existingPods[agentPod.Namespace+"/"+agentPod.Name] {
+			merged = append(merged, agentPod)
+		}
+	}
+	return merged
+}
+
+// mergeDaemonSets adds the agent DaemonSet to the snapshot workloads, unless it's already part of them
+func mergeDaemonSets(daemonSets []appsv1.DaemonSet, agentDaemonSet *appsv1.DaemonSet) []appsv1.DaemonSet {
+	for _, daemonSet := range daemonSets {
+		if daemonSet.Namespace == agentDaemonSet.Namespace && daemonSet.Name == agentDaemonSet.Name {
+			return daemonSets
+		}
+	}
+	return append(append([]appsv1.DaemonSet{}, daemonSets...), *agentDaemonSet)
+}